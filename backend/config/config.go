@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -19,6 +20,45 @@ type Config struct {
 	TimeZone      string
 	MaxDBConn     int
 	DebugMode     bool
+
+	PaymentProvider      string
+	PaymentWebhookSecret string
+	Currency             string
+
+	PublicBaseURL       string // URL công khai của API, dùng để tạo link tải
+	DownloadTokenSecret string
+	DownloadTokenTTL    time.Duration
+
+	WatermarkEnabled  bool
+	WatermarkCacheDir string
+
+	StorageBackend   string // "local" hoặc "s3"
+	UploadRoot       string // Thư mục lưu file khi dùng local
+	S3Endpoint       string
+	S3Region         string
+	S3Bucket         string
+	S3AccessKey      string
+	S3SecretKey      string
+	S3ForcePathStyle bool
+	S3PublicURL      string // URL công khai của bucket (ảnh bìa), rỗng thì dùng endpoint
+
+	MaxBookFileSize   int64 // byte, cho mỗi file PDF/EPUB/MOBI
+	MaxCoverImageSize int64 // byte
+	MaxCoverDimension int   // pixel, cho mỗi chiều của ảnh bìa
+
+	KeywordExtractor string // "auto", "http" (dịch vụ ngoài) hoặc "builtin" (trong tiến trình)
+
+	ExtractionServiceURL       string        // URL dịch vụ trích xuất keyword/mục lục
+	ExtractionTimeout          time.Duration // Thời gian tối đa cho một lượt trích xuất
+	ExtractionHealthTimeout    time.Duration
+	ExtractionPollInterval     time.Duration // Chu kỳ worker kiểm tra job mới
+	ExtractionMaxAttempts      int
+	ExtractionRetryBase        time.Duration // Backoff: base * 2^(lần thử - 1), tối đa RetryMax
+	ExtractionRetryMax         time.Duration
+	ExtractionBreakerThreshold int           // Số lỗi liên tiếp thì mở circuit breaker
+	ExtractionBreakerCooldown  time.Duration // Thời gian breaker mở trước khi cho request thử
+
+	RecommendationRefreshInterval time.Duration // Chu kỳ tính lại độ tương đồng sách cho gợi ý
 }
 
 func LoadConfig() *Config {
@@ -35,6 +75,45 @@ func LoadConfig() *Config {
 		TimeZone:      getEnv("TIME_ZONE", "Asia/Ho_Chi_Minh"),
 		MaxDBConn:     parseInt(getEnv("MAX_DB_CONN", "10")),
 		DebugMode:     parseBool(getEnv("DEBUG_MODE", "false")),
+
+		PaymentProvider:      getEnv("PAYMENT_PROVIDER", "mock"),
+		PaymentWebhookSecret: getEnv("PAYMENT_WEBHOOK_SECRET", "default_webhook_secret_should_be_changed"),
+		Currency:             getEnv("CURRENCY", "USD"),
+
+		PublicBaseURL:       strings.TrimRight(getEnv("PUBLIC_BASE_URL", "http://localhost:8081"), "/"),
+		DownloadTokenSecret: getEnv("DOWNLOAD_TOKEN_SECRET", "default_download_secret_should_be_changed"),
+		DownloadTokenTTL:    parseDuration(getEnv("DOWNLOAD_TOKEN_TTL", "1h")),
+
+		WatermarkEnabled:  parseBool(getEnv("WATERMARK_ENABLED", "true")),
+		WatermarkCacheDir: getEnv("WATERMARK_CACHE_DIR", "/app/storage/watermarked"),
+
+		StorageBackend:   getEnv("STORAGE_BACKEND", "local"),
+		UploadRoot:       getEnv("UPLOAD_ROOT", "/app/storage"),
+		S3Endpoint:       getEnv("S3_ENDPOINT", ""),
+		S3Region:         getEnv("S3_REGION", "us-east-1"),
+		S3Bucket:         getEnv("S3_BUCKET", ""),
+		S3AccessKey:      getEnv("S3_ACCESS_KEY", ""),
+		S3SecretKey:      getEnv("S3_SECRET_KEY", ""),
+		S3ForcePathStyle: parseBool(getEnv("S3_FORCE_PATH_STYLE", "true")),
+		S3PublicURL:      getEnv("S3_PUBLIC_URL", ""),
+
+		MaxBookFileSize:   int64(parseInt(getEnv("UPLOAD_MAX_BOOK_MB", "100"))) << 20,
+		MaxCoverImageSize: int64(parseInt(getEnv("UPLOAD_MAX_IMAGE_MB", "5"))) << 20,
+		MaxCoverDimension: parseInt(getEnv("UPLOAD_MAX_IMAGE_DIMENSION", "4000")),
+
+		KeywordExtractor: getEnv("KEYWORD_EXTRACTOR", "auto"),
+
+		ExtractionServiceURL:       getEnv("EXTRACTION_SERVICE_URL", "http://localhost:8001"),
+		ExtractionTimeout:          parseDuration(getEnv("EXTRACTION_TIMEOUT", "10m")),
+		ExtractionHealthTimeout:    parseDuration(getEnv("EXTRACTION_HEALTH_TIMEOUT", "5s")),
+		ExtractionPollInterval:     parseDuration(getEnv("EXTRACTION_POLL_INTERVAL", "5s")),
+		ExtractionMaxAttempts:      parseInt(getEnv("EXTRACTION_MAX_ATTEMPTS", "5")),
+		ExtractionRetryBase:        parseDuration(getEnv("EXTRACTION_RETRY_BASE", "30s")),
+		ExtractionRetryMax:         parseDuration(getEnv("EXTRACTION_RETRY_MAX", "30m")),
+		ExtractionBreakerThreshold: parseInt(getEnv("EXTRACTION_BREAKER_THRESHOLD", "5")),
+		ExtractionBreakerCooldown:  parseDuration(getEnv("EXTRACTION_BREAKER_COOLDOWN", "1m")),
+
+		RecommendationRefreshInterval: parseDuration(getEnv("RECOMMENDATION_REFRESH_INTERVAL", "1h")),
 	}
 }
 
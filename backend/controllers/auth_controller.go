@@ -3,6 +3,7 @@ package controllers
 import (
 	"log"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/Poloni84Learning/ebook-store/config"
@@ -370,8 +371,14 @@ func (ac *AuthController) UpdateProfile(c *gin.Context) {
 }
 
 func (ac *AuthController) ListUsers(c *gin.Context) {
+	query := ac.DB
+	// Tìm theo username, email, họ tên hoặc số điện thoại, không phân biệt dấu
+	if q := strings.TrimSpace(c.Query("q")); q != "" {
+		query = query.Where("fold_text(concat_ws(' ', username, email, first_name, last_name, last_name || ' ' || first_name, phone)) LIKE ?", utils.FoldedContains(q))
+	}
+
 	var users []models.User
-	if err := ac.DB.Find(&users).Error; err != nil {
+	if err := query.Find(&users).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
 			"error":   "Failed to fetch users",
@@ -0,0 +1,175 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Poloni84Learning/ebook-store/models"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	catalogDefaultLimit = 10
+	catalogMaxLimit     = 50
+)
+
+// GetBooks - Duyệt danh mục sách với bộ lọc kết hợp, sắp xếp và phân trang bằng cursor.
+// Trang đầu (không có cursor) trả thêm số sách theo từng giá trị của mỗi chiều lọc (facets).
+func (bc *BookController) GetBooks(c *gin.Context) {
+	bc.listCatalog(c, "", "")
+}
+
+// listCatalog xử lý GetBooks và các alias by-title/by-author/by-category; searchEndpoint khác rỗng
+// thì trang đầu được ghi vào log tìm kiếm với từ khóa searchQuery
+func (bc *BookController) listCatalog(c *gin.Context, searchEndpoint, searchQuery string) {
+	started := time.Now()
+	filter, ok := parseCatalogFilter(c)
+	if !ok {
+		return
+	}
+
+	sort := models.CatalogSort(c.DefaultQuery("sort", string(models.CatalogSortNewest)))
+	if !sort.IsValid() {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "sort phải là newest, price_asc, price_desc, rating hoặc best_selling"})
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(catalogDefaultLimit)))
+	if err != nil || limit < 1 || limit > catalogMaxLimit {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "limit phải từ 1 đến " + strconv.Itoa(catalogMaxLimit)})
+		return
+	}
+	cursor := c.Query("cursor")
+
+	page, err := models.QueryCatalog(bc.DB, filter, sort, cursor, limit)
+	if errors.Is(err, models.ErrInvalidCursor) {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "cursor không hợp lệ"})
+		return
+	}
+	if err != nil {
+		log.Printf("[DEBUG] Lỗi khi lấy danh mục sách: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Không thể lấy danh sách sách"})
+		return
+	}
+
+	response := gin.H{
+		"success":     true,
+		"data":        page.Books,
+		"total":       page.Total,
+		"limit":       limit,
+		"sort":        sort,
+		"next_cursor": page.NextCursor,
+	}
+	if cursor == "" {
+		facets, err := models.CatalogFacetCounts(bc.DB, filter)
+		if err != nil {
+			log.Printf("[DEBUG] Lỗi khi đếm facet: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Không thể lấy danh sách sách"})
+			return
+		}
+		response["facets"] = facets
+
+		if searchEndpoint != "" {
+			recordSearch(c, bc.DB, bc.Config, searchEndpoint, searchQuery, int(page.Total), started)
+		}
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// parseCatalogFilter đọc bộ lọc từ query string, trả về false (đã gửi lỗi 400) nếu không hợp lệ.
+// Chiều nhiều giá trị nhận dạng lặp tham số (?category=A&category=B) hoặc phân cách bằng dấu phẩy.
+func parseCatalogFilter(c *gin.Context) (*models.CatalogFilter, bool) {
+	filter := &models.CatalogFilter{
+		Languages:  queryList(c, "language"),
+		Publishers: queryList(c, "publisher"),
+		Title:      strings.TrimSpace(c.Query("title")),
+		Author:     strings.TrimSpace(c.Query("author")),
+	}
+	for _, value := range queryList(c, "category") {
+		category := models.BookCategory(value)
+		if !category.IsValid() {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Thể loại không hợp lệ: " + value})
+			return nil, false
+		}
+		filter.Categories = append(filter.Categories, category)
+	}
+
+	floats := []struct {
+		name string
+		dest **float64
+	}{
+		{"min_price", &filter.MinPrice},
+		{"max_price", &filter.MaxPrice},
+		{"min_rating", &filter.MinRating},
+	}
+	for _, param := range floats {
+		raw := c.Query(param.name)
+		if raw == "" {
+			continue
+		}
+		value, err := strconv.ParseFloat(raw, 64)
+		if err != nil || value < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": param.name + " không hợp lệ"})
+			return nil, false
+		}
+		*param.dest = &value
+	}
+	if filter.MinPrice != nil && filter.MaxPrice != nil && *filter.MinPrice > *filter.MaxPrice {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "min_price phải nhỏ hơn hoặc bằng max_price"})
+		return nil, false
+	}
+	if filter.MinRating != nil && *filter.MinRating > 5 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "min_rating phải từ 0 đến 5"})
+		return nil, false
+	}
+
+	ints := []struct {
+		name string
+		dest **int
+	}{
+		{"year_from", &filter.YearFrom},
+		{"year_to", &filter.YearTo},
+	}
+	for _, param := range ints {
+		raw := c.Query(param.name)
+		if raw == "" {
+			continue
+		}
+		value, err := strconv.Atoi(raw)
+		if err != nil || value < 0 || value > 9999 {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": param.name + " không hợp lệ"})
+			return nil, false
+		}
+		*param.dest = &value
+	}
+	if filter.YearFrom != nil && filter.YearTo != nil && *filter.YearFrom > *filter.YearTo {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "year_from phải nhỏ hơn hoặc bằng year_to"})
+		return nil, false
+	}
+
+	if raw := c.Query("in_stock"); raw != "" {
+		inStock, err := strconv.ParseBool(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "in_stock không hợp lệ"})
+			return nil, false
+		}
+		filter.InStock = inStock
+	}
+	return filter, true
+}
+
+// queryList gộp các giá trị của tham số lặp lại và tách theo dấu phẩy, bỏ giá trị rỗng
+func queryList(c *gin.Context, name string) []string {
+	var values []string
+	for _, raw := range c.QueryArray(name) {
+		for _, value := range strings.Split(raw, ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				values = append(values, value)
+			}
+		}
+	}
+	return values
+}
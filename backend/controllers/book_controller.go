@@ -2,63 +2,64 @@ package controllers
 
 import (
 	"bytes"
-	"crypto/rand"
-	"encoding/hex"
-	"encoding/json"
+	"context"
 	"errors"
 	"fmt"
+	"image"
 	"io"
 	"log"
+	"mime"
 	"mime/multipart"
 	"net/http"
 	"os"
-	"path/filepath"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/Poloni84Learning/ebook-store/config"
+	"github.com/Poloni84Learning/ebook-store/imaging"
 	"github.com/Poloni84Learning/ebook-store/models"
+	"github.com/Poloni84Learning/ebook-store/storage"
+	"github.com/Poloni84Learning/ebook-store/utils"
+	"github.com/Poloni84Learning/ebook-store/watermark"
 	"github.com/gin-gonic/gin"
 	"github.com/lib/pq"
 	"gorm.io/gorm"
 )
 
 type BookController struct {
-	DB         *gorm.DB
-	Config     *config.Config
-	tempTokens map[string]string // Dùng cho demo
-	sync.Mutex
+	DB     *gorm.DB
+	Config *config.Config
+	Blob   storage.Blob
 }
 
 type BookWithOrderCount struct {
-	ID                   uint    `json:"id"`
-	Title                string  `json:"title"`
-	Author               string  `json:"author"`
-	CoverImage           string  `json:"cover_image"`
+	ID     uint   `json:"id"`
+	Title  string `json:"title"`
+	Author string `json:"author"`
+	models.BookCover
 	Price                float64 `json:"price"`
 	CompletedOrdersCount int64   `json:"completed_orders_count"`
 }
 
 type BookWithoutOrderCount struct {
-	ID                   uint    `json:"id"`
-	Title                string  `json:"title"`
-	Author               string  `json:"author"`
-	CoverImage           string  `json:"cover_image"`
+	ID     uint   `json:"id"`
+	Title  string `json:"title"`
+	Author string `json:"author"`
+	models.BookCover
 	Price                float64 `json:"price"`
 	CompletedOrdersCount int64   `json:"-"`
 }
 
-type PDFExtractionResult struct {
-	Keywords  []string `json:"keywords"`
-	TocTitles []string `json:"toc_titles"`
-	Status    string   `json:"status"`
-	Message   string   `json:"message,omitempty"`
-}
+// Chiều rộng ảnh bìa dựng từ trang đầu PDF
+const coverRenderWidth = 800
 
 func NewBookController(db *gorm.DB, cfg *config.Config) *BookController {
-	return &BookController{DB: db, Config: cfg, tempTokens: make(map[string]string)}
+	blob, err := storage.NewBlob(cfg)
+	if err != nil {
+		log.Fatalf("Failed to init storage: %v", err)
+	}
+	return &BookController{DB: db, Config: cfg, Blob: blob}
 }
 
 func (bc *BookController) CreateBook(c *gin.Context) {
@@ -75,8 +76,9 @@ func (bc *BookController) CreateBook(c *gin.Context) {
 		return
 	}
 
-	// 2. Parse form data với giới hạn kích thước file
-	if err := c.Request.ParseMultipartForm(32 << 20); err != nil { // 32MB max
+	// 2. Parse form data với giới hạn kích thước file (PDF, EPUB, MOBI, ảnh bìa)
+	limitUploadBody(c, 3*bc.Config.MaxBookFileSize+bc.Config.MaxCoverImageSize+1<<20)
+	if err := c.Request.ParseMultipartForm(32 << 20); err != nil { // 32MB trong bộ nhớ, phần còn lại ghi ra file tạm
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Không thể parse form data", "details": err.Error()})
 		return
 	}
@@ -124,18 +126,36 @@ func (bc *BookController) CreateBook(c *gin.Context) {
 		return
 	}
 
-	// 6. Xử lý file PDF
+	// 6. Xử lý file PDF (bắt buộc) và các định dạng khác (tùy chọn)
 	pdfFile, err := c.FormFile("pdf")
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Thiếu file PDF", "details": err.Error()})
 		return
 	}
-	var imageUrl string
-	if imageFile, err := c.FormFile("cover_image"); err == nil {
-		// Lưu file ảnh
-		imageUrl, err = SaveImageFile(imageFile)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể lưu ảnh", "details": err.Error()})
+	extraFiles := make(map[models.BookFormat]*multipart.FileHeader)
+	for _, format := range []models.BookFormat{models.FormatEPUB, models.FormatMOBI} {
+		if file, err := c.FormFile(string(format)); err == nil {
+			extraFiles[format] = file
+		}
+	}
+	imageFile, _ := c.FormFile("cover_image")
+
+	// Kiểm tra mọi file trước khi lưu để không để lại file rác khi một file không hợp lệ
+	if err := validateBookFile(pdfFile, models.FormatPDF, bc.Config.MaxBookFileSize); err != nil {
+		respondUploadError(c, err)
+		return
+	}
+	for format, file := range extraFiles {
+		if err := validateBookFile(file, format, bc.Config.MaxBookFileSize); err != nil {
+			respondUploadError(c, err)
+			return
+		}
+	}
+	var coverImg image.Image
+	var coverExt string
+	if imageFile != nil {
+		if coverImg, coverExt, err = validateCoverImage(imageFile, bc.Config.MaxCoverImageSize, bc.Config.MaxCoverDimension); err != nil {
+			respondUploadError(c, err)
 			return
 		}
 	}
@@ -148,20 +168,47 @@ func (bc *BookController) CreateBook(c *gin.Context) {
 	}
 	defer os.Remove(tempPDFPath) // Xóa file tạm sau khi xử lý xong
 
-	// 10. Lưu file PDF vĩnh viễn
-	pdfUrl, err := SavePDFFile(pdfFile)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể lưu file PDF", "details": err.Error()})
-		return
+	// 8. Không có ảnh bìa: dựng từ trang đầu của PDF trước khi lưu file nào vào storage.
+	// Lỗi (kể cả panic) khi dựng chỉ làm sách không có ảnh bìa riêng, BeforeCreate gán ảnh mặc định.
+	var pdfCover []byte
+	if imageFile == nil {
+		if coverImg, pdfCover, err = renderPDFCover(c.Request.Context(), tempPDFPath); err != nil {
+			log.Printf("[Cover] Không tạo được ảnh bìa từ PDF, dùng ảnh mặc định: %v", err)
+		}
 	}
 
-	// --- Gọi API để lấy keywords và toc_titles ---
-	keywords, tocTitles, err := callKeywordAndTOCApi(tempPDFPath, formValues["title"], formValues["author"], string(formValues["category"]), formValues["toc_pages"])
-
+	// 10. Lưu các file vĩnh viễn (lỗi ở các bước sau thì xóa lại các file đã lưu)
+	pdfBookFile, err := bc.SaveBookFile(c.Request.Context(), pdfFile, models.FormatPDF)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể lấy dữ liệu phân tích PDF", "details": err.Error()})
+		respondUploadError(c, err)
 		return
 	}
+	bookFiles := []models.BookFile{*pdfBookFile}
+	for format, file := range extraFiles {
+		bookFile, err := bc.SaveBookFile(c.Request.Context(), file, format)
+		if err != nil {
+			bc.removeBookFiles(bookFiles)
+			respondUploadError(c, err)
+			return
+		}
+		bookFiles = append(bookFiles, *bookFile)
+	}
+	var imageUrl string
+	var coverThumbnails map[string]string
+	if imageFile != nil {
+		imageUrl, coverThumbnails, err = bc.SaveImageFile(c.Request.Context(), imageFile, coverImg, coverExt)
+		if err != nil {
+			bc.removeBookFiles(bookFiles)
+			respondUploadError(c, err)
+			return
+		}
+	} else if pdfCover != nil {
+		imageUrl, coverThumbnails, err = bc.storeCover(c.Request.Context(), coverImg, bytes.NewReader(pdfCover), int64(len(pdfCover)), ".jpg")
+		if err != nil {
+			log.Printf("[Cover] Không lưu được ảnh bìa dựng từ PDF, dùng ảnh mặc định: %v", err)
+		}
+	}
+
 	// 11. Tạo book record
 	book := models.Book{
 		Title:       formValues["title"],
@@ -175,14 +222,26 @@ func (bc *BookController) CreateBook(c *gin.Context) {
 		Pages:       pages,
 		Language:    formValues["language"],
 		PublishedAt: formValues["published_at"],
-		PDFUrl:      pdfUrl,
+		PDFUrl:      pdfBookFile.URL,
+		Files:       bookFiles,
 		CoverImage:  imageUrl,
-		Keywords:    keywords,
-		TOCTitles:   tocTitles,
+		Keywords:    pq.StringArray{},
+		TOCTitles:   pq.StringArray{},
 	}
+	book.CoverThumbnails = coverThumbnails
 
-	if err := bc.DB.Create(&book).Error; err != nil {
+	// Keyword/mục lục được worker trích xuất sau, request không phải chờ dịch vụ trích xuất
+	var job *models.ExtractionJob
+	err = bc.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&book).Error; err != nil {
+			return err
+		}
+		job, err = models.EnqueueExtraction(tx, book.ID, formValues["toc_pages"], bc.Config.ExtractionMaxAttempts)
+		return err
+	})
+	if err != nil {
 		log.Printf("[ERROR] Failed to create book: %v", err)
+		bc.removeBookFiles(bookFiles)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Không thể tạo sách",
 			"details": err.Error(),
@@ -190,119 +249,19 @@ func (bc *BookController) CreateBook(c *gin.Context) {
 		return
 	}
 
+	book.ExtractionStatus = job.Status
+
 	// 12. Trả về response
 	c.JSON(http.StatusCreated, gin.H{
 		"success": true,
 		"data": gin.H{
-			"book":       book,
-			"keywords":   keywords,
-			"toc_titles": tocTitles,
+			"book":              book,
+			"extraction_status": job.Status,
+			"extraction_job_id": job.ID,
 		},
 	})
 }
 
-func callKeywordAndTOCApi(pdfPath, title, author, topic, tocPages string) (pq.StringArray, pq.StringArray, error) {
-
-	healthURL := fmt.Sprintf("%s/healthcheck", getAPIBaseURL())
-	healthResp, err := http.Get(healthURL)
-	if err != nil {
-		// If health check fails, return empty results
-		return pq.StringArray{}, pq.StringArray{}, nil
-	}
-	defer healthResp.Body.Close()
-
-	// If server is not healthy, return empty results
-	if healthResp.StatusCode != http.StatusOK {
-		return pq.StringArray{}, pq.StringArray{}, nil
-	}
-
-	// Parse health check response
-	var healthStatus struct {
-		Status string `json:"status"`
-	}
-	if err := json.NewDecoder(healthResp.Body).Decode(&healthStatus); err != nil {
-		return pq.StringArray{}, pq.StringArray{}, nil
-	}
-	if healthStatus.Status != "healthy" {
-		return pq.StringArray{}, pq.StringArray{}, nil
-	}
-
-	file, err := os.Open(pdfPath)
-	if err != nil {
-		return nil, nil, fmt.Errorf("mở file PDF thất bại: %w", err)
-	}
-	defer file.Close()
-
-	body := &bytes.Buffer{}
-	writer := multipart.NewWriter(body)
-
-	// file
-	part, err := writer.CreateFormFile("file", filepath.Base(pdfPath))
-	if err != nil {
-		return nil, nil, fmt.Errorf("tạo form file thất bại: %w", err)
-	}
-	if _, err := io.Copy(part, file); err != nil {
-		return nil, nil, fmt.Errorf("ghi dữ liệu file thất bại: %w", err)
-	}
-
-	// required fields
-	_ = writer.WriteField("book_title", title)
-	_ = writer.WriteField("authors", author)
-	_ = writer.WriteField("topic", topic)
-
-	// optional
-	if tocPages != "" {
-		_ = writer.WriteField("toc_pages", tocPages)
-	}
-
-	if err := writer.Close(); err != nil {
-		return nil, nil, fmt.Errorf("đóng writer thất bại: %w", err)
-	}
-
-	// Gửi request
-	apiURL := fmt.Sprintf("%s/extract-keywords", getAPIBaseURL())
-	req, err := http.NewRequest("POST", apiURL, body)
-	if err != nil {
-		return nil, nil, fmt.Errorf("tạo request thất bại: %w", err)
-	}
-	req.Header.Set("Content-Type", writer.FormDataContentType())
-
-	// 4. Cấu hình HTTP client
-	client := &http.Client{
-		Timeout: 0, // Vô hiệu hóa timeout
-	}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, nil, fmt.Errorf("gửi request thất bại: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		responseBody, _ := io.ReadAll(resp.Body)
-		return nil, nil, fmt.Errorf("API trả về lỗi %d: %s", resp.StatusCode, string(responseBody))
-	}
-
-	var result struct {
-		Keywords  []string `json:"keywords"`
-		TOCTitles []string `json:"toc_titles"`
-	}
-
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, nil, fmt.Errorf("decode JSON thất bại: %w", err)
-	}
-	keywords := pq.StringArray(result.Keywords)
-	tocTitles := pq.StringArray(result.TOCTitles)
-	return keywords, tocTitles, nil
-}
-
-func getAPIBaseURL() string {
-	// Khi chạy trong Docker (tự động phát hiện)
-	if _, err := os.Stat("/.dockerenv"); err == nil {
-		return "http://host.docker.internal:8001"
-	}
-	return "http://localhost:8001"
-}
-
 // Helper function để lưu file tạm
 func saveTempFile(file *multipart.FileHeader) (string, error) {
 	src, err := file.Open()
@@ -333,49 +292,48 @@ func (bc *BookController) GenerateDownloadLink(c *gin.Context) {
 		return
 	}
 
-	// Tạo token ngẫu nhiên
-	tokenBytes := make([]byte, 16)
-	if _, err := rand.Read(tokenBytes); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi hệ thống"})
-		return
+	// Khách hàng chỉ được tải sách đã mua; staff/admin được tải mọi sách
+	if c.GetString("role") == string(models.RoleCustomer) {
+		owned, err := models.HasEntitlement(bc.DB, c.GetUint("userID"), bookID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi hệ thống"})
+			return
+		}
+		if !owned {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Bạn chưa sở hữu sách này"})
+			return
+		}
 	}
-	token := hex.EncodeToString(tokenBytes)
 
-	// Lưu token vào map với mutex
-	bc.Lock()
-	bc.tempTokens[token] = bookIDStr // Lưu dưới dạng string để tiện xử lý
-	bc.Unlock()
+	// Định dạng muốn tải (mặc định PDF)
+	var book models.Book
+	if err := bc.DB.First(&book, bookID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy sách"})
+		return
+	}
+	format := models.BookFormat(strings.ToLower(c.DefaultQuery("format", string(models.FormatPDF))))
+	if _, err := models.FindBookFile(bc.DB, &book, format); err != nil {
+		formats, _ := models.AvailableFormats(bc.DB, &book)
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sách không có định dạng này", "formats": formats})
+		return
+	}
 
-	// Tạo URL tải về (sử dụng config nếu có, hoặc localhost:8081)
-	baseURL := "http://localhost:8081"
-	downloadURL := fmt.Sprintf("%s/api/books/download/%s", baseURL, token)
+	// Token được ký HMAC, chứa book ID, user ID và thời hạn nên không cần lưu trên server
+	token, expiresAt := utils.GenerateDownloadToken(bc.Config.DownloadTokenSecret, bookID, c.GetUint("userID"), bc.Config.DownloadTokenTTL)
+	downloadURL := fmt.Sprintf("%s/api/books/download/%s?format=%s", bc.Config.PublicBaseURL, token, format)
 
 	c.JSON(http.StatusOK, gin.H{
 		"success":      true,
 		"download_url": downloadURL,
-		"expires_in":   "1 hour", // Thời gian hết hạn
-	})
-
-	// Tự động xóa token sau 1 giờ
-	time.AfterFunc(1*time.Hour, func() {
-		bc.Lock()
-		delete(bc.tempTokens, token)
-		bc.Unlock()
+		"format":       format,
+		"expires_at":   expiresAt,
+		"expires_in":   bc.Config.DownloadTokenTTL.String(),
 	})
 }
 
 func (bc *BookController) DownloadFile(c *gin.Context) {
-	token := c.Param("token")
-
-	// Lấy bookID từ tempTokens
-	bc.Lock()
-	bookIDStr, exists := bc.tempTokens[token]
-	if exists {
-		delete(bc.tempTokens, token) // Xóa token sau khi dùng
-	}
-	bc.Unlock()
-
-	if !exists {
+	claims, err := utils.ParseDownloadToken(bc.Config.DownloadTokenSecret, c.Param("token"))
+	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{
 			"success": false,
 			"error":   "Link tải không hợp lệ hoặc đã hết hạn",
@@ -383,54 +341,109 @@ func (bc *BookController) DownloadFile(c *gin.Context) {
 		return
 	}
 
-	// Chuyển đổi bookID sang uint
-	var bookID uint
-	if _, err := fmt.Sscanf(bookIDStr, "%d", &bookID); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"success": false,
-			"error":   "Lỗi hệ thống",
-		})
+	// Kiểm tra lại quyền sở hữu (có thể đã bị thu hồi sau khi tạo link)
+	var user models.User
+	if err := bc.DB.First(&user, claims.UserID).Error; err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "Link tải không hợp lệ"})
+		return
+	}
+	entitlement, err := models.FindEntitlement(bc.DB, user.ID, claims.BookID)
+	if err != nil || (entitlement == nil && user.Role == models.RoleCustomer) {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "Bạn chưa sở hữu sách này"})
 		return
 	}
 
 	// Lấy thông tin sách từ database
 	var book models.Book
-	if err := bc.DB.First(&book, bookID).Error; err != nil {
+	if err := bc.DB.First(&book, claims.BookID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{
 			"success": false,
 			"error":   "Không tìm thấy sách",
 		})
 		return
 	}
-	log.Println("PDF URL from DB:", book.PDFUrl)
-
-	relativePath := strings.TrimPrefix(book.PDFUrl, "/storage/")
-	absolutePath := filepath.Join("/app/storage", relativePath)
-
-	log.Println("Absolute path to file:", absolutePath)
 
-	if _, err := os.Stat(absolutePath); os.IsNotExist(err) {
+	format := models.BookFormat(strings.ToLower(c.DefaultQuery("format", string(models.FormatPDF))))
+	bookFile, err := models.FindBookFile(bc.DB, &book, format)
+	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{
 			"success": false,
-			"error":   "File PDF không tồn tại",
-			"debug":   absolutePath,
+			"error":   "Sách không có định dạng này",
 		})
 		return
 	}
 
-	// Mở file
-	file, err := os.Open(absolutePath)
+	ctx := c.Request.Context()
+	key := bookFile.StorageKey()
+	object, err := bc.Blob.Stat(ctx, key)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+		log.Printf("[Download] Không đọc được file %s: %v", key, err)
+		status := http.StatusInternalServerError
+		if errors.Is(err, storage.ErrNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
 			"success": false,
-			"error":   "Không thể mở file",
+			"error":   "File không tồn tại",
 		})
 		return
 	}
-	defer file.Close()
 
-	// Lấy thông tin file
-	fileInfo, err := file.Stat()
+	fileName := fmt.Sprintf("%s_%s%s", book.Title, book.Author, models.BookFormats[format].Extension)
+	fileName = strings.ReplaceAll(fileName, " ", "_") // Thay thế khoảng trắng
+	disposition := fmt.Sprintf("attachment; filename=%s", fileName)
+
+	// Chỉ ghi log cho lượt tải mới, bỏ qua các request Range nối tiếp của cùng phiên tải
+	if isNewDownloadRequest(c.Request) {
+		bc.logDownload(c, claims, format)
+	}
+
+	// Đóng dấu thông tin người mua (chỉ PDF); nếu không xử lý được thì phục vụ file gốc
+	var content io.ReadSeeker
+	modTime := object.ModTime
+	if bc.Config.WatermarkEnabled && format == models.FormatPDF {
+		if stamped, err := bc.watermarkFor(key, object, &book, &user, entitlement); err != nil {
+			log.Printf("[Download] Không đóng dấu được sách %d cho user %d, dùng file gốc: %v", book.ID, user.ID, err)
+		} else if file, err := os.Open(stamped); err == nil {
+			defer file.Close()
+			if fi, err := file.Stat(); err == nil {
+				modTime = fi.ModTime()
+			}
+			content = file
+		}
+	}
+
+	if content == nil {
+		// Object storage: chuyển hướng tới signed URL, client tải thẳng (kể cả Range) từ storage
+		if _, local := bc.Blob.(*storage.Local); !local {
+			signedURL, err := bc.Blob.SignedURL(ctx, key, bc.Config.DownloadTokenTTL, storage.SignedURLOptions{
+				ContentType:        bookFile.MimeType,
+				ContentDisposition: disposition,
+			})
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Không thể tạo link tải"})
+				return
+			}
+			c.Redirect(http.StatusFound, signedURL)
+			return
+		}
+
+		rc, _, err := bc.Blob.Get(ctx, key)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"error":   "Không thể mở file",
+			})
+			return
+		}
+		defer rc.Close()
+		content = rc.(io.ReadSeeker)
+	}
+
+	size, err := content.Seek(0, io.SeekEnd)
+	if err == nil {
+		_, err = content.Seek(0, io.SeekStart)
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
@@ -440,37 +453,78 @@ func (bc *BookController) DownloadFile(c *gin.Context) {
 	}
 
 	// Thiết lập headers
-	fileName := fmt.Sprintf("%s_%s.pdf", book.Title, book.Author)
-	fileName = strings.ReplaceAll(fileName, " ", "_") // Thay thế khoảng trắng
 	c.Header("Content-Description", "File Transfer")
-	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", fileName))
-	c.Header("Content-Type", "application/pdf")
-	c.Header("Content-Length", fmt.Sprintf("%d", fileInfo.Size()))
+	c.Header("Content-Disposition", disposition)
+	c.Header("Content-Type", bookFile.MimeType)
+	c.Header("Cache-Control", "private, no-transform")
+	// ETag theo kích thước + thời điểm sửa file để If-Range/If-None-Match hoạt động
+	c.Header("ETag", fmt.Sprintf(`"%d-%s-%x-%x"`, book.ID, format, size, modTime.UnixNano()))
+
+	// ServeContent xử lý Range/If-Range (206), If-None-Match/If-Modified-Since (304) và Last-Modified
+	http.ServeContent(c.Writer, c.Request, fileName, modTime, content)
+}
 
-	// Gửi file
-	if _, err := io.Copy(c.Writer, file); err != nil {
-		log.Printf("Lỗi khi gửi file: %v", err)
+// ServeSignedFile phục vụ signed URL của storage local (object storage tự phục vụ link của nó)
+func (bc *BookController) ServeSignedFile(c *gin.Context) {
+	local, ok := bc.Blob.(*storage.Local)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Không tìm thấy file"})
+		return
 	}
+	local.ServeSigned(c.Writer, c.Request, strings.TrimPrefix(c.Param("key"), "/"))
 }
 
-func (bc *BookController) GetBooks(c *gin.Context) {
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
-	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
-	offset := (page - 1) * limit
+// watermarkFor trả về file PDF đã đóng dấu (email, đơn hàng, thời điểm) cho user
+func (bc *BookController) watermarkFor(key string, object *storage.ObjectInfo, book *models.Book, user *models.User, entitlement *models.LibraryEntitlement) (string, error) {
+	mark := watermark.Mark{Licensee: user.Email, IssuedAt: time.Now()}
+	if mark.Licensee == "" {
+		mark.Licensee = user.Username
+	}
+	if entitlement != nil && entitlement.OrderID != nil {
+		mark.OrderID = *entitlement.OrderID
+	}
 
-	var books []models.Book
-	var total int64
+	cache := watermark.Cache{Dir: bc.Config.WatermarkCacheDir}
+	src := watermark.Source{
+		Size:    object.Size,
+		ModTime: object.ModTime,
+		Open: func() (io.ReadCloser, error) {
+			rc, _, err := bc.Blob.Get(context.Background(), key)
+			return rc, err
+		},
+	}
+	return cache.Get(src, book.ID, user.ID, mark)
+}
+
+// isNewDownloadRequest cho biết request bắt đầu một lượt tải mới (không Range hoặc Range từ byte 0)
+func isNewDownloadRequest(r *http.Request) bool {
+	if r.Method != http.MethodGet {
+		return false
+	}
+	if r.Header.Get("If-None-Match") != "" || r.Header.Get("If-Modified-Since") != "" {
+		return false
+	}
+	rangeHeader := r.Header.Get("Range")
+	return rangeHeader == "" || strings.HasPrefix(strings.TrimSpace(rangeHeader), "bytes=0-")
+}
 
-	bc.DB.Model(&models.Book{}).Count(&total)
-	bc.DB.Offset(offset).Limit(limit).Find(&books)
+// logDownload ghi lại lượt tải; lỗi ghi log không chặn việc tải file
+func (bc *BookController) logDownload(c *gin.Context, claims *utils.DownloadClaims, format models.BookFormat) {
+	userAgent := c.Request.UserAgent()
+	if len(userAgent) > 255 {
+		userAgent = userAgent[:255]
+	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"success": true,
-		"data":    books,
-		"total":   total,
-		"page":    page,
-		"limit":   limit,
-	})
+	if err := bc.DB.Create(&models.DownloadLog{
+		UserID:       claims.UserID,
+		BookID:       claims.BookID,
+		Format:       format,
+		IPAddress:    c.ClientIP(),
+		UserAgent:    userAgent,
+		TokenExpires: claims.ExpiresAt,
+	}).Error; err != nil {
+		log.Printf("[Download] Lỗi ghi log tải sách %d của user %d: %v", claims.BookID, claims.UserID, err)
+	}
 }
 
 func (bc *BookController) GetBook(c *gin.Context) {
@@ -485,6 +539,10 @@ func (bc *BookController) GetBook(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Không tìm thấy sách"})
 		return
 	}
+	// Mở sách từ kết quả tìm kiếm (client gửi lại X-Search-ID): tính click-through
+	if searchID := c.Query("search_id"); searchID != "" {
+		recordSearchClick(bc.DB, searchID, book.ID)
+	}
 	var totalReviews int64
 	err = bc.DB.Table("reviews").Where("book_id = ?", id).Count(&totalReviews).Error
 	if err != nil {
@@ -569,75 +627,69 @@ func (bc *BookController) DeleteBook(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Xoá sách thành công"})
 }
 
-func SavePDFFile(file *multipart.FileHeader) (string, error) {
-	uploadRoot := os.Getenv("UPLOAD_ROOT")
-	if uploadRoot == "" {
-		uploadRoot = "./storage" // fallback
-	}
-	log.Println("UPLOAD_ROOT:", uploadRoot)
-
-	filename := fmt.Sprintf("%d_%s", time.Now().UnixNano(), filepath.Base(file.Filename))
-	savePath := filepath.Join(uploadRoot, "pdf", filename)
-	log.Println("Save path:", savePath)
-
-	if err := os.MkdirAll(filepath.Dir(savePath), os.ModePerm); err != nil {
-		log.Println("MkdirAll error:", err)
-		return "", err
-	}
-
-	if err := saveUploadedFile(file, savePath); err != nil {
-		log.Println("Save file error:", err)
-		return "", err
+// SaveImageFile lưu ảnh bìa đã qua validateCoverImage (img là ảnh đã giải mã), kèm các bản thu nhỏ JPEG (small, medium, large)
+// với tên ngẫu nhiên. Trả về URL công khai của bản gốc và URL các bản thu nhỏ theo kích thước.
+func (bc *BookController) SaveImageFile(ctx context.Context, file *multipart.FileHeader, img image.Image, ext string) (string, map[string]string, error) {
+	src, err := file.Open()
+	if err != nil {
+		return "", nil, err
 	}
+	defer src.Close()
 
-	log.Println("File saved successfully:", savePath)
-	publicURL := "/storage/pdf/" + filename
-	return publicURL, nil
+	return bc.storeCover(ctx, img, src, file.Size, ext)
 }
 
-func SaveImageFile(file *multipart.FileHeader) (string, error) {
-	uploadRoot := os.Getenv("UPLOAD_ROOT")
-	if uploadRoot == "" {
-		uploadRoot = "./storage" // fallback
-	}
-
-	// Tạo tên file độc đáo với timestamp
-	filename := fmt.Sprintf("%d_%s", time.Now().UnixNano(), filepath.Base(file.Filename))
-
-	// Đường dẫn lưu trong thư mục images
-	savePath := filepath.Join(uploadRoot, "images", filename)
+// renderPDFCover dựng ảnh bìa JPEG từ trang đầu của file PDF, trả về ảnh và dữ liệu JPEG.
+// Panic khi đọc PDF hỏng được đổi thành lỗi để request upload không bị hỏng theo.
+func renderPDFCover(ctx context.Context, pdfPath string) (cover image.Image, data []byte, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			cover, data, err = nil, nil, fmt.Errorf("render cover panic: %v", r)
+		}
+	}()
 
-	// Tạo thư mục nếu chưa tồn tại
-	if err := os.MkdirAll(filepath.Dir(savePath), os.ModePerm); err != nil {
-		return "", fmt.Errorf("failed to create directory: %v", err)
+	img, err := imaging.RenderFirstPage(ctx, pdfPath, coverRenderWidth)
+	if err != nil {
+		return nil, nil, err
 	}
-
-	// Lưu file
-	if err := saveUploadedFile(file, savePath); err != nil {
-		return "", fmt.Errorf("failed to save image: %v", err)
+	cover = imaging.Resize(imaging.Flatten(img), coverRenderWidth)
+	data, err = imaging.EncodeJPEG(cover, 90)
+	if err != nil {
+		return nil, nil, err
 	}
-
-	// Trả về đường dẫn public
-	publicURL := "/storage/images/" + filename
-	return publicURL, nil
+	return cover, data, nil
 }
 
-// Hàm save an toàn hơn cho gin
-func saveUploadedFile(file *multipart.FileHeader, dst string) error {
-	src, err := file.Open()
+// storeCover lưu ảnh bìa gốc (data) và các bản thu nhỏ JPEG dựng từ img
+func (bc *BookController) storeCover(ctx context.Context, img image.Image, data io.Reader, size int64, ext string) (string, map[string]string, error) {
+	name, err := randomStorageName("")
 	if err != nil {
-		return err
+		return "", nil, err
+	}
+	key := "images/" + name + ext
+	if err := bc.Blob.Put(ctx, key, data, size, mime.TypeByExtension(ext)); err != nil {
+		return "", nil, fmt.Errorf("failed to save image: %v", err)
 	}
-	defer src.Close()
 
-	out, err := os.Create(dst)
-	if err != nil {
-		return err
+	// Bản thu nhỏ lưu cạnh ảnh gốc: images/<tên>_<kích thước>.jpg
+	thumbnails := make(map[string]string)
+	flat := imaging.Flatten(img)
+	for _, size := range models.CoverSizes {
+		if flat.Bounds().Dx() <= size.Width {
+			continue // Không phóng to, kích thước này dùng ảnh gốc
+		}
+		data, err := imaging.EncodeJPEG(imaging.Resize(flat, size.Width), 85)
+		if err != nil {
+			return "", nil, err
+		}
+		thumbKey := fmt.Sprintf("images/%s_%s.jpg", name, size.Name)
+		if err := bc.Blob.Put(ctx, thumbKey, bytes.NewReader(data), int64(len(data)), "image/jpeg"); err != nil {
+			return "", nil, fmt.Errorf("failed to save thumbnail: %v", err)
+		}
+		thumbnails[size.Name] = bc.Blob.PublicURL(thumbKey)
 	}
-	defer out.Close()
 
-	_, err = io.Copy(out, src)
-	return err
+	return bc.Blob.PublicURL(key), thumbnails, nil
 }
 
 func (bc *BookController) GetBookCombos(c *gin.Context) {
@@ -695,7 +747,7 @@ func (bc *BookController) GetTopBooksByCompletedOrders(c *gin.Context) {
 
 	err = bc.DB.
 		Table("order_items").
-		Select("books.id, books.title, books.author, books.cover_image, books.price, SUM(order_items.quantity) as completed_orders_count").
+		Select("books.id, books.title, books.author, books.cover_image, books.cover_thumbnails, books.price, SUM(order_items.quantity) as completed_orders_count").
 		Joins("JOIN books ON order_items.book_id = books.id").
 		Joins("JOIN orders ON order_items.order_id = orders.id").
 		Where("orders.status = ? AND "+timeCondition, "completed").
@@ -709,6 +761,9 @@ func (bc *BookController) GetTopBooksByCompletedOrders(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Không thể lấy dữ liệu sách top order"})
 		return
 	}
+	for i := range results {
+		results[i].ResolveCoverImages()
+	}
 
 	c.JSON(http.StatusOK, gin.H{"success": true,
 		"period": period,
@@ -717,80 +772,38 @@ func (bc *BookController) GetTopBooksByCompletedOrders(c *gin.Context) {
 	})
 }
 
+// GetBooksByTitle - Alias của GetBooks với bộ lọc title bắt buộc
 func (bc *BookController) GetBooksByTitle(c *gin.Context) {
-	title := c.DefaultQuery("title", "")
-
+	title := strings.TrimSpace(c.Query("title"))
 	if title == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Title is required"})
 		return
 	}
-
-	var books []models.Book
-
-	err := bc.DB.
-		Where("title ILIKE ?", "%"+title+"%").
-		Find(&books).Error
-
-	if err != nil {
-		log.Printf("[DEBUG] Lỗi khi lấy sách theo title: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Không thể lấy sách theo title"})
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{"success": true,
-		"data": books,
-	})
+	bc.listCatalog(c, "by-title", title)
 }
 
+// GetBooksByAuthor - Alias của GetBooks với bộ lọc author bắt buộc
 func (bc *BookController) GetBooksByAuthor(c *gin.Context) {
-	author := c.DefaultQuery("author", "")
-
+	author := strings.TrimSpace(c.Query("author"))
 	if author == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Author is required"})
 		return
 	}
-
-	var books []models.Book
-
-	err := bc.DB.
-		Where("author ILIKE ?", "%"+author+"%").
-		Find(&books).Error
-
-	if err != nil {
-		log.Printf("[DEBUG] Lỗi khi lấy sách theo author: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Không thể lấy sách theo author"})
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{"success": true,
-		"data": books,
-	})
+	bc.listCatalog(c, "by-author", author)
 }
 
+// GetBooksByCategory - Alias của GetBooks với bộ lọc category bắt buộc
 func (bc *BookController) GetBooksByCategory(c *gin.Context) {
-	categoryParam := c.Query("category")
-	if categoryParam == "" {
+	category := c.Query("category")
+	if category == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Category is required"})
 		return
 	}
-
-	category := models.BookCategory(categoryParam)
-	if !category.IsValid() {
-		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid category"})
-		return
-	}
-
-	var books []models.Book
-	if err := bc.DB.Where("category = ?", category).Find(&books).Error; err != nil {
-		log.Printf("[DEBUG] Lỗi khi lấy sách theo category: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Không thể lấy sách theo category"})
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{"success": true, "data": books})
+	bc.listCatalog(c, "by-category", category)
 }
 
 func (bc *BookController) SearchBooks(c *gin.Context) {
+	started := time.Now()
 	author := c.Query("author")
 	title := c.Query("title")
 	category := c.Query("category")
@@ -800,16 +813,16 @@ func (bc *BookController) SearchBooks(c *gin.Context) {
 	query := bc.DB
 
 	if author != "" {
-		query = query.Or("author ILIKE ?", "%"+author+"%")
+		query = query.Or("fold_text(author) LIKE ?", utils.FoldedContains(author))
 	}
 	if title != "" {
-		query = query.Or("title ILIKE ?", "%"+title+"%")
+		query = query.Or("fold_text(title) LIKE ?", utils.FoldedContains(title))
 	}
 	if category != "" {
-		query = query.Or("category ILIKE ?", "%"+category+"%")
+		query = query.Or("fold_text(category) LIKE ?", utils.FoldedContains(category))
 	}
 	if description != "" {
-		query = query.Or("description ILIKE ?", "%"+description+"%")
+		query = query.Or("fold_text(description) LIKE ?", utils.FoldedContains(description))
 	}
 
 	if err := query.Find(&books).Error; err != nil {
@@ -817,9 +830,25 @@ func (bc *BookController) SearchBooks(c *gin.Context) {
 		return
 	}
 
+	recordSearch(c, bc.DB, bc.Config, "search-books", searchBooksQuery(title, author, category, description), len(books), started)
 	c.JSON(http.StatusOK, books)
 }
 
+// searchBooksQuery gộp các tham số của SearchBooks thành một từ khóa để ghi log
+// (giao diện thường gửi cùng một chuỗi cho mọi trường)
+func searchBooksQuery(values ...string) string {
+	var parts []string
+	seen := map[string]bool{}
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value != "" && !seen[value] {
+			seen[value] = true
+			parts = append(parts, value)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
 func (bc *BookController) GetTopBooks(c *gin.Context) {
 	period := c.DefaultQuery("period", "yesterday") // mặc định là ngày hôm qua
 	limitStr := c.DefaultQuery("limit", "10")
@@ -846,7 +875,7 @@ func (bc *BookController) GetTopBooks(c *gin.Context) {
 	var results []BookWithOrderCount
 	err = bc.DB.
 		Table("order_items").
-		Select("books.id, books.title, books.author, books.cover_image, books.price, SUM(order_items.quantity) as completed_orders_count").
+		Select("books.id, books.title, books.author, books.cover_image, books.cover_thumbnails, books.price, SUM(order_items.quantity) as completed_orders_count").
 		Joins("JOIN books ON order_items.book_id = books.id").
 		Joins("JOIN orders ON order_items.order_id = orders.id").
 		Where("orders.status = ? AND "+timeCondition, "completed").
@@ -859,6 +888,9 @@ func (bc *BookController) GetTopBooks(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Không thể lấy dữ liệu sách bán chạy"})
 		return
 	}
+	for i := range results {
+		results[i].ResolveCoverImages()
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -1034,8 +1066,9 @@ func (bc *BookController) GetKeywordsAndTOC(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"data": gin.H{
-			"keywords":   book.Keywords,
-			"toc_titles": book.TOCTitles,
+			"keywords":          book.Keywords,
+			"toc_titles":        book.TOCTitles,
+			"extraction_status": book.ExtractionStatus,
 		},
 	})
 }
@@ -1070,26 +1103,27 @@ func (bc *BookController) UpdateKeywordsAndTOC(c *gin.Context) {
 }
 
 func (bc *BookController) SearchByKeywords(c *gin.Context) {
+	started := time.Now()
 	searchTerm := strings.TrimSpace(c.Query("q"))
 	if searchTerm == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Thiếu từ khóa tìm kiếm"})
 		return
 	}
 
-	// Loại bỏ các từ khóa trống trong mảng keywords và toc_titles
-	cleanSearchTerm := strings.ToLower(searchTerm)
+	// So khớp không phân biệt dấu, bỏ qua các từ khóa trống trong mảng keywords và toc_titles
+	pattern := utils.FoldedContains(searchTerm)
 
 	var books []models.Book
 	err := bc.DB.Where(
 		`(EXISTS (
             SELECT 1 FROM unnest(keywords) AS k 
-            WHERE k <> '' AND LOWER(k) LIKE ?
+            WHERE k <> '' AND fold_text(k) LIKE ?
         ) OR EXISTS (
             SELECT 1 FROM unnest(toc_titles) AS t 
-            WHERE t <> '' AND LOWER(t) LIKE ?
+            WHERE t <> '' AND fold_text(t) LIKE ?
         ))`,
-		"%"+cleanSearchTerm+"%",
-		"%"+cleanSearchTerm+"%",
+		pattern,
+		pattern,
 	).Find(&books).Error
 
 	if err != nil {
@@ -1100,6 +1134,7 @@ func (bc *BookController) SearchByKeywords(c *gin.Context) {
 		return
 	}
 
+	recordSearch(c, bc.DB, bc.Config, "keywords", searchTerm, len(books), started)
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"data":    books,
@@ -0,0 +1,111 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Poloni84Learning/ebook-store/extraction"
+	"github.com/Poloni84Learning/ebook-store/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// TriggerExtraction đưa sách vào hàng đợi trích xuất keyword/mục lục lại (admin)
+func (bc *BookController) TriggerExtraction(c *gin.Context) {
+	var book models.Book
+	if err := bc.DB.First(&book, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy sách"})
+		return
+	}
+
+	var request struct {
+		TOCPages string `json:"toc_pages"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Dữ liệu không hợp lệ"})
+			return
+		}
+	}
+
+	var job *models.ExtractionJob
+	err := bc.DB.Transaction(func(tx *gorm.DB) error {
+		// Khóa dòng sách để hai request đồng thời không tạo hai job
+		if err := tx.Exec("SELECT id FROM books WHERE id = ? FOR UPDATE", book.ID).Error; err != nil {
+			return err
+		}
+		var err error
+		job, err = models.EnqueueExtraction(tx, book.ID, request.TOCPages, bc.Config.ExtractionMaxAttempts)
+		return err
+	})
+	if errors.Is(err, models.ErrExtractionInProgress) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Sách đang chờ hoặc đang được trích xuất"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể tạo job trích xuất", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"success": true,
+		"data":    job,
+	})
+}
+
+// GetExtractionJobs trả về lịch sử job trích xuất của sách, mới nhất trước (admin)
+func (bc *BookController) GetExtractionJobs(c *gin.Context) {
+	var book models.Book
+	if err := bc.DB.First(&book, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy sách"})
+		return
+	}
+
+	var jobs []models.ExtractionJob
+	if err := bc.DB.Where("book_id = ?", book.ID).Order("created_at DESC").Limit(20).Find(&jobs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể lấy danh sách job"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"extraction_status": book.ExtractionStatus,
+			"jobs":              jobs,
+		},
+	})
+}
+
+// GetExtractionHealth trả về trạng thái dịch vụ trích xuất: circuit breaker, kết quả
+// healthcheck hiện tại và số job theo trạng thái (admin)
+func (bc *BookController) GetExtractionHealth(c *gin.Context) {
+	service := extraction.NewHTTPExtractor(bc.Config)
+	serviceStatus := "healthy"
+	if err := service.Healthcheck(c.Request.Context()); err != nil {
+		serviceStatus = err.Error()
+	}
+
+	var counts []struct {
+		Status models.ExtractionStatus
+		Count  int64
+	}
+	if err := bc.DB.Model(&models.ExtractionJob{}).Select("status, COUNT(*) AS count").Group("status").Scan(&counts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể lấy thống kê job"})
+		return
+	}
+	jobs := gin.H{}
+	for _, row := range counts {
+		jobs[string(row.Status)] = row.Count
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"extractor":      bc.Config.KeywordExtractor,
+			"service_url":    service.BaseURL,
+			"service_status": serviceStatus,
+			"breaker":        service.Breaker.Status(),
+			"jobs":           jobs,
+		},
+	})
+}
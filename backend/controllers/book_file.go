@@ -0,0 +1,163 @@
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/Poloni84Learning/ebook-store/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// SaveBookFile lưu file vào storage với tên ngẫu nhiên và tính SHA-256. File phải đã qua
+// validateBookFile (kích thước, nội dung thật thay vì phần mở rộng/Content-Type do client gửi, cấu trúc file).
+func (bc *BookController) SaveBookFile(ctx context.Context, file *multipart.FileHeader, format models.BookFormat) (*models.BookFile, error) {
+	spec := models.BookFormats[format]
+
+	src, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	name, err := randomStorageName(spec.Extension)
+	if err != nil {
+		return nil, err
+	}
+	key := string(format) + "/" + name
+
+	hash := sha256.New()
+	if err := bc.Blob.Put(ctx, key, io.TeeReader(src, hash), file.Size, spec.MimeType); err != nil {
+		return nil, err
+	}
+
+	log.Println("File saved successfully:", key)
+	return &models.BookFile{
+		Format:       format,
+		URL:          "/storage/" + key,
+		MimeType:     spec.MimeType,
+		Size:         file.Size,
+		Checksum:     hex.EncodeToString(hash.Sum(nil)),
+		OriginalName: filepath.Base(file.Filename),
+	}, nil
+}
+
+// removeBookFiles xóa các file vừa lưu vào storage khi không tạo được sách, tránh để lại file mồ côi
+func (bc *BookController) removeBookFiles(files []models.BookFile) {
+	for _, f := range files {
+		if err := bc.Blob.Delete(context.Background(), f.StorageKey()); err != nil {
+			log.Printf("[Storage] Không xóa được file %s: %v", f.StorageKey(), err)
+		}
+	}
+}
+
+// GetBookFiles - Danh sách định dạng tải được của sách
+func (bc *BookController) GetBookFiles(c *gin.Context) {
+	var book models.Book
+	if err := bc.DB.First(&book, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Không tìm thấy sách"})
+		return
+	}
+
+	var files []models.BookFile
+	if err := bc.DB.Where("book_id = ?", book.ID).Order("format").Find(&files).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Không thể lấy danh sách file"})
+		return
+	}
+	formats, err := models.AvailableFormats(bc.DB, &book)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Không thể lấy danh sách file"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": files, "formats": formats})
+}
+
+// UploadBookFile - Thêm hoặc thay thế một định dạng của sách (multipart: format, file)
+func (bc *BookController) UploadBookFile(c *gin.Context) {
+	var book models.Book
+	if err := bc.DB.First(&book, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Không tìm thấy sách"})
+		return
+	}
+
+	limitUploadBody(c, bc.Config.MaxBookFileSize+1<<20)
+	format := models.BookFormat(strings.ToLower(c.PostForm("format")))
+	if !format.IsValid() {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Định dạng không hợp lệ (pdf, epub, mobi)"})
+		return
+	}
+	upload, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Thiếu file", "details": err.Error()})
+		return
+	}
+
+	if err := validateBookFile(upload, format, bc.Config.MaxBookFileSize); err != nil {
+		respondUploadError(c, err)
+		return
+	}
+	bookFile, err := bc.SaveBookFile(c.Request.Context(), upload, format)
+	if err != nil {
+		respondUploadError(c, err)
+		return
+	}
+	bookFile.BookID = book.ID
+
+	err = bc.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("book_id = ? AND format = ?", book.ID, format).Delete(&models.BookFile{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(bookFile).Error; err != nil {
+			return err
+		}
+		// Giữ PDFUrl đồng bộ cho các client cũ
+		if format == models.FormatPDF {
+			return tx.Model(&book).Update("pdf_url", bookFile.URL).Error
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Không thể lưu file"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"success": true, "data": bookFile})
+}
+
+// DeleteBookFile - Gỡ một định dạng của sách
+func (bc *BookController) DeleteBookFile(c *gin.Context) {
+	format := models.BookFormat(strings.ToLower(c.Param("format")))
+
+	var deleted int64
+	err := bc.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("book_id = ? AND format = ?", c.Param("id"), format).Delete(&models.BookFile{})
+		if result.Error != nil {
+			return result.Error
+		}
+		deleted = result.RowsAffected
+		if format == models.FormatPDF {
+			pdf := tx.Model(&models.Book{}).Where("id = ? AND pdf_url <> ''", c.Param("id")).Update("pdf_url", "")
+			deleted += pdf.RowsAffected
+			return pdf.Error
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Không thể xóa file"})
+		return
+	}
+	if deleted == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Sách không có định dạng này"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Đã xóa định dạng " + string(format)})
+}
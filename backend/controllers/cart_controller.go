@@ -0,0 +1,391 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/Poloni84Learning/ebook-store/config"
+	"github.com/Poloni84Learning/ebook-store/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type CartController struct {
+	DB     *gorm.DB
+	Config *config.Config
+}
+
+type CartItemInput struct {
+	BookID   uint `json:"book_id" binding:"required"`
+	Quantity int  `json:"quantity" binding:"required,min=1"`
+}
+
+type CartItemUpdateInput struct {
+	Quantity int `json:"quantity" binding:"required,min=1"`
+}
+
+type CheckoutInput struct {
+	PaymentMethod string `json:"payment_method" binding:"required,oneof=Card COD BankTransfer"`
+	CouponCode    string `json:"coupon_code" binding:"max=50"`
+}
+
+// Các mã lỗi cho từng dòng khi checkout
+const (
+	CartProblemBookUnavailable   = "book_unavailable"
+	CartProblemInsufficientStock = "insufficient_stock"
+	CartProblemPriceChanged      = "price_changed"
+)
+
+// CartLineProblem mô tả vấn đề của một dòng trong giỏ hàng khi checkout
+type CartLineProblem struct {
+	CartItemID uint    `json:"cart_item_id"`
+	BookID     uint    `json:"book_id"`
+	Title      string  `json:"title,omitempty"`
+	Code       string  `json:"code"`
+	Message    string  `json:"message"`
+	Requested  int     `json:"requested,omitempty"`
+	Available  int     `json:"available,omitempty"`
+	OldPrice   float64 `json:"old_price,omitempty"`
+	NewPrice   float64 `json:"new_price,omitempty"`
+}
+
+func NewCartController(db *gorm.DB, cfg *config.Config) *CartController {
+	return &CartController{DB: db, Config: cfg}
+}
+
+// getOrCreateCart lấy giỏ hàng của user, tạo mới nếu chưa có
+func (cc *CartController) getOrCreateCart(db *gorm.DB, userID uint) (*models.Cart, error) {
+	cart := models.Cart{UserID: userID}
+	if err := db.Where("user_id = ?", userID).FirstOrCreate(&cart).Error; err != nil {
+		return nil, err
+	}
+	return &cart, nil
+}
+
+// loadCart load lại giỏ hàng kèm danh sách sách
+func (cc *CartController) loadCart(cartID uint) (*models.Cart, error) {
+	var cart models.Cart
+	if err := cc.DB.
+		Preload("CartItems", func(db *gorm.DB) *gorm.DB { return db.Order("cart_items.id") }).
+		Preload("CartItems.Book").
+		First(&cart, cartID).Error; err != nil {
+		return nil, err
+	}
+	return &cart, nil
+}
+
+func (cc *CartController) respondCart(c *gin.Context, status int, cartID uint) {
+	cart, err := cc.loadCart(cartID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch cart"})
+		return
+	}
+
+	c.JSON(status, gin.H{
+		"success":  true,
+		"data":     cart,
+		"subtotal": cart.Subtotal(),
+	})
+}
+
+// GetCart - Lấy giỏ hàng của user hiện tại
+func (cc *CartController) GetCart(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	cart, err := cc.getOrCreateCart(cc.DB, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get cart"})
+		return
+	}
+
+	cc.respondCart(c, http.StatusOK, cart.ID)
+}
+
+// AddItem - Thêm sách vào giỏ (cộng dồn số lượng nếu đã có)
+func (cc *CartController) AddItem(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	var input CartItemInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var book models.Book
+	if err := cc.DB.First(&book, input.BookID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Book not found"})
+		return
+	}
+
+	cart, err := cc.getOrCreateCart(cc.DB, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get cart"})
+		return
+	}
+
+	var item models.CartItem
+	err = cc.DB.Where("cart_id = ? AND book_id = ?", cart.ID, book.ID).First(&item).Error
+	switch {
+	case err == nil:
+		item.Quantity += input.Quantity
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		item = models.CartItem{CartID: cart.ID, BookID: book.ID, Quantity: input.Quantity}
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add item"})
+		return
+	}
+
+	if item.Quantity > book.Stock {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":     "Not enough stock for book " + book.Title,
+			"available": book.Stock,
+		})
+		return
+	}
+	item.Price = book.Price
+
+	if err := cc.DB.Save(&item).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add item"})
+		return
+	}
+
+	cc.respondCart(c, http.StatusOK, cart.ID)
+}
+
+// UpdateItem - Cập nhật số lượng của một dòng trong giỏ
+func (cc *CartController) UpdateItem(c *gin.Context) {
+	userID := c.GetUint("userID")
+	itemID := c.Param("id")
+
+	var input CartItemUpdateInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	cart, err := cc.getOrCreateCart(cc.DB, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get cart"})
+		return
+	}
+
+	var item models.CartItem
+	if err := cc.DB.Preload("Book").Where("id = ? AND cart_id = ?", itemID, cart.ID).First(&item).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Cart item not found"})
+		return
+	}
+
+	if item.Book.ID != 0 && input.Quantity > item.Book.Stock {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":     "Not enough stock for book " + item.Book.Title,
+			"available": item.Book.Stock,
+		})
+		return
+	}
+
+	if err := cc.DB.Model(&item).Update("quantity", input.Quantity).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update item"})
+		return
+	}
+
+	cc.respondCart(c, http.StatusOK, cart.ID)
+}
+
+// RemoveItem - Xóa một dòng khỏi giỏ
+func (cc *CartController) RemoveItem(c *gin.Context) {
+	userID := c.GetUint("userID")
+	itemID := c.Param("id")
+
+	cart, err := cc.getOrCreateCart(cc.DB, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get cart"})
+		return
+	}
+
+	result := cc.DB.Where("id = ? AND cart_id = ?", itemID, cart.ID).Delete(&models.CartItem{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove item"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Cart item not found"})
+		return
+	}
+
+	cc.respondCart(c, http.StatusOK, cart.ID)
+}
+
+// ClearCart - Xóa toàn bộ giỏ hàng
+func (cc *CartController) ClearCart(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	cart, err := cc.getOrCreateCart(cc.DB, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get cart"})
+		return
+	}
+
+	if err := cc.DB.Where("cart_id = ?", cart.ID).Delete(&models.CartItem{}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to clear cart"})
+		return
+	}
+
+	cc.respondCart(c, http.StatusOK, cart.ID)
+}
+
+// Checkout - Chuyển giỏ hàng thành đơn hàng
+func (cc *CartController) Checkout(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	var input CheckoutInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	cart, err := cc.getOrCreateCart(cc.DB, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get cart"})
+		return
+	}
+	cart, err = cc.loadCart(cart.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get cart"})
+		return
+	}
+
+	if len(cart.CartItems) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cart is empty"})
+		return
+	}
+
+	// Kiểm tra lại tồn kho và giá hiện tại cho từng dòng
+	var problems []CartLineProblem
+	for _, item := range cart.CartItems {
+		book := item.Book
+		if book.ID == 0 {
+			problems = append(problems, CartLineProblem{
+				CartItemID: item.ID,
+				BookID:     item.BookID,
+				Code:       CartProblemBookUnavailable,
+				Message:    "Book is no longer available",
+			})
+			continue
+		}
+
+		if book.Stock < item.Quantity {
+			problems = append(problems, CartLineProblem{
+				CartItemID: item.ID,
+				BookID:     book.ID,
+				Title:      book.Title,
+				Code:       CartProblemInsufficientStock,
+				Message:    fmt.Sprintf("Only %d left in stock", book.Stock),
+				Requested:  item.Quantity,
+				Available:  book.Stock,
+			})
+		}
+
+		if book.Price != item.Price {
+			problems = append(problems, CartLineProblem{
+				CartItemID: item.ID,
+				BookID:     book.ID,
+				Title:      book.Title,
+				Code:       CartProblemPriceChanged,
+				Message:    "Price has changed since the book was added to cart",
+				OldPrice:   item.Price,
+				NewPrice:   book.Price,
+			})
+			// Cập nhật giá mới để lần checkout sau khách hàng đã được thông báo
+			if err := cc.DB.Model(&models.CartItem{}).Where("id = ?", item.ID).Update("price", book.Price).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update cart price"})
+				return
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		c.JSON(http.StatusConflict, gin.H{
+			"success":  false,
+			"error":    "Some items in your cart need attention",
+			"problems": problems,
+		})
+		return
+	}
+
+	// Giữ hàng, tạo đơn và làm trống giỏ trong cùng một transaction
+	var order models.Order
+	err = cc.DB.Transaction(func(tx *gorm.DB) error {
+		quote, coupon, err := reserveAndQuote(tx, userID, cartOrderItemInputs(cart), input.CouponCode)
+		if err != nil {
+			return err
+		}
+
+		order = models.Order{
+			UserID:        userID,
+			Status:        models.OrderStatusPending,
+			OrderItems:    quote.OrderItems(),
+			PaymentMethod: input.PaymentMethod,
+		}
+		quote.ApplyTo(&order)
+		if err := tx.Create(&order).Error; err != nil {
+			return err
+		}
+		if coupon != nil {
+			if err := models.RedeemCoupon(tx, coupon, userID, order.ID, quote.CouponDiscount); err != nil {
+				return err
+			}
+		}
+		if err := models.RecordOrderStatus(tx, order.ID, "", models.OrderStatusPending, &userID, "Order created from cart"); err != nil {
+			return err
+		}
+		return tx.Where("cart_id = ?", cart.ID).Delete(&models.CartItem{}).Error
+	})
+
+	var stockErr *models.InsufficientStockError
+	if errors.As(err, &stockErr) {
+		// Tồn kho thay đổi giữa lúc kiểm tra và lúc giữ hàng
+		var cartItemID uint
+		for _, item := range cart.CartItems {
+			if item.BookID == stockErr.BookID {
+				cartItemID = item.ID
+			}
+		}
+		c.JSON(http.StatusConflict, gin.H{
+			"success": false,
+			"error":   "Some items in your cart need attention",
+			"problems": []CartLineProblem{{
+				CartItemID: cartItemID,
+				BookID:     stockErr.BookID,
+				Title:      stockErr.Title,
+				Code:       CartProblemInsufficientStock,
+				Message:    fmt.Sprintf("Only %d left in stock", stockErr.Available),
+				Requested:  stockErr.Requested,
+				Available:  stockErr.Available,
+			}},
+		})
+		return
+	}
+	if err != nil {
+		respondOrderError(c, err, "Failed to create order")
+		return
+	}
+
+	if err := cc.DB.
+		Preload("User").
+		Preload("OrderItems.Book").
+		First(&order, order.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch created order"})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"success": true, "data": order})
+}
+
+// cartOrderItemInputs chuyển các dòng trong giỏ thành input đặt hàng
+func cartOrderItemInputs(cart *models.Cart) []OrderItemInput {
+	items := make([]OrderItemInput, 0, len(cart.CartItems))
+	for _, item := range cart.CartItems {
+		items = append(items, OrderItemInput{BookID: item.BookID, Quantity: item.Quantity})
+	}
+	return items
+}
@@ -2,9 +2,11 @@ package controllers
 
 import (
 	"net/http"
+	"strings"
 
 	"github.com/Poloni84Learning/ebook-store/config"
 	"github.com/Poloni84Learning/ebook-store/models"
+	"github.com/Poloni84Learning/ebook-store/utils"
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
@@ -22,7 +24,14 @@ func NewComboController(db *gorm.DB, cfg *config.Config) *ComboController {
 func (cc *ComboController) GetCombos(c *gin.Context) {
 	var combos []models.BookCombo
 
-	if err := cc.DB.
+	query := cc.DB
+	// Tìm theo tiêu đề/mô tả combo, không phân biệt dấu
+	if q := strings.TrimSpace(c.Query("q")); q != "" {
+		pattern := utils.FoldedContains(q)
+		query = query.Where("fold_text(title) LIKE ? OR fold_text(description) LIKE ?", pattern, pattern)
+	}
+
+	if err := query.
 		Preload("User").
 		Preload("ComboItems", "is_hidden = ?", false). // Chỉ lấy items không bị ẩn
 		Preload("ComboItems.Book").
@@ -56,9 +65,11 @@ func (cc *ComboController) CreateCombo(c *gin.Context) {
 	userID := c.GetUint("userID")
 
 	var input struct {
-		Title       string `json:"title" binding:"required"`
-		Description string `json:"description" binding:"required"`
-		BookIDs     []uint `json:"book_ids" binding:"required,min=1"`
+		Title           string   `json:"title" binding:"required"`
+		Description     string   `json:"description" binding:"required"`
+		BookIDs         []uint   `json:"book_ids" binding:"required,min=1"`
+		BundlePrice     *float64 `json:"bundle_price" binding:"omitempty,gt=0"`
+		DiscountPercent float64  `json:"discount_percent" binding:"gte=0,lte=100"`
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -77,9 +88,11 @@ func (cc *ComboController) CreateCombo(c *gin.Context) {
 	var combo models.BookCombo
 	err := cc.DB.Transaction(func(tx *gorm.DB) error {
 		combo = models.BookCombo{
-			Title:       input.Title,
-			Description: input.Description,
-			CreatedBy:   userID,
+			Title:           input.Title,
+			Description:     input.Description,
+			BundlePrice:     input.BundlePrice,
+			DiscountPercent: input.DiscountPercent,
+			CreatedBy:       userID,
 		}
 
 		if err := tx.Create(&combo).Error; err != nil {
@@ -126,9 +139,11 @@ func (cc *ComboController) UpdateCombo(c *gin.Context) {
 	userID := c.GetUint("userID")
 
 	var input struct {
-		Title       string `json:"title"`
-		Description string `json:"description"`
-		BookIDs     []uint `json:"book_ids"`
+		Title           string   `json:"title"`
+		Description     string   `json:"description"`
+		BookIDs         []uint   `json:"book_ids"`
+		BundlePrice     *float64 `json:"bundle_price" binding:"omitempty,gte=0"` // 0 = bỏ giá trọn gói
+		DiscountPercent *float64 `json:"discount_percent" binding:"omitempty,gte=0,lte=100"`
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -158,6 +173,16 @@ func (cc *ComboController) UpdateCombo(c *gin.Context) {
 		if input.Description != "" {
 			combo.Description = input.Description
 		}
+		if input.BundlePrice != nil {
+			if *input.BundlePrice == 0 {
+				combo.BundlePrice = nil
+			} else {
+				combo.BundlePrice = input.BundlePrice
+			}
+		}
+		if input.DiscountPercent != nil {
+			combo.DiscountPercent = *input.DiscountPercent
+		}
 
 		if err := tx.Save(&combo).Error; err != nil {
 			return err
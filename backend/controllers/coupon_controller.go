@@ -0,0 +1,209 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Poloni84Learning/ebook-store/config"
+	"github.com/Poloni84Learning/ebook-store/models"
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+)
+
+type CouponController struct {
+	DB     *gorm.DB
+	Config *config.Config
+}
+
+type CouponInput struct {
+	Code           string     `json:"code" binding:"required,min=3,max=50"`
+	Description    string     `json:"description"`
+	Type           string     `json:"type" binding:"required,oneof=percent fixed"`
+	Value          float64    `json:"value" binding:"required,gt=0"`
+	MinOrderAmount float64    `json:"min_order_amount" binding:"gte=0"`
+	StartsAt       *time.Time `json:"starts_at"`
+	ExpiresAt      *time.Time `json:"expires_at"`
+	UsageLimit     int        `json:"usage_limit" binding:"gte=0"`
+	PerUserLimit   int        `json:"per_user_limit" binding:"gte=0"`
+	IsActive       *bool      `json:"is_active"`
+	Categories     []string   `json:"categories"`
+	BookIDs        []int64    `json:"book_ids"`
+	ComboIDs       []int64    `json:"combo_ids"`
+}
+
+func NewCouponController(db *gorm.DB, cfg *config.Config) *CouponController {
+	return &CouponController{DB: db, Config: cfg}
+}
+
+// GetCoupons - Danh sách coupon (lọc theo ?active=true/false)
+func (cc *CouponController) GetCoupons(c *gin.Context) {
+	query := cc.DB.Model(&models.Coupon{})
+	switch c.Query("active") {
+	case "true":
+		query = query.Where("is_active = ?", true)
+	case "false":
+		query = query.Where("is_active = ?", false)
+	}
+
+	var coupons []models.Coupon
+	if err := query.Order("created_at DESC").Find(&coupons).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch coupons"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": coupons})
+}
+
+// GetCoupon - Chi tiết coupon
+func (cc *CouponController) GetCoupon(c *gin.Context) {
+	var coupon models.Coupon
+	if err := cc.DB.First(&coupon, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Coupon not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": coupon})
+}
+
+// CreateCoupon - Tạo coupon mới
+func (cc *CouponController) CreateCoupon(c *gin.Context) {
+	var input CouponInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if msg := validateCouponInput(&input); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	var existing int64
+	cc.DB.Unscoped().Model(&models.Coupon{}).Where("code = ?", models.NormalizeCouponCode(input.Code)).Count(&existing)
+	if existing > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Coupon code already exists"})
+		return
+	}
+
+	coupon := models.Coupon{IsActive: true}
+	applyCouponInput(&coupon, &input)
+	if err := cc.DB.Create(&coupon).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create coupon"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"success": true, "data": coupon})
+}
+
+// UpdateCoupon - Cập nhật coupon (không đổi số lượt đã dùng)
+func (cc *CouponController) UpdateCoupon(c *gin.Context) {
+	var input CouponInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if msg := validateCouponInput(&input); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	var coupon models.Coupon
+	if err := cc.DB.First(&coupon, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Coupon not found"})
+		return
+	}
+
+	code := models.NormalizeCouponCode(input.Code)
+	if code != coupon.Code {
+		var existing int64
+		cc.DB.Unscoped().Model(&models.Coupon{}).Where("code = ? AND id <> ?", code, coupon.ID).Count(&existing)
+		if existing > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Coupon code already exists"})
+			return
+		}
+	}
+
+	applyCouponInput(&coupon, &input)
+	// Save để ghi cả giá trị rỗng (bỏ giới hạn, xóa ngày hết hạn...)
+	if err := cc.DB.Omit("used_count").Save(&coupon).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update coupon"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": coupon})
+}
+
+// DeleteCoupon - Xóa coupon (lịch sử sử dụng vẫn được giữ)
+func (cc *CouponController) DeleteCoupon(c *gin.Context) {
+	var coupon models.Coupon
+	if err := cc.DB.First(&coupon, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Coupon not found"})
+		return
+	}
+
+	if err := cc.DB.Delete(&coupon).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete coupon"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Coupon deleted successfully"})
+}
+
+// GetCouponRedemptions - Lịch sử sử dụng coupon
+func (cc *CouponController) GetCouponRedemptions(c *gin.Context) {
+	var coupon models.Coupon
+	if err := cc.DB.Unscoped().First(&coupon, c.Param("id")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Coupon not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch coupon"})
+		return
+	}
+
+	var redemptions []models.CouponRedemption
+	if err := cc.DB.Where("coupon_id = ?", coupon.ID).Order("created_at DESC").Find(&redemptions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch redemptions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    redemptions,
+		"coupon":  coupon,
+	})
+}
+
+// validateCouponInput kiểm tra các ràng buộc mà binding tag không diễn tả được
+func validateCouponInput(input *CouponInput) string {
+	if input.Type == string(models.CouponTypePercent) && input.Value > 100 {
+		return "Percent coupon value must not exceed 100"
+	}
+	if input.StartsAt != nil && input.ExpiresAt != nil && !input.ExpiresAt.After(*input.StartsAt) {
+		return "expires_at must be after starts_at"
+	}
+	if strings.ContainsAny(strings.TrimSpace(input.Code), " \t\n") {
+		return "Coupon code must not contain spaces"
+	}
+	return ""
+}
+
+func applyCouponInput(coupon *models.Coupon, input *CouponInput) {
+	coupon.Code = models.NormalizeCouponCode(input.Code)
+	coupon.Description = input.Description
+	coupon.Type = models.CouponType(input.Type)
+	coupon.Value = input.Value
+	coupon.MinOrderAmount = input.MinOrderAmount
+	coupon.StartsAt = input.StartsAt
+	coupon.ExpiresAt = input.ExpiresAt
+	coupon.UsageLimit = input.UsageLimit
+	coupon.PerUserLimit = input.PerUserLimit
+	if input.IsActive != nil {
+		coupon.IsActive = *input.IsActive
+	}
+	coupon.Categories = pq.StringArray(input.Categories)
+	coupon.BookIDs = pq.Int64Array(input.BookIDs)
+	coupon.ComboIDs = pq.Int64Array(input.ComboIDs)
+}
@@ -0,0 +1,75 @@
+package controllers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/Poloni84Learning/ebook-store/config"
+	"github.com/Poloni84Learning/ebook-store/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type LibraryController struct {
+	DB     *gorm.DB
+	Config *config.Config
+}
+
+type LibraryBookResponse struct {
+	BookID      uint                `json:"book_id"`
+	Title       string              `json:"title"`
+	Author      string              `json:"author"`
+	Category    models.BookCategory `json:"category"`
+	CoverImage  string              `json:"cover_image"`
+	CoverImages map[string]string   `json:"cover_images,omitempty"`
+	OrderID     *uint               `json:"order_id,omitempty"`
+	GrantedAt   time.Time           `json:"granted_at"`
+	Available   bool                `json:"available"` // false nếu sách đã ngừng bán (không tải được nữa)
+	Formats     []models.BookFormat `json:"formats"`   // Các định dạng có thể tải
+}
+
+func NewLibraryController(db *gorm.DB, cfg *config.Config) *LibraryController {
+	return &LibraryController{DB: db, Config: cfg}
+}
+
+// GetLibrary - Danh sách ebook user đã sở hữu
+func (lc *LibraryController) GetLibrary(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	var entitlements []models.LibraryEntitlement
+	if err := lc.DB.
+		Preload("Book", func(db *gorm.DB) *gorm.DB { return db.Unscoped() }).
+		Where("user_id = ?", userID).
+		Order("granted_at DESC").
+		Find(&entitlements).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get library"})
+		return
+	}
+
+	books := make([]LibraryBookResponse, 0, len(entitlements))
+	for _, e := range entitlements {
+		formats, err := models.AvailableFormats(lc.DB, &e.Book)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get library"})
+			return
+		}
+		books = append(books, LibraryBookResponse{
+			BookID:      e.BookID,
+			Title:       e.Book.Title,
+			Author:      e.Book.Author,
+			Category:    e.Book.Category,
+			CoverImage:  e.Book.CoverImage,
+			CoverImages: e.Book.CoverImages,
+			OrderID:     e.OrderID,
+			GrantedAt:   e.GrantedAt,
+			Available:   !e.Book.DeletedAt.Valid,
+			Formats:     formats,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    books,
+		"total":   len(books),
+	})
+}
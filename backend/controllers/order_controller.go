@@ -1,14 +1,18 @@
 package controllers
 
 import (
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
 	"time"
 
 	"github.com/Poloni84Learning/ebook-store/config"
 	"github.com/Poloni84Learning/ebook-store/models"
+	"github.com/Poloni84Learning/ebook-store/pricing"
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type OrderController struct {
@@ -19,22 +23,37 @@ type OrderController struct {
 type OrderInput struct {
 	OrderItems    []OrderItemInput `json:"order_items" binding:"required,min=1"`
 	PaymentMethod string           `json:"payment_method" binding:"required,oneof=Card COD BankTransfer"`
+	CouponCode    string           `json:"coupon_code" binding:"max=50"`
 }
 
+// OrderItemInput là một dòng đặt hàng: một cuốn sách hoặc một combo (không cả hai)
 type OrderItemInput struct {
-	BookID   uint `json:"book_id" binding:"required"`
+	BookID   uint `json:"book_id" binding:"required_without=ComboID,excluded_with=ComboID"`
+	ComboID  uint `json:"combo_id" binding:"required_without=BookID"`
 	Quantity int  `json:"quantity" binding:"required,min=1"`
 }
 
 type UserOrderUpdateInput struct {
 	OrderItems    []OrderItemInput `json:"order_items" binding:"required,min=1"`
 	PaymentMethod string           `json:"payment_method" binding:"required,oneof=Card COD BankTransfer"`
+	CouponCode    string           `json:"coupon_code" binding:"max=50"`
+}
+
+type QuoteInput struct {
+	OrderItems []OrderItemInput `json:"order_items" binding:"required,min=1"`
+	CouponCode string           `json:"coupon_code" binding:"max=50"`
 }
 
 type StaffOrderUpdateInput struct {
 	Status string `json:"status" binding:"required,oneof=pending processing completed canceled"`
+	Reason string `json:"reason" binding:"max=500"`
 }
 
+var (
+	errOrderNotPending = errors.New("only pending orders can be updated")
+	errOrderHasPayment = errors.New("order already has a payment")
+)
+
 func NewOrderController(db *gorm.DB, cfg *config.Config) *OrderController {
 	go monitorOrders(db)
 	return &OrderController{DB: db, Config: cfg}
@@ -50,40 +69,33 @@ func (oc *OrderController) CreateOrder(c *gin.Context) {
 		return
 	}
 
-	// Tính toán tổng giá và kiểm tra kho
-	var total float64
-	var orderItems []models.OrderItem
-
-	for _, item := range input.OrderItems {
-		var book models.Book
-		if err := oc.DB.First(&book, item.BookID).Error; err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Book not found"})
-			return
+	// Giữ hàng và tạo đơn trong cùng một transaction
+	var order models.Order
+	err := oc.DB.Transaction(func(tx *gorm.DB) error {
+		quote, coupon, err := reserveAndQuote(tx, userID, input.OrderItems, input.CouponCode)
+		if err != nil {
+			return err
 		}
 
-		if book.Stock < item.Quantity {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Not enough stock for book " + book.Title})
-			return
+		order = models.Order{
+			UserID:        userID,
+			Status:        models.OrderStatusPending,
+			OrderItems:    quote.OrderItems(),
+			PaymentMethod: input.PaymentMethod,
 		}
-
-		total += book.Price * float64(item.Quantity)
-		orderItems = append(orderItems, models.OrderItem{
-			BookID:   book.ID,
-			Quantity: item.Quantity,
-			Price:    book.Price,
-		})
-	}
-
-	order := models.Order{
-		UserID:        userID,
-		TotalAmount:   total,
-		Status:        "pending",
-		OrderItems:    orderItems,
-		PaymentMethod: input.PaymentMethod,
-	}
-
-	if err := oc.DB.Create(&order).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create order"})
+		quote.ApplyTo(&order)
+		if err := tx.Create(&order).Error; err != nil {
+			return err
+		}
+		if coupon != nil {
+			if err := models.RedeemCoupon(tx, coupon, userID, order.ID, quote.CouponDiscount); err != nil {
+				return err
+			}
+		}
+		return models.RecordOrderStatus(tx, order.ID, "", models.OrderStatusPending, &userID, "Order created")
+	})
+	if err != nil {
+		respondOrderError(c, err, "Failed to create order")
 		return
 	}
 
@@ -109,7 +121,7 @@ func (oc *OrderController) UserUpdateOrder(c *gin.Context) {
 	}
 
 	var order models.Order
-	if err := oc.DB.Preload("OrderItems").First(&order, orderID).Error; err != nil {
+	if err := oc.DB.First(&order, orderID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
 		return
 	}
@@ -120,46 +132,83 @@ func (oc *OrderController) UserUpdateOrder(c *gin.Context) {
 		return
 	}
 
-	// Chỉ cho phép cập nhật khi đơn ở trạng thái pending
-	if order.Status != "pending" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Only pending orders can be updated"})
-		return
-	}
+	err := oc.DB.Transaction(func(tx *gorm.DB) error {
+		// Khóa đơn hàng để tránh cập nhật đồng thời với staff/auto-cancel
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, order.ID).Error; err != nil {
+			return err
+		}
 
-	// Xóa items cũ và thêm items mới
-	oc.DB.Where("order_id = ?", order.ID).Delete(&models.OrderItem{})
+		// Chỉ cho phép cập nhật khi đơn ở trạng thái pending
+		if order.Status != models.OrderStatusPending {
+			return errOrderNotPending
+		}
 
-	var newItems []models.OrderItem
-	var newTotal float64
+		// Số tiền của payment cố định khi tạo: không cho sửa đơn đã có payment đang chờ hoặc đã thu,
+		// tránh trường hợp trả tiền cho đơn nhỏ rồi sửa thành đơn lớn hơn
+		var activePayments int64
+		if err := tx.Model(&models.Payment{}).
+			Where("order_id = ? AND status IN ?", order.ID, []models.PaymentStatus{models.PaymentStatusPending, models.PaymentStatusSucceeded}).
+			Count(&activePayments).Error; err != nil {
+			return err
+		}
+		if activePayments > 0 {
+			return errOrderHasPayment
+		}
 
-	for _, item := range input.OrderItems {
-		var book models.Book
-		if err := oc.DB.First(&book, item.BookID).Error; err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Book not found"})
-			return
+		// Hoàn trả tồn kho và lượt dùng coupon của items cũ rồi xóa
+		if err := models.ReleaseOrderStock(tx, order.ID); err != nil {
+			return err
+		}
+		if err := models.ReleaseCouponRedemption(tx, order.ID); err != nil {
+			return err
+		}
+		if err := tx.Where("order_id = ?", order.ID).Delete(&models.OrderItem{}).Error; err != nil {
+			return err
 		}
 
-		newItems = append(newItems, models.OrderItem{
-			OrderID:  order.ID,
-			BookID:   book.ID,
-			Quantity: item.Quantity,
-			Price:    book.Price,
-		})
-		newTotal += book.Price * float64(item.Quantity)
-	}
+		// Giữ hàng và tính giá cho items mới
+		quote, coupon, err := reserveAndQuote(tx, userID, input.OrderItems, input.CouponCode)
+		if err != nil {
+			return err
+		}
 
-	if err := oc.DB.Create(&newItems).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update order items"})
-		return
-	}
+		newItems := quote.OrderItems()
+		for i := range newItems {
+			newItems[i].OrderID = order.ID
+		}
+		if err := tx.Create(&newItems).Error; err != nil {
+			return err
+		}
 
-	// Cập nhật thông tin đơn hàng
-	order.OrderItems = newItems
-	order.TotalAmount = newTotal
-	order.PaymentMethod = input.PaymentMethod
+		// Cập nhật thông tin đơn hàng
+		quote.ApplyTo(&order)
+		if err := tx.Model(&order).Updates(map[string]interface{}{
+			"subtotal":        order.Subtotal,
+			"discount_amount": order.DiscountAmount,
+			"shipping_fee":    order.ShippingFee,
+			"total_amount":    order.TotalAmount,
+			"coupon_id":       order.CouponID,
+			"coupon_code":     order.CouponCode,
+			"payment_method":  input.PaymentMethod,
+		}).Error; err != nil {
+			return err
+		}
 
-	if err := oc.DB.Session(&gorm.Session{FullSaveAssociations: true}).Save(&order).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update order"})
+		if coupon != nil {
+			return models.RedeemCoupon(tx, coupon, userID, order.ID, quote.CouponDiscount)
+		}
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, errOrderNotPending) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Only pending orders can be updated"})
+			return
+		}
+		if errors.Is(err, errOrderHasPayment) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Order already has a payment and can no longer be updated"})
+			return
+		}
+		respondOrderError(c, err, "Failed to update order")
 		return
 	}
 
@@ -192,16 +241,70 @@ func (oc *OrderController) StaffUpdateOrder(c *gin.Context) {
 		return
 	}
 
-	// Cập nhật trạng thái
-	order.Status = input.Status
-	if err := oc.DB.Save(&order).Error; err != nil {
+	// Chuyển trạng thái qua state machine (hoàn trả tồn kho khi hủy, ghi lịch sử)
+	staffID := c.GetUint("userID")
+	err := oc.DB.Transaction(func(tx *gorm.DB) error {
+		_, err := models.TransitionOrder(tx, order.ID, models.OrderTransition{
+			To:        models.OrderStatus(input.Status),
+			ChangedBy: &staffID,
+			Reason:    input.Reason,
+		})
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidOrderTransition) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":               err.Error(),
+				"allowed_transitions": order.Status.AllowedTransitions(),
+			})
+			return
+		}
+		if errors.Is(err, models.ErrPaymentNotConfirmed) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Order cannot be processed before payment is confirmed"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update order status"})
 		return
 	}
+	order.Status = models.OrderStatus(input.Status)
 
 	c.JSON(http.StatusOK, order)
 }
 
+// GetOrderHistory - Lấy lịch sử chuyển trạng thái của đơn hàng
+func (oc *OrderController) GetOrderHistory(c *gin.Context) {
+	userID := c.GetUint("userID")
+	orderID := c.Param("id")
+
+	var order models.Order
+	query := oc.DB.Where("id = ?", orderID)
+	if c.GetString("role") == string(models.RoleCustomer) {
+		query = query.Where("user_id = ?", userID)
+	}
+	if err := query.First(&order).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+
+	var history []models.OrderStatusHistory
+	if err := oc.DB.
+		Preload("ChangedByUser").
+		Where("order_id = ?", order.ID).
+		Order("created_at ASC, id ASC").
+		Find(&history).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get order history"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":             true,
+		"order_id":            order.ID,
+		"status":              order.Status,
+		"allowed_transitions": order.Status.AllowedTransitions(),
+		"data":                history,
+	})
+}
+
 // GetUserOrders lấy danh sách đơn hàng của user hiện tại
 func (oc *OrderController) GetUserOrders(c *gin.Context) {
 	userID := c.GetUint("userID")
@@ -405,6 +508,167 @@ func (oc *OrderController) GetOrderStats(c *gin.Context) {
 	})
 }
 
+// QuoteOrder - Xem trước bảng giá (giảm giá, coupon, phí ship) trước khi đặt hàng
+func (oc *OrderController) QuoteOrder(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	var input QuoteInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	combos, err := loadOrderCombos(oc.DB, input.OrderItems)
+	if err != nil {
+		respondOrderError(c, err, "Failed to calculate quote")
+		return
+	}
+
+	books := make(map[uint]models.Book)
+	for bookID := range orderInputQuantities(input.OrderItems, combos) {
+		var book models.Book
+		if err := oc.DB.First(&book, bookID).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Book not found", "book_id": bookID})
+			return
+		}
+		books[bookID] = book
+	}
+
+	var coupon *models.Coupon
+	if input.CouponCode != "" {
+		if coupon, err = models.FindCoupon(oc.DB, input.CouponCode, userID); err != nil {
+			respondOrderError(c, err, "Failed to calculate quote")
+			return
+		}
+	}
+
+	quote, err := quoteOrderItems(oc.DB, input.OrderItems, books, combos, coupon)
+	if err != nil {
+		respondOrderError(c, err, "Failed to calculate quote")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": quote})
+}
+
+// reserveAndQuote giữ hàng, khóa coupon (nếu có) và tính giá; phải gọi trong transaction
+func reserveAndQuote(tx *gorm.DB, userID uint, items []OrderItemInput, couponCode string) (*pricing.Quote, *models.Coupon, error) {
+	combos, err := loadOrderCombos(tx, items)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	books, err := models.ReserveStock(tx, orderInputQuantities(items, combos))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var coupon *models.Coupon
+	if couponCode != "" {
+		if coupon, err = models.LockCoupon(tx, couponCode, userID); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	quote, err := quoteOrderItems(tx, items, books, combos, coupon)
+	if err != nil {
+		return nil, nil, err
+	}
+	return quote, coupon, nil
+}
+
+// quoteOrderItems tính giá các dòng theo giá sách hiện tại, giá combo, SystemConfig và coupon
+func quoteOrderItems(db *gorm.DB, items []OrderItemInput, books map[uint]models.Book, combos map[uint]*models.BookCombo, coupon *models.Coupon) (*pricing.Quote, error) {
+	engine, err := pricing.LoadEngine(db)
+	if err != nil {
+		return nil, err
+	}
+
+	if coupon != nil {
+		rule, err := pricing.NewCouponRule(db, coupon)
+		if err != nil {
+			return nil, err
+		}
+		engine.WithCoupon(rule)
+	}
+
+	lines := make([]pricing.Line, 0, len(items))
+	for _, item := range items {
+		if item.ComboID != 0 {
+			lines = append(lines, pricing.BundleLines(combos[item.ComboID], books, item.Quantity)...)
+			continue
+		}
+
+		book := books[item.BookID]
+		lines = append(lines, pricing.Line{
+			BookID:    item.BookID,
+			Quantity:  item.Quantity,
+			UnitPrice: book.Price,
+			Category:  book.Category,
+		})
+	}
+	return engine.Quote(lines)
+}
+
+// loadOrderCombos nạp các combo được đặt trong input và kiểm tra còn bán được
+func loadOrderCombos(db *gorm.DB, items []OrderItemInput) (map[uint]*models.BookCombo, error) {
+	combos := make(map[uint]*models.BookCombo)
+	for _, item := range items {
+		if item.ComboID == 0 || combos[item.ComboID] != nil {
+			continue
+		}
+		combo, err := models.LoadPurchasableCombo(db, item.ComboID)
+		if err != nil {
+			return nil, err
+		}
+		combos[item.ComboID] = combo
+	}
+	return combos, nil
+}
+
+// orderInputQuantities gộp số lượng theo từng sách từ input (combo được tách thành từng sách)
+func orderInputQuantities(items []OrderItemInput, combos map[uint]*models.BookCombo) map[uint]int {
+	quantities := make(map[uint]int)
+	for _, item := range items {
+		if item.ComboID != 0 {
+			for _, comboItem := range combos[item.ComboID].ComboItems {
+				quantities[comboItem.BookID] += item.Quantity
+			}
+			continue
+		}
+		quantities[item.BookID] += item.Quantity
+	}
+	return quantities
+}
+
+// respondOrderError trả về lỗi phù hợp khi giữ hàng hoặc áp coupon thất bại
+func respondOrderError(c *gin.Context, err error, fallback string) {
+	var stockErr *models.InsufficientStockError
+	var couponErr *models.CouponError
+	var comboErr *models.ComboUnavailableError
+	switch {
+	case errors.As(err, &comboErr):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":    "Combo is not available for purchase",
+			"combo_id": comboErr.ComboID,
+			"details":  comboErr.Reason,
+		})
+	case errors.As(err, &couponErr):
+		c.JSON(http.StatusBadRequest, gin.H{"error": couponErr.Message, "code": couponErr.Code})
+	case errors.As(err, &stockErr):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":     "Not enough stock for book " + stockErr.Title,
+			"book_id":   stockErr.BookID,
+			"requested": stockErr.Requested,
+			"available": stockErr.Available,
+		})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Book not found"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
+
 // getStartTime - helper tính mốc thời gian bắt đầu
 func getStartTime(timeRange string) (time.Time, error) {
 	now := time.Now()
@@ -425,10 +689,27 @@ func monitorOrders(db *gorm.DB) {
 	for {
 		time.Sleep(1 * time.Hour)
 
-		// Hủy đơn chưa thanh toán sau 24h
-		db.Model(&models.Order{}).
+		// Hủy đơn chưa thanh toán sau 24h và hoàn trả tồn kho
+		var expiredIDs []uint
+		if err := db.Model(&models.Order{}).
 			Where("status = 'pending' AND created_at < ?", time.Now().Add(-24*time.Hour)).
-			Update("status", "canceled")
+			Pluck("id", &expiredIDs).Error; err != nil {
+			log.Printf("[ERROR] Lỗi lấy đơn hàng quá hạn: %v", err)
+		}
+
+		for _, orderID := range expiredIDs {
+			err := db.Transaction(func(tx *gorm.DB) error {
+				_, err := models.TransitionOrder(tx, orderID, models.OrderTransition{
+					To:           models.OrderStatusCanceled,
+					Reason:       "Tự động hủy: đơn chưa thanh toán sau 24h",
+					ExpectedFrom: models.OrderStatusPending,
+				})
+				return err
+			})
+			if err != nil && !errors.Is(err, models.ErrInvalidOrderTransition) {
+				log.Printf("[ERROR] Lỗi hủy đơn hàng %d: %v", orderID, err)
+			}
+		}
 
 		// Xóa đơn đã hủy sau 48h
 		db.Where("status = 'canceled' AND updated_at < ?", time.Now().Add(-48*time.Hour)).
@@ -0,0 +1,393 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/Poloni84Learning/ebook-store/config"
+	"github.com/Poloni84Learning/ebook-store/models"
+	"github.com/Poloni84Learning/ebook-store/payments"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	errOrderNotPayable    = errors.New("only pending orders can be paid")
+	errOrderPaidOnDeliver = errors.New("COD orders are paid on delivery")
+	errOrderAlreadyPaid   = errors.New("order has already been paid")
+	errPaymentInProgress  = errors.New("order already has a pending payment")
+	errCreateIntent       = errors.New("failed to create payment intent")
+)
+
+type PaymentController struct {
+	DB       *gorm.DB
+	Config   *config.Config
+	Provider payments.PaymentProvider
+}
+
+type RefundInput struct {
+	Amount float64 `json:"amount" binding:"gte=0"` // Bỏ trống hoặc bằng số tiền của payment (không hỗ trợ hoàn một phần)
+	Reason string  `json:"reason" binding:"max=500"`
+}
+
+func NewPaymentController(db *gorm.DB, cfg *config.Config) *PaymentController {
+	provider, err := payments.NewProvider(cfg)
+	if err != nil {
+		log.Fatalf("Failed to init payment provider: %v", err)
+	}
+
+	pc := &PaymentController{DB: db, Config: cfg, Provider: provider}
+
+	// Mock provider gửi webhook ngay trong process, đi qua cùng luồng xác thực chữ ký
+	if mock, ok := provider.(*payments.MockProvider); ok {
+		mock.SetWebhookHandler(pc.handleWebhook)
+	}
+	return pc
+}
+
+// CreatePayment - Tạo yêu cầu thanh toán cho đơn hàng. Mỗi đơn chỉ có một payment đang chờ hoặc đã thu:
+// đơn đã có payment pending trả về 409 kèm payment đó để client xác nhận tiếp thay vì tạo intent mới.
+func (pc *PaymentController) CreatePayment(c *gin.Context) {
+	userID := c.GetUint("userID")
+	orderID := c.Param("id")
+
+	var order models.Order
+	if err := pc.DB.Where("id = ? AND user_id = ?", orderID, userID).First(&order).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+
+	var payment, existing models.Payment
+	var clientSecret string
+	err := pc.DB.Transaction(func(tx *gorm.DB) error {
+		// Khóa đơn để hai request đồng thời không cùng tạo payment (khách bị thu tiền hai lần)
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, order.ID).Error; err != nil {
+			return err
+		}
+		if order.Status != models.OrderStatusPending {
+			return errOrderNotPayable
+		}
+		if !order.RequiresPrepayment() {
+			return errOrderPaidOnDeliver
+		}
+
+		err := tx.Where("order_id = ? AND status IN ?", order.ID, []models.PaymentStatus{models.PaymentStatusPending, models.PaymentStatusSucceeded}).
+			Order("created_at DESC").
+			First(&existing).Error
+		switch {
+		case err == nil && existing.Status == models.PaymentStatusSucceeded:
+			return errOrderAlreadyPaid
+		case err == nil:
+			return errPaymentInProgress
+		case !errors.Is(err, gorm.ErrRecordNotFound):
+			return err
+		}
+
+		intent, err := pc.Provider.CreateIntent(c.Request.Context(), order.TotalAmount, pc.Config.Currency, map[string]string{
+			"order_id": fmt.Sprintf("%d", order.ID),
+		})
+		if err != nil {
+			log.Printf("[Payment] Lỗi tạo payment intent cho đơn %d: %v", order.ID, err)
+			return fmt.Errorf("%w: %v", errCreateIntent, err)
+		}
+		clientSecret = intent.ClientSecret
+
+		payment = models.Payment{
+			OrderID:     order.ID,
+			Provider:    pc.Provider.Name(),
+			ProviderRef: intent.ID,
+			Amount:      intent.Amount,
+			Currency:    intent.Currency,
+			Status:      models.PaymentStatusPending,
+		}
+		return tx.Create(&payment).Error
+	})
+	switch {
+	case errors.Is(err, errOrderNotPayable):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only pending orders can be paid"})
+		return
+	case errors.Is(err, errOrderPaidOnDeliver):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "COD orders are paid on delivery"})
+		return
+	case errors.Is(err, errOrderAlreadyPaid):
+		c.JSON(http.StatusConflict, gin.H{"error": "Order has already been paid"})
+		return
+	case errors.Is(err, errPaymentInProgress):
+		c.JSON(http.StatusConflict, gin.H{"error": "Order already has a pending payment; confirm it instead", "data": existing})
+		return
+	case errors.Is(err, errCreateIntent):
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to create payment"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save payment"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success":       true,
+		"data":          payment,
+		"client_secret": clientSecret,
+	})
+}
+
+// ConfirmPayment - Xác nhận thanh toán (với mock provider: giả lập khách đã trả tiền)
+func (pc *PaymentController) ConfirmPayment(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	var payment models.Payment
+	if err := pc.DB.
+		Joins("JOIN orders ON orders.id = payments.order_id").
+		Where("payments.id = ? AND orders.user_id = ?", c.Param("id"), userID).
+		First(&payment).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Payment not found"})
+		return
+	}
+
+	if payment.Status != models.PaymentStatusPending {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Payment is not pending"})
+		return
+	}
+
+	// Đơn đã bị hủy (ví dụ tự hủy do quá hạn) thì không thu tiền nữa
+	var order models.Order
+	if err := pc.DB.First(&order, payment.OrderID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch order"})
+		return
+	}
+	if order.Status != models.OrderStatusPending {
+		c.JSON(http.StatusConflict, gin.H{"error": "Order is no longer pending and cannot be paid"})
+		return
+	}
+
+	if _, err := pc.Provider.Confirm(c.Request.Context(), payment.ProviderRef); err != nil {
+		log.Printf("[Payment] Lỗi xác nhận payment %d: %v", payment.ID, err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to confirm payment", "details": err.Error()})
+		return
+	}
+
+	// Trạng thái được cập nhật qua webhook, load lại để trả về
+	if err := pc.DB.First(&payment, payment.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch payment"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": payment})
+}
+
+// RefundPayment - Hoàn tiền (dành cho staff/admin)
+func (pc *PaymentController) RefundPayment(c *gin.Context) {
+	var input RefundInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var payment models.Payment
+	if err := pc.DB.First(&payment, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Payment not found"})
+		return
+	}
+
+	if payment.Status != models.PaymentStatusSucceeded {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only succeeded payments can be refunded"})
+		return
+	}
+
+	// Hoàn tiền luôn hủy đơn và thu hồi quyền tải sách nên chỉ hỗ trợ hoàn toàn bộ số tiền
+	if input.Amount > 0 && !sameAmount(input.Amount, payment.Amount) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Partial refunds are not supported; omit amount to refund the full payment"})
+		return
+	}
+
+	if _, err := pc.Provider.Refund(c.Request.Context(), payment.ProviderRef, payment.Amount); err != nil {
+		log.Printf("[Payment] Lỗi hoàn tiền payment %d: %v", payment.ID, err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to refund payment", "details": err.Error()})
+		return
+	}
+
+	// Hủy đơn nếu đơn chưa hoàn tất
+	staffID := c.GetUint("userID")
+	reason := input.Reason
+	if reason == "" {
+		reason = "Payment refunded"
+	}
+	err := pc.DB.Transaction(func(tx *gorm.DB) error {
+		_, err := models.TransitionOrder(tx, payment.OrderID, models.OrderTransition{
+			To:        models.OrderStatusCanceled,
+			ChangedBy: &staffID,
+			Reason:    reason,
+		})
+		if err != nil && !errors.Is(err, models.ErrInvalidOrderTransition) {
+			return err
+		}
+		// Đơn đã hoàn tất không hủy được nhưng vẫn phải thu hồi quyền tải sách
+		return models.RevokeOrderEntitlements(tx, payment.OrderID)
+	})
+	if err != nil {
+		log.Printf("[Payment] Lỗi hủy đơn %d sau khi hoàn tiền: %v", payment.OrderID, err)
+	}
+
+	if err := pc.DB.First(&payment, payment.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch payment"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": payment})
+}
+
+// GetOrderPayments - Danh sách thanh toán của đơn hàng
+func (pc *PaymentController) GetOrderPayments(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	query := pc.DB.Where("id = ?", c.Param("id"))
+	if c.GetString("role") == string(models.RoleCustomer) {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	var order models.Order
+	if err := query.First(&order).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+
+	var list []models.Payment
+	if err := pc.DB.Where("order_id = ?", order.ID).Order("created_at DESC").Find(&list).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get payments"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": list})
+}
+
+// sameAmount so sánh hai số tiền tới đơn vị nhỏ nhất (2 chữ số thập phân như cột decimal(10,2))
+func sameAmount(a, b float64) bool {
+	return math.Abs(a-b) < 0.005
+}
+
+// Webhook - Nhận webhook từ cổng thanh toán (public, xác thực bằng chữ ký)
+func (pc *PaymentController) Webhook(c *gin.Context) {
+	if c.Param("provider") != pc.Provider.Name() {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown payment provider"})
+		return
+	}
+
+	payload, err := io.ReadAll(io.LimitReader(c.Request.Body, 1<<20))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read payload"})
+		return
+	}
+
+	if err := pc.handleWebhook(payload, c.GetHeader(payments.SignatureHeader)); err != nil {
+		if errors.Is(err, payments.ErrInvalidSignature) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid signature"})
+			return
+		}
+		log.Printf("[Payment] Lỗi xử lý webhook: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process webhook"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"received": true})
+}
+
+// handleWebhook xác thực chữ ký và áp dụng sự kiện vào Payment/Order.
+// Tiền đã thu nhưng không áp dụng được cho đơn (đơn không còn pending hoặc lệch số tiền) được hoàn tự động.
+func (pc *PaymentController) handleWebhook(payload []byte, signature string) error {
+	event, err := pc.Provider.VerifyWebhook(payload, signature)
+	if err != nil {
+		return err
+	}
+
+	var orphan *models.Payment
+	err = pc.DB.Transaction(func(tx *gorm.DB) error {
+		var payment models.Payment
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("provider = ? AND provider_ref = ?", pc.Provider.Name(), event.IntentID).
+			First(&payment).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		switch event.Type {
+		case payments.EventPaymentSucceeded:
+			if payment.Status != models.PaymentStatusPending {
+				return nil // Sự kiện lặp lại
+			}
+			if err := tx.Model(&payment).Updates(map[string]interface{}{
+				"status":       models.PaymentStatusSucceeded,
+				"confirmed_at": &now,
+			}).Error; err != nil {
+				return err
+			}
+
+			// Chỉ xử lý đơn khi số tiền đã thu khớp với tổng tiền hiện tại của đơn
+			var order models.Order
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, payment.OrderID).Error; err != nil {
+				return err
+			}
+			if !sameAmount(event.Amount, payment.Amount) || !sameAmount(payment.Amount, order.TotalAmount) {
+				log.Printf("[Payment] Số tiền thanh toán %s (%.2f, payment %.2f) không khớp tổng đơn %d (%.2f), giữ đơn ở trạng thái chờ và hoàn tiền",
+					payment.ProviderRef, event.Amount, payment.Amount, order.ID, order.TotalAmount)
+				orphan = &payment
+				return nil
+			}
+
+			_, err := models.TransitionOrder(tx, payment.OrderID, models.OrderTransition{
+				To:           models.OrderStatusProcessing,
+				Reason:       "Payment confirmed: " + payment.ProviderRef,
+				ExpectedFrom: models.OrderStatusPending,
+			})
+			if errors.Is(err, models.ErrInvalidOrderTransition) {
+				log.Printf("[Payment] Đơn %d không còn ở trạng thái pending khi nhận thanh toán, hoàn tiền %s", payment.OrderID, payment.ProviderRef)
+				orphan = &payment
+				return nil
+			}
+			return err
+
+		case payments.EventPaymentFailed:
+			if payment.Status != models.PaymentStatusPending {
+				return nil
+			}
+			return tx.Model(&payment).Update("status", models.PaymentStatusFailed).Error
+
+		case payments.EventPaymentRefunded:
+			if payment.Status == models.PaymentStatusRefunded {
+				return nil
+			}
+			return tx.Model(&payment).Updates(map[string]interface{}{
+				"status":      models.PaymentStatusRefunded,
+				"refunded_at": &now,
+			}).Error
+
+		default:
+			log.Printf("[Payment] Bỏ qua sự kiện không hỗ trợ: %s", event.Type)
+			return nil
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	// Hoàn tiền sau khi commit: provider có thể gửi webhook refunded ngay (mock gửi in-process),
+	// webhook đó cần khóa lại dòng payment vừa cập nhật
+	if orphan != nil {
+		pc.refundOrphanPayment(orphan, event.Amount)
+	}
+	return nil
+}
+
+// refundOrphanPayment hoàn lại khoản đã thu nhưng không gắn được vào đơn; lỗi chỉ ghi log để staff
+// hoàn thủ công qua RefundPayment (payment vẫn ở trạng thái succeeded)
+func (pc *PaymentController) refundOrphanPayment(payment *models.Payment, amount float64) {
+	if _, err := pc.Provider.Refund(context.Background(), payment.ProviderRef, amount); err != nil {
+		log.Printf("[Payment] Không hoàn được tiền payment %d (%s) của đơn %d, cần hoàn thủ công: %v",
+			payment.ID, payment.ProviderRef, payment.OrderID, err)
+	}
+}
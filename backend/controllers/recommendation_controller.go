@@ -0,0 +1,50 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/Poloni84Learning/ebook-store/config"
+	"github.com/Poloni84Learning/ebook-store/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	recommendationDefaultLimit = 10
+	recommendationMaxLimit     = 50
+)
+
+type RecommendationController struct {
+	DB     *gorm.DB
+	Config *config.Config
+}
+
+func NewRecommendationController(db *gorm.DB, cfg *config.Config) *RecommendationController {
+	return &RecommendationController{DB: db, Config: cfg}
+}
+
+// GetRecommendations - Gợi ý sách cho user đang đăng nhập: "khách mua sách này cũng mua",
+// sách tương tự theo thể loại/keyword, và sách phổ biến khi chưa có lịch sử
+func (rc *RecommendationController) GetRecommendations(c *gin.Context) {
+	userID := c.GetUint("userID")
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(recommendationDefaultLimit)))
+	if err != nil || limit < 1 || limit > recommendationMaxLimit {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "limit phải từ 1 đến " + strconv.Itoa(recommendationMaxLimit)})
+		return
+	}
+
+	recommendations, err := models.RecommendBooks(rc.DB, userID, limit)
+	if err != nil {
+		log.Printf("[DEBUG] Lỗi khi lấy gợi ý sách cho user %d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Không thể lấy gợi ý sách"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    recommendations,
+		"limit":   limit,
+	})
+}
@@ -33,10 +33,10 @@ type UpdateReviewInput struct {
 	Comment string `json:"comment" binding:"omitempty,max=500"`
 }
 type TopRatedBook struct {
-	ID            uint    `json:"id"`
-	Title         string  `json:"title"`
-	Author        string  `json:"author"`
-	CoverImage    string  `json:"cover_image"`
+	ID     uint   `json:"id"`
+	Title  string `json:"title"`
+	Author string `json:"author"`
+	models.BookCover
 	AverageRating float64 `json:"average_rating"`
 	Price         float64 `json:"price"`
 }
@@ -219,17 +219,17 @@ func (rc *ReviewController) GetMostReviewedBooks(c *gin.Context) {
 	}
 
 	var results []struct {
-		ID         uint    `json:"id"`
-		Title      string  `json:"title"`
-		Author     string  `json:"author"`
-		CoverImage string  `json:"cover_image"`
-		ViewCount  int64   `json:"view_count"`
-		Price      float64 `json:"price"`
+		ID     uint   `json:"id"`
+		Title  string `json:"title"`
+		Author string `json:"author"`
+		models.BookCover
+		ViewCount int64   `json:"view_count"`
+		Price     float64 `json:"price"`
 	}
 
 	err = rc.DB.
 		Table("reviews").
-		Select("books.id, books.title, books.author, books.cover_image, books.price, COUNT(reviews.id) AS view_count").
+		Select("books.id, books.title, books.author, books.cover_image, books.cover_thumbnails, books.price, COUNT(reviews.id) AS view_count").
 		Joins("JOIN books ON reviews.book_id = books.id").
 		Where(timeCondition).
 		Group("books.id").
@@ -242,6 +242,9 @@ func (rc *ReviewController) GetMostReviewedBooks(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể lấy sách nhiều review nhất"})
 		return
 	}
+	for i := range results {
+		results[i].ResolveCoverImages()
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"limit":  limit,
@@ -279,7 +282,7 @@ func (rc *ReviewController) GetTopRatedBooks(c *gin.Context) {
 
 	err = rc.DB.
 		Table("reviews").
-		Select("books.id, books.title, books.author, books.cover_image, books.price, AVG(reviews.rating) as average_rating").
+		Select("books.id, books.title, books.author, books.cover_image, books.cover_thumbnails, books.price, AVG(reviews.rating) as average_rating").
 		Joins("JOIN books ON reviews.book_id = books.id").
 		Where(timeCondition).
 		Group("books.id").
@@ -293,6 +296,9 @@ func (rc *ReviewController) GetTopRatedBooks(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể lấy danh sách sách top rating"})
 		return
 	}
+	for i := range results {
+		results[i].ResolveCoverImages()
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"limit":  limit,
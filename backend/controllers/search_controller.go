@@ -0,0 +1,333 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Poloni84Learning/ebook-store/config"
+	"github.com/Poloni84Learning/ebook-store/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	searchDefaultLimit = 20
+	searchMaxLimit     = 50
+	searchMaxQueryLen  = 200
+
+	suggestDefaultLimit = 5
+	suggestMaxLimit     = 10
+	suggestMaxQueryLen  = 100
+	suggestTimeout      = 500 * time.Millisecond // Gợi ý trễ thì bỏ, client sẽ gửi lại theo phím gõ tiếp
+)
+
+// Tùy chọn ts_headline: từ khớp được bọc trong <mark>, mô tả chỉ lấy đoạn chứa từ khớp
+const (
+	headlineFieldOptions       = "HighlightAll=true, StartSel=<mark>, StopSel=</mark>"
+	headlineDescriptionOptions = "MaxFragments=2, MaxWords=30, MinWords=10, FragmentDelimiter=\" … \", StartSel=<mark>, StopSel=</mark>"
+)
+
+type SearchController struct {
+	DB     *gorm.DB
+	Config *config.Config
+}
+
+// SearchHighlights là các trường đã đánh dấu từ khớp bằng <mark>; phần còn lại đã được escape HTML
+type SearchHighlights struct {
+	Title       string `json:"title"`
+	Author      string `json:"author"`
+	Description string `json:"description,omitempty"`
+}
+
+type SearchResult struct {
+	ID            uint                `json:"id"`
+	Title         string              `json:"title"`
+	Author        string              `json:"author"`
+	Category      models.BookCategory `json:"category"`
+	Price         float64             `json:"price"`
+	AverageRating float64             `json:"average_rating"`
+	Language      string              `json:"language,omitempty"`
+	models.BookCover
+	Rank       float64          `json:"rank"`
+	Highlights SearchHighlights `gorm:"embedded;embeddedPrefix:hl_" json:"highlights"`
+}
+
+func NewSearchController(db *gorm.DB, cfg *config.Config) *SearchController {
+	return &SearchController{DB: db, Config: cfg}
+}
+
+// Search - Tìm kiếm toàn văn trên tiêu đề, tác giả, keyword, mục lục, thể loại và mô tả,
+// kết quả xếp theo độ liên quan, có đánh dấu từ khớp và phân trang
+func (sc *SearchController) Search(c *gin.Context) {
+	started := time.Now()
+	q := strings.TrimSpace(c.Query("q"))
+	if q == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Thiếu từ khóa tìm kiếm"})
+		return
+	}
+	if len([]rune(q)) > searchMaxQueryLen {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Từ khóa tìm kiếm quá dài"})
+		return
+	}
+	page, limit, ok := parsePagination(c, searchDefaultLimit, searchMaxLimit)
+	if !ok {
+		return
+	}
+
+	where := "b.deleted_at IS NULL AND b.search_vector @@ q.query"
+	args := map[string]interface{}{
+		"q":      q,
+		"limit":  limit,
+		"offset": (page - 1) * limit,
+	}
+	if category := c.Query("category"); category != "" {
+		if !models.BookCategory(category).IsValid() {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Thể loại không hợp lệ"})
+			return
+		}
+		where += " AND b.category = @category"
+		args["category"] = category
+	}
+
+	var total int64
+	if err := sc.DB.Raw(`WITH q AS (SELECT `+models.BookSearchQuery+` AS query)
+		SELECT COUNT(*) FROM books b, q WHERE `+where, args).Scan(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Lỗi khi tìm kiếm sách"})
+		return
+	}
+
+	results := []SearchResult{}
+	if total > 0 {
+		// Xếp hạng và phân trang trước, chỉ tạo headline (tốn CPU) cho các dòng của trang hiện tại
+		err := sc.DB.Raw(`WITH q AS (SELECT `+models.BookSearchQuery+` AS query),
+		hits AS (
+			SELECT b.id, ts_rank_cd(b.search_vector, q.query, 32) AS rank
+			FROM books b, q
+			WHERE `+where+`
+			ORDER BY rank DESC, b.average_rating DESC, b.id
+			LIMIT @limit OFFSET @offset
+		)
+		SELECT b.id, b.title, b.author, b.category, b.price, b.average_rating, b.language,
+			b.cover_image, b.cover_thumbnails, hits.rank,
+			ts_headline('`+models.SearchConfigEN+`', `+escapeHTMLSQL("b.title")+`, q.query, '`+headlineFieldOptions+`') AS hl_title,
+			ts_headline('`+models.SearchConfigEN+`', `+escapeHTMLSQL("b.author")+`, q.query, '`+headlineFieldOptions+`') AS hl_author,
+			ts_headline('`+models.SearchConfigEN+`', `+escapeHTMLSQL("coalesce(b.description, '')")+`, q.query, '`+headlineDescriptionOptions+`') AS hl_description
+		FROM hits JOIN books b ON b.id = hits.id CROSS JOIN q
+		ORDER BY hits.rank DESC, b.average_rating DESC, b.id`, args).Scan(&results).Error
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Lỗi khi tìm kiếm sách"})
+			return
+		}
+	}
+	for i := range results {
+		results[i].ResolveCoverImages()
+	}
+
+	// Chỉ ghi log trang đầu, các trang sau là cùng một lượt tìm kiếm
+	if page == 1 {
+		recordSearch(c, sc.DB, sc.Config, "search", q, int(total), started)
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    results,
+		"total":   total,
+		"page":    page,
+		"limit":   limit,
+	})
+}
+
+// Suggest - Gợi ý tiêu đề, tác giả và keyword khi người dùng đang gõ, kèm "did you mean" cho tên tác giả gõ sai
+func (sc *SearchController) Suggest(c *gin.Context) {
+	q := strings.TrimSpace(c.Query("q"))
+	if q == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Thiếu từ khóa tìm kiếm"})
+		return
+	}
+	if len([]rune(q)) > suggestMaxQueryLen {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Từ khóa tìm kiếm quá dài"})
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(suggestDefaultLimit)))
+	if err != nil || limit < 1 || limit > suggestMaxLimit {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "limit phải từ 1 đến " + strconv.Itoa(suggestMaxLimit)})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), suggestTimeout)
+	defer cancel()
+	suggestions, err := models.SuggestBooks(sc.DB.WithContext(ctx), q, limit)
+	if err != nil {
+		log.Printf("[DEBUG] Lỗi khi lấy gợi ý tìm kiếm: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Không thể lấy gợi ý tìm kiếm"})
+		return
+	}
+
+	// Cùng một tiền tố được gõ lại nhiều lần, cho phép trình duyệt/CDN cache ngắn hạn
+	c.Header("Cache-Control", "public, max-age=60")
+	c.JSON(http.StatusOK, gin.H{"success": true, "query": q, "data": suggestions})
+}
+
+// parsePagination đọc page/limit, trả về false (đã gửi lỗi 400) nếu không hợp lệ
+func parsePagination(c *gin.Context, defaultLimit, maxLimit int) (int, int, bool) {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "page không hợp lệ"})
+		return 0, 0, false
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultLimit)))
+	if err != nil || limit < 1 || limit > maxLimit {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "limit phải từ 1 đến " + strconv.Itoa(maxLimit)})
+		return 0, 0, false
+	}
+	return page, limit, true
+}
+
+// escapeHTMLSQL escape HTML trong SQL trước khi ts_headline chèn <mark>, để client hiển thị
+// highlight bằng innerHTML mà không bị chèn mã từ dữ liệu sách
+func escapeHTMLSQL(expr string) string {
+	return "replace(replace(replace(" + expr + ", '&', '&amp;'), '<', '&lt;'), '>', '&gt;')"
+}
+
+const (
+	insightsDefaultDays = 7
+	insightsMaxDays     = 365
+	insightsMaxLimit    = 100
+)
+
+type searchInsightsSummary struct {
+	TotalSearches      int64   `json:"total_searches"`
+	UniqueSearchers    int64   `json:"unique_searchers"`
+	ZeroResultSearches int64   `json:"zero_result_searches"`
+	SearchesWithClick  int64   `json:"searches_with_click"`
+	AvgLatencyMs       float64 `json:"avg_latency_ms"`
+	P95LatencyMs       float64 `json:"p95_latency_ms"`
+	ZeroResultRate     float64 `json:"zero_result_rate" gorm:"-"`
+	ClickThroughRate   float64 `json:"click_through_rate" gorm:"-"`
+}
+
+type searchQueryStat struct {
+	Query             string    `json:"query"`
+	Example           string    `json:"example"` // Một cách gõ thực tế của query (có dấu)
+	Searches          int64     `json:"searches"`
+	Users             int64     `json:"users"`
+	AvgResults        float64   `json:"avg_results"`
+	SearchesWithClick int64     `json:"searches_with_click"`
+	ClickThroughRate  float64   `json:"click_through_rate" gorm:"-"`
+	LastSearchedAt    time.Time `json:"last_searched_at"`
+}
+
+type searchClickedBook struct {
+	BookID   uint   `json:"book_id"`
+	Title    string `json:"title"`
+	Clicks   int64  `json:"clicks"`
+	Searches int64  `json:"searches"`
+}
+
+// Lượt tìm kiếm có ít nhất một lần mở sách từ kết quả: COUNT(clicked.search_id) sau khi join
+const searchClickedJoin = "LEFT JOIN (SELECT DISTINCT search_id FROM search_clicks) clicked ON clicked.search_id = search_logs.id"
+
+// GetSearchInsights - Thống kê tìm kiếm cho admin: query phổ biến, query không có kết quả
+// (để bổ sung keyword qua UpdateKeywordsAndTOC) và tỷ lệ mở sách từ kết quả
+func (sc *SearchController) GetSearchInsights(c *gin.Context) {
+	days, err := strconv.Atoi(c.DefaultQuery("days", strconv.Itoa(insightsDefaultDays)))
+	if err != nil || days < 1 || days > insightsMaxDays {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "days phải từ 1 đến " + strconv.Itoa(insightsMaxDays)})
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit < 1 || limit > insightsMaxLimit {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "limit phải từ 1 đến " + strconv.Itoa(insightsMaxLimit)})
+		return
+	}
+	since := time.Now().AddDate(0, 0, -days)
+
+	var summary searchInsightsSummary
+	err = sc.DB.Model(&models.SearchLog{}).
+		Select(`COUNT(*) AS total_searches,
+			COUNT(DISTINCT user_hash) AS unique_searchers,
+			COUNT(*) FILTER (WHERE result_count = 0) AS zero_result_searches,
+			COUNT(clicked.search_id) AS searches_with_click,
+			COALESCE(AVG(latency_ms), 0) AS avg_latency_ms,
+			COALESCE(percentile_cont(0.95) WITHIN GROUP (ORDER BY latency_ms), 0) AS p95_latency_ms`).
+		Joins(searchClickedJoin).
+		Where("created_at >= ?", since).
+		Scan(&summary).Error
+	if err != nil {
+		log.Printf("[DEBUG] Lỗi khi thống kê tìm kiếm: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Không thể lấy thống kê tìm kiếm"})
+		return
+	}
+	summary.ZeroResultRate = ratio(summary.ZeroResultSearches, summary.TotalSearches)
+	summary.ClickThroughRate = ratio(summary.SearchesWithClick, summary.TotalSearches)
+
+	queryStats := func(zeroResultsOnly bool) ([]searchQueryStat, error) {
+		stats := []searchQueryStat{}
+		query := sc.DB.Model(&models.SearchLog{}).
+			Select(`normalized_query AS query, MIN(query) AS example,
+				COUNT(*) AS searches, COUNT(DISTINCT user_hash) AS users,
+				ROUND(AVG(result_count), 1) AS avg_results,
+				COUNT(clicked.search_id) AS searches_with_click,
+				MAX(created_at) AS last_searched_at`).
+			Joins(searchClickedJoin).
+			Where("created_at >= ? AND normalized_query <> ''", since)
+		if zeroResultsOnly {
+			query = query.Where("result_count = 0")
+		}
+		err := query.Group("normalized_query").Order("searches DESC, normalized_query").Limit(limit).Scan(&stats).Error
+		for i := range stats {
+			stats[i].ClickThroughRate = ratio(stats[i].SearchesWithClick, stats[i].Searches)
+		}
+		return stats, err
+	}
+	topQueries, err := queryStats(false)
+	if err != nil {
+		log.Printf("[DEBUG] Lỗi khi lấy top query: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Không thể lấy thống kê tìm kiếm"})
+		return
+	}
+	zeroResultQueries, err := queryStats(true)
+	if err != nil {
+		log.Printf("[DEBUG] Lỗi khi lấy query không có kết quả: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Không thể lấy thống kê tìm kiếm"})
+		return
+	}
+
+	clickedBooks := []searchClickedBook{}
+	err = sc.DB.Model(&models.SearchClick{}).
+		Select("books.id AS book_id, books.title, COUNT(*) AS clicks, COUNT(DISTINCT search_clicks.search_id) AS searches").
+		Joins("JOIN books ON books.id = search_clicks.book_id").
+		Where("search_clicks.created_at >= ?", since).
+		Group("books.id, books.title").
+		Order("clicks DESC, books.id").
+		Limit(limit).
+		Scan(&clickedBooks).Error
+	if err != nil {
+		log.Printf("[DEBUG] Lỗi khi lấy sách được mở từ tìm kiếm: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Không thể lấy thống kê tìm kiếm"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"days":    days,
+		"limit":   limit,
+		"data": gin.H{
+			"summary":             summary,
+			"top_queries":         topQueries,
+			"zero_result_queries": zeroResultQueries,
+			"top_clicked_books":   clickedBooks,
+		},
+	})
+}
+
+func ratio(part, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return math.Round(float64(part)/float64(total)*1000) / 1000
+}
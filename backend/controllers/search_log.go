@@ -0,0 +1,65 @@
+package controllers
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"regexp"
+	"time"
+
+	"github.com/Poloni84Learning/ebook-store/config"
+	"github.com/Poloni84Learning/ebook-store/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Header trả về ID lượt tìm kiếm; client gửi lại qua ?search_id= khi mở sách từ kết quả
+const searchIDHeader = "X-Search-ID"
+
+var searchIDPattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// recordSearch ghi log một lượt tìm kiếm và đặt header X-Search-ID, phải gọi trước khi ghi response.
+// Việc ghi DB chạy ở goroutine riêng để không làm chậm kết quả tìm kiếm.
+func recordSearch(c *gin.Context, db *gorm.DB, cfg *config.Config, endpoint, query string, resultCount int, started time.Time) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		log.Printf("[Search] Không tạo được search ID: %v", err)
+		return
+	}
+	entry := models.NewSearchLog(hex.EncodeToString(id), endpoint, query, resultCount, time.Since(started), anonymousUserHash(c, cfg))
+	c.Header(searchIDHeader, entry.ID)
+
+	go func() {
+		if err := db.Create(entry).Error; err != nil {
+			log.Printf("[Search] Lỗi ghi log tìm kiếm %q: %v", entry.Query, err)
+		}
+	}()
+}
+
+// recordSearchClick ghi nhận việc mở sách từ kết quả tìm kiếm; search_id sai định dạng thì bỏ qua
+func recordSearchClick(db *gorm.DB, searchID string, bookID uint) {
+	if !searchIDPattern.MatchString(searchID) {
+		return
+	}
+	go func() {
+		if err := db.Create(&models.SearchClick{SearchID: searchID, BookID: bookID}).Error; err != nil {
+			log.Printf("[Search] Lỗi ghi click-through %s -> sách %d: %v", searchID, bookID, err)
+		}
+	}()
+}
+
+// anonymousUserHash trả về định danh ẩn danh cho thống kê: HMAC của user ID khi đã đăng nhập,
+// khách vãng lai dùng IP + User-Agent theo ngày (đếm được số người tìm, không theo dõi lâu dài).
+// Route public phải gắn middlewares.OptionalJWTAuthMiddleware thì userID mới có trong context.
+func anonymousUserHash(c *gin.Context, cfg *config.Config) string {
+	mac := hmac.New(sha256.New, []byte("search-log:"+cfg.JWTSecret))
+	if userID := c.GetUint("userID"); userID != 0 {
+		fmt.Fprintf(mac, "user:%d", userID)
+	} else {
+		fmt.Fprintf(mac, "guest:%s|%s|%s", c.ClientIP(), c.Request.UserAgent(), time.Now().Format("2006-01-02"))
+	}
+	return hex.EncodeToString(mac.Sum(nil))[:32]
+}
@@ -0,0 +1,230 @@
+package controllers
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg" // Đăng ký decoder cho image.Decode
+	_ "image/png"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"regexp"
+	"strconv"
+
+	"github.com/Poloni84Learning/ebook-store/models"
+	"github.com/Poloni84Learning/ebook-store/pdf"
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/gin-gonic/gin"
+)
+
+// Định dạng ảnh bìa được chấp nhận (theo nội dung file) và phần mở rộng khi lưu
+var coverImageTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+}
+
+var pdfObjectHeader = regexp.MustCompile(`^\s*\d+\s+\d+\s+obj\b`)
+
+// InvalidUploadError trả về khi file upload không hợp lệ (sai định dạng, quá lớn, hỏng...)
+type InvalidUploadError struct {
+	Field    string // Tên field trong form: pdf, epub, mobi, cover_image
+	Reason   string
+	Detected string // MIME type phát hiện được từ nội dung (nếu có)
+}
+
+func (e *InvalidUploadError) Error() string {
+	if e.Detected != "" {
+		return fmt.Sprintf("invalid %s: %s (detected %s)", e.Field, e.Reason, e.Detected)
+	}
+	return fmt.Sprintf("invalid %s: %s", e.Field, e.Reason)
+}
+
+// validateBookFile kiểm tra kích thước, MIME type thật và cấu trúc của file ebook
+func validateBookFile(file *multipart.FileHeader, format models.BookFormat, maxSize int64) error {
+	spec, ok := models.BookFormats[format]
+	if !ok {
+		return fmt.Errorf("unsupported format: %s", format)
+	}
+	field := string(format)
+	if err := checkUploadSize(file, field, maxSize); err != nil {
+		return err
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	detected, err := mimetype.DetectReader(src)
+	if err != nil {
+		return err
+	}
+	if !detected.Is(spec.MimeType) {
+		return &InvalidUploadError{Field: field, Reason: "nội dung không đúng định dạng " + field, Detected: detected.String()}
+	}
+
+	switch format {
+	case models.FormatPDF:
+		return checkPDFStructure(src, file.Size)
+	case models.FormatEPUB:
+		return checkEPUBStructure(src, file.Size)
+	}
+	return nil
+}
+
+// checkPDFStructure kiểm tra header %PDF-x.y, marker %%EOF cuối file, startxref trỏ tới
+// bảng xref (hoặc xref stream) nằm trong file và mọi entry của xref đều nằm trong file
+func checkPDFStructure(r io.ReaderAt, size int64) error {
+	invalid := func(reason string) error {
+		return &InvalidUploadError{Field: string(models.FormatPDF), Reason: reason}
+	}
+
+	head := make([]byte, 8)
+	if _, err := r.ReadAt(head, 0); err != nil {
+		return invalid("file PDF bị cắt cụt")
+	}
+	if !bytes.HasPrefix(head, []byte("%PDF-")) || (head[5] != '1' && head[5] != '2') || head[6] != '.' {
+		return invalid("thiếu header %PDF")
+	}
+
+	tailSize := int64(2048)
+	if size < tailSize {
+		tailSize = size
+	}
+	tail := make([]byte, tailSize)
+	if _, err := r.ReadAt(tail, size-tailSize); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	eof := bytes.LastIndex(tail, []byte("%%EOF"))
+	if eof < 0 {
+		return invalid("thiếu %%EOF (file bị cắt cụt?)")
+	}
+	startxref := bytes.LastIndex(tail[:eof], []byte("startxref"))
+	if startxref < 0 {
+		return invalid("thiếu startxref")
+	}
+	offset, err := strconv.ParseInt(string(bytes.TrimSpace(tail[startxref+len("startxref"):eof])), 10, 64)
+	if err != nil || offset <= 0 || offset >= size {
+		return invalid("startxref không hợp lệ")
+	}
+
+	section := make([]byte, 64)
+	n, _ := r.ReadAt(section, offset)
+	section = section[:n]
+	if !bytes.HasPrefix(bytes.TrimLeft(section, " \r\n\t"), []byte("xref")) && !pdfObjectHeader.Match(section) {
+		return invalid("startxref không trỏ tới bảng xref")
+	}
+
+	// Đọc toàn bộ bảng xref bằng chính bộ đọc PDF dùng khi đóng dấu/trích xuất (chỉ nạp các
+	// section xref, không nạp cả file): mọi object phải nằm trong file
+	if err := pdf.ValidateXref(r, size); err != nil {
+		return invalid("bảng xref không hợp lệ hoặc trỏ ra ngoài file")
+	}
+	return nil
+}
+
+// checkEPUBStructure kiểm tra EPUB là file zip đọc được và có META-INF/container.xml
+func checkEPUBStructure(r io.ReaderAt, size int64) error {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return &InvalidUploadError{Field: string(models.FormatEPUB), Reason: "file zip bị hỏng"}
+	}
+	for _, f := range archive.File {
+		if f.Name == "META-INF/container.xml" {
+			return nil
+		}
+	}
+	return &InvalidUploadError{Field: string(models.FormatEPUB), Reason: "thiếu META-INF/container.xml"}
+}
+
+// validateCoverImage kiểm tra ảnh bìa (JPEG/PNG), giải mã toàn bộ ảnh và giới hạn kích thước.
+// Trả về ảnh đã giải mã và phần mở rộng dùng khi lưu.
+func validateCoverImage(file *multipart.FileHeader, maxSize int64, maxDimension int) (image.Image, string, error) {
+	const field = "cover_image"
+	if err := checkUploadSize(file, field, maxSize); err != nil {
+		return nil, "", err
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		return nil, "", err
+	}
+	defer src.Close()
+
+	detected, err := mimetype.DetectReader(src)
+	if err != nil {
+		return nil, "", err
+	}
+	ext, ok := coverImageTypes[detected.String()]
+	if !ok {
+		return nil, "", &InvalidUploadError{Field: field, Reason: "chỉ chấp nhận ảnh JPEG hoặc PNG", Detected: detected.String()}
+	}
+
+	// Đọc kích thước trước khi giải mã để không cấp phát bộ nhớ cho ảnh quá lớn
+	if _, err := src.Seek(0, io.SeekStart); err != nil {
+		return nil, "", err
+	}
+	config, _, err := image.DecodeConfig(src)
+	if err != nil {
+		return nil, "", &InvalidUploadError{Field: field, Reason: "không đọc được ảnh"}
+	}
+	if config.Width <= 0 || config.Height <= 0 || config.Width > maxDimension || config.Height > maxDimension {
+		return nil, "", &InvalidUploadError{Field: field, Reason: fmt.Sprintf("kích thước ảnh %dx%d vượt quá %dx%d", config.Width, config.Height, maxDimension, maxDimension)}
+	}
+
+	if _, err := src.Seek(0, io.SeekStart); err != nil {
+		return nil, "", err
+	}
+	img, _, err := image.Decode(src)
+	if err != nil {
+		return nil, "", &InvalidUploadError{Field: field, Reason: "ảnh bị hỏng"}
+	}
+	return img, ext, nil
+}
+
+func checkUploadSize(file *multipart.FileHeader, field string, maxSize int64) error {
+	if file.Size == 0 {
+		return &InvalidUploadError{Field: field, Reason: "file rỗng"}
+	}
+	if file.Size > maxSize {
+		return &InvalidUploadError{Field: field, Reason: fmt.Sprintf("file vượt quá %d MB", maxSize>>20)}
+	}
+	return nil
+}
+
+// randomStorageName tạo tên file ngẫu nhiên, không chứa tên file gốc của người upload
+func randomStorageName(ext string) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b) + ext, nil
+}
+
+// limitUploadBody giới hạn tổng dung lượng request upload
+func limitUploadBody(c *gin.Context, maxBytes int64) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes)
+}
+
+func respondUploadError(c *gin.Context, err error) {
+	var invalid *InvalidUploadError
+	if errors.As(err, &invalid) {
+		resp := gin.H{
+			"success": false,
+			"error":   "File " + invalid.Field + " không hợp lệ: " + invalid.Reason,
+			"field":   invalid.Field,
+		}
+		if invalid.Detected != "" {
+			resp["detected"] = invalid.Detected
+		}
+		c.JSON(http.StatusBadRequest, resp)
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Không thể lưu file", "details": err.Error()})
+}
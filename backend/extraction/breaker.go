@@ -0,0 +1,132 @@
+package extraction
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/Poloni84Learning/ebook-store/config"
+)
+
+type BreakerState string
+
+const (
+	BreakerClosed   BreakerState = "closed"    // Gọi dịch vụ bình thường
+	BreakerOpen     BreakerState = "open"      // Dịch vụ lỗi liên tiếp, tạm ngừng gọi
+	BreakerHalfOpen BreakerState = "half_open" // Hết thời gian chờ, cho một request thử
+)
+
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// CircuitBreaker ngừng gọi dịch vụ sau threshold lỗi liên tiếp; sau cooldown cho một request
+// thử, thành công thì đóng lại, lỗi thì mở tiếp. Trạng thái nằm trong bộ nhớ của từng instance.
+type CircuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+
+	state     BreakerState
+	failures  int // Số lỗi liên tiếp
+	openedAt  time.Time
+	probing   bool // Đang có request thử ở trạng thái half-open
+	lastError string
+	lastFail  time.Time
+}
+
+// BreakerStatus là ảnh chụp trạng thái breaker, trả về cho trang health của admin
+type BreakerStatus struct {
+	State               BreakerState `json:"state"`
+	ConsecutiveFailures int          `json:"consecutive_failures"`
+	Threshold           int          `json:"threshold"`
+	OpenedAt            *time.Time   `json:"opened_at,omitempty"`
+	RetryAt             *time.Time   `json:"retry_at,omitempty"`
+	LastError           string       `json:"last_error,omitempty"`
+	LastFailureAt       *time.Time   `json:"last_failure_at,omitempty"`
+}
+
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	if threshold < 1 {
+		threshold = 1
+	}
+	return &CircuitBreaker{threshold: threshold, cooldown: cooldown, state: BreakerClosed}
+}
+
+var (
+	serviceBreaker     *CircuitBreaker
+	serviceBreakerOnce sync.Once
+)
+
+// ServiceBreaker trả về breaker dùng chung cho dịch vụ trích xuất trong tiến trình
+// (worker gọi dịch vụ, trang health của admin đọc trạng thái)
+func ServiceBreaker(cfg *config.Config) *CircuitBreaker {
+	serviceBreakerOnce.Do(func() {
+		serviceBreaker = NewCircuitBreaker(cfg.ExtractionBreakerThreshold, cfg.ExtractionBreakerCooldown)
+	})
+	return serviceBreaker
+}
+
+// Allow cho biết có được gọi dịch vụ không; trả về ErrCircuitOpen khi breaker đang mở
+func (b *CircuitBreaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if time.Since(b.openedAt) < b.cooldown {
+			return ErrCircuitOpen
+		}
+		b.state = BreakerHalfOpen
+		b.probing = true
+		return nil
+	case BreakerHalfOpen:
+		if b.probing {
+			return ErrCircuitOpen
+		}
+		b.probing = true
+	}
+	return nil
+}
+
+// Success ghi nhận request thành công và đóng breaker
+func (b *CircuitBreaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.state = BreakerClosed
+	b.failures = 0
+	b.probing = false
+}
+
+// Failure ghi nhận lỗi; đủ ngưỡng (hoặc request thử ở half-open lỗi) thì mở breaker
+func (b *CircuitBreaker) Failure(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	b.probing = false
+	b.lastError = err.Error()
+	b.lastFail = time.Now()
+	if b.state == BreakerHalfOpen || b.failures >= b.threshold {
+		b.state = BreakerOpen
+		b.openedAt = time.Now()
+	}
+}
+
+func (b *CircuitBreaker) Status() BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	status := BreakerStatus{
+		State:               b.state,
+		ConsecutiveFailures: b.failures,
+		Threshold:           b.threshold,
+		LastError:           b.lastError,
+	}
+	if b.state == BreakerOpen {
+		openedAt, retryAt := b.openedAt, b.openedAt.Add(b.cooldown)
+		status.OpenedAt, status.RetryAt = &openedAt, &retryAt
+	}
+	if !b.lastFail.IsZero() {
+		lastFail := b.lastFail
+		status.LastFailureAt = &lastFail
+	}
+	return status
+}
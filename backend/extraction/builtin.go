@@ -0,0 +1,289 @@
+package extraction
+
+import (
+	"context"
+	"errors"
+	"math"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/Poloni84Learning/ebook-store/pdf"
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	builtinMaxPages    = 200 // Chỉ đọc text của chừng này trang đầu
+	builtinMaxKeywords = 15
+	maxPhraseWords     = 3
+	maxTOCTitles       = 100
+	maxTOCTitleLen     = 150
+)
+
+var ErrNoText = errors.New("PDF has no extractable text (scanned images?)")
+
+var (
+	// Dòng mục lục: tiêu đề, dấu chấm dẫn (tùy chọn), số trang ở cuối
+	tocEntryLine = regexp.MustCompile(`^(.*\pL.*?)[\s.·…_]*\s(\d{1,4}|[ivxlcdm]{1,6})$`)
+	// Dòng tiêu đề chương không có số trang
+	tocHeadingLine = regexp.MustCompile(`(?i)^(chương|chapter|phần|part|bài|lesson|appendix|phụ lục)\s+[\pL\pN]+`)
+)
+
+// BuiltinExtractor trích xuất trong tiến trình, không cần dịch vụ ngoài: keyword tính bằng
+// RAKE trên text của PDF, mục lục lấy từ bookmark hoặc từ các trang mục lục (toc_pages).
+type BuiltinExtractor struct {
+	MaxPages    int
+	MaxKeywords int
+}
+
+func NewBuiltinExtractor() *BuiltinExtractor {
+	return &BuiltinExtractor{MaxPages: builtinMaxPages, MaxKeywords: builtinMaxKeywords}
+}
+
+func (b *BuiltinExtractor) Name() string {
+	return "builtin"
+}
+
+func (b *BuiltinExtractor) Extract(ctx context.Context, pdfPath string, req Request) (*Result, error) {
+	src, err := os.ReadFile(pdfPath)
+	if err != nil {
+		return nil, err
+	}
+	texts, err := pdf.PageTexts(src, b.MaxPages)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	tocTitles := outlineTitles(src)
+	if len(tocTitles) == 0 && req.TOCPages != "" {
+		tocTitles = tocFromPages(texts, parsePageRange(req.TOCPages, len(texts)))
+	}
+	keywords := rakeKeywords(strings.Join(texts, "\n"), b.MaxKeywords)
+	if len(keywords) == 0 && len(tocTitles) == 0 {
+		return nil, ErrNoText
+	}
+	return &Result{Keywords: keywords, TOCTitles: tocTitles}, nil
+}
+
+// outlineTitles lấy tiêu đề hai cấp đầu trong bookmark của PDF
+func outlineTitles(src []byte) []string {
+	items, err := pdf.Outline(src)
+	if err != nil {
+		return nil
+	}
+	var titles []string
+	for _, item := range items {
+		if item.Level > 1 {
+			continue
+		}
+		if title := cleanTitle(item.Title); title != "" {
+			titles = appendUnique(titles, title)
+		}
+		if len(titles) >= maxTOCTitles {
+			break
+		}
+	}
+	return titles
+}
+
+// tocFromPages nhận diện dòng mục lục trên các trang chỉ định
+func tocFromPages(texts []string, pages []int) []string {
+	var titles []string
+	for _, page := range pages {
+		for _, line := range strings.Split(texts[page], "\n") {
+			line = strings.Join(strings.Fields(line), " ")
+			var title string
+			if m := tocEntryLine.FindStringSubmatch(line); m != nil {
+				title = m[1]
+			} else if tocHeadingLine.MatchString(line) {
+				title = line
+			}
+			if title = cleanTitle(title); title != "" {
+				titles = appendUnique(titles, title)
+			}
+			if len(titles) >= maxTOCTitles {
+				return titles
+			}
+		}
+	}
+	return titles
+}
+
+func cleanTitle(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	s = strings.TrimRight(s, " .·…_")
+	if len([]rune(s)) < 2 || len(s) > maxTOCTitleLen {
+		return ""
+	}
+	return s
+}
+
+// parsePageRange đọc "3-5", "3,4,7" hoặc "3-5,8" (đánh số từ 1) thành chỉ số trang hợp lệ
+func parsePageRange(spec string, total int) []int {
+	var pages []int
+	seen := make(map[int]bool)
+	for _, part := range strings.Split(spec, ",") {
+		lo, hi, found := strings.Cut(strings.TrimSpace(part), "-")
+		from, err := strconv.Atoi(strings.TrimSpace(lo))
+		if err != nil {
+			continue
+		}
+		to := from
+		if found {
+			if to, err = strconv.Atoi(strings.TrimSpace(hi)); err != nil {
+				continue
+			}
+		}
+		for p := from; p <= to && p <= total; p++ {
+			if p >= 1 && !seen[p] {
+				seen[p] = true
+				pages = append(pages, p-1)
+			}
+		}
+	}
+	return pages
+}
+
+// rakeKeywords chọn keyword theo RAKE (Rapid Automatic Keyword Extraction): tách text thành
+// các cụm từ ngăn bởi từ dừng và dấu câu; điểm của từ là bậc/tần suất, điểm cụm là tổng điểm
+// các từ, nhân thêm theo số lần cụm xuất hiện để ưu tiên khái niệm lặp lại trong sách.
+func rakeKeywords(text string, limit int) []string {
+	// Chuẩn hóa NFC để chữ có dấu dạng tổ hợp (NFD) khớp với danh sách từ dừng
+	text = strings.ReplaceAll(strings.ToLower(norm.NFC.String(text)), "-\n", "") // Nối từ bị ngắt dòng
+
+	var phrases [][]string
+	var current []string
+	words := 0
+	flush := func() {
+		if len(current) > 0 && len(current) <= maxPhraseWords {
+			phrases = append(phrases, current)
+		}
+		current = nil
+	}
+	for _, token := range tokenize(text) {
+		if token == "" {
+			flush()
+			continue
+		}
+		words++
+		if stopwords[token] || !isKeywordToken(token) {
+			flush()
+			continue
+		}
+		current = append(current, token)
+	}
+	flush()
+
+	freq := make(map[string]float64)
+	degree := make(map[string]float64)
+	counts := make(map[string]int)
+	for _, phrase := range phrases {
+		for _, w := range phrase {
+			freq[w]++
+			degree[w] += float64(len(phrase))
+		}
+		counts[strings.Join(phrase, " ")]++
+	}
+
+	// Sách dài: bỏ cụm chỉ xuất hiện một lần (thường là nhiễu)
+	minCount := 1
+	if words > 2000 {
+		minCount = 2
+	}
+	type candidate struct {
+		phrase string
+		score  float64
+	}
+	var candidates []candidate
+	for phrase, count := range counts {
+		if count < minCount {
+			continue
+		}
+		score := 0.0
+		for _, w := range strings.Fields(phrase) {
+			score += degree[w] / freq[w]
+		}
+		candidates = append(candidates, candidate{phrase, score * (1 + math.Log(float64(count)))})
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].score != candidates[j].score {
+			return candidates[i].score > candidates[j].score
+		}
+		return candidates[i].phrase < candidates[j].phrase
+	})
+
+	var keywords []string
+	for _, c := range candidates {
+		if len(keywords) >= limit {
+			break
+		}
+		if !containedIn(c.phrase, keywords) {
+			keywords = append(keywords, c.phrase)
+		}
+	}
+	return keywords
+}
+
+// tokenize tách text thành từ; chuỗi rỗng đánh dấu ranh giới cụm (dấu câu, xuống dòng)
+func tokenize(text string) []string {
+	var tokens []string
+	var word strings.Builder
+	endWord := func() {
+		if word.Len() > 0 {
+			tokens = append(tokens, word.String())
+			word.Reset()
+		}
+	}
+	for _, r := range text {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r):
+			word.WriteRune(r)
+		case r == ' ' || r == '\t' || r == '\u00a0':
+			endWord()
+		default:
+			endWord()
+			tokens = append(tokens, "")
+		}
+	}
+	endWord()
+	return tokens
+}
+
+// isKeywordToken loại số, từ một ký tự, chuỗi quá dài (thường là URL/mã) và ký hiệu toán học
+func isKeywordToken(token string) bool {
+	n := 0
+	hasLetter := false
+	for _, r := range token {
+		if r >= 0x1D400 && r <= 0x1D7FF { // Mathematical Alphanumeric Symbols
+			return false
+		}
+		n++
+		hasLetter = hasLetter || unicode.IsLetter(r)
+	}
+	return hasLetter && n >= 2 && n <= 30
+}
+
+// containedIn cho biết phrase đã nằm trọn trong (hoặc chứa trọn) một keyword đã chọn
+func containedIn(phrase string, selected []string) bool {
+	for _, s := range selected {
+		if strings.Contains(" "+s+" ", " "+phrase+" ") || strings.Contains(" "+phrase+" ", " "+s+" ") {
+			return true
+		}
+	}
+	return false
+}
+
+func appendUnique(list []string, s string) []string {
+	for _, existing := range list {
+		if strings.EqualFold(existing, s) {
+			return list
+		}
+	}
+	return append(list, s)
+}
@@ -0,0 +1,68 @@
+package extraction
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/Poloni84Learning/ebook-store/config"
+)
+
+// Request là thông tin sách gửi kèm file PDF
+type Request struct {
+	Title    string
+	Author   string
+	Topic    string
+	TOCPages string // Tùy chọn, ví dụ "3-5"
+}
+
+// Result là keyword và tiêu đề mục lục trích xuất được
+type Result struct {
+	Keywords  []string `json:"keywords"`
+	TOCTitles []string `json:"toc_titles"`
+}
+
+// KeywordExtractor trích xuất keyword và tiêu đề mục lục từ file PDF
+type KeywordExtractor interface {
+	Name() string
+	Extract(ctx context.Context, pdfPath string, req Request) (*Result, error)
+}
+
+// NewExtractor khởi tạo bộ trích xuất theo cấu hình:
+// "http" chỉ dùng dịch vụ ngoài, "builtin" chỉ dùng bộ trích xuất trong tiến trình,
+// "auto" dùng dịch vụ ngoài và chuyển sang builtin khi dịch vụ lỗi.
+func NewExtractor(cfg *config.Config) (KeywordExtractor, error) {
+	switch cfg.KeywordExtractor {
+	case "", "auto":
+		return &fallbackExtractor{primary: NewHTTPExtractor(cfg), fallback: NewBuiltinExtractor()}, nil
+	case "http":
+		return NewHTTPExtractor(cfg), nil
+	case "builtin":
+		return NewBuiltinExtractor(), nil
+	default:
+		return nil, fmt.Errorf("unsupported keyword extractor: %s", cfg.KeywordExtractor)
+	}
+}
+
+type fallbackExtractor struct {
+	primary  KeywordExtractor
+	fallback KeywordExtractor
+}
+
+func (f *fallbackExtractor) Name() string {
+	return "auto"
+}
+
+func (f *fallbackExtractor) Extract(ctx context.Context, pdfPath string, req Request) (*Result, error) {
+	result, err := f.primary.Extract(ctx, pdfPath, req)
+	if err == nil {
+		return result, nil
+	}
+	// Job bị hủy/hết thời gian thì không chạy tiếp bộ dự phòng
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return nil, err
+	}
+	log.Printf("[Extraction] %s lỗi, dùng %s: %v", f.primary.Name(), f.fallback.Name(), err)
+	return f.fallback.Extract(ctx, pdfPath, req)
+}
@@ -0,0 +1,154 @@
+package extraction
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Poloni84Learning/ebook-store/config"
+)
+
+var ErrServiceUnavailable = errors.New("extraction service unavailable")
+
+// HTTPExtractor gọi dịch vụ trích xuất keyword/mục lục (service Python), qua circuit breaker
+// để dịch vụ chết không làm mỗi job phải chờ hết timeout
+type HTTPExtractor struct {
+	BaseURL       string
+	HTTP          *http.Client
+	HealthTimeout time.Duration
+	Breaker       *CircuitBreaker
+}
+
+func NewHTTPExtractor(cfg *config.Config) *HTTPExtractor {
+	return &HTTPExtractor{
+		BaseURL:       strings.TrimRight(cfg.ExtractionServiceURL, "/"),
+		HTTP:          &http.Client{Timeout: cfg.ExtractionTimeout},
+		HealthTimeout: cfg.ExtractionHealthTimeout,
+		Breaker:       ServiceBreaker(cfg),
+	}
+}
+
+func (c *HTTPExtractor) Name() string {
+	return "http"
+}
+
+// Extract gửi file PDF tới dịch vụ. Dịch vụ không sẵn sàng thì trả về ErrServiceUnavailable
+// (để job được thử lại hoặc chuyển sang bộ trích xuất dự phòng) thay vì kết quả rỗng.
+func (c *HTTPExtractor) Extract(ctx context.Context, pdfPath string, req Request) (*Result, error) {
+	if err := c.Breaker.Allow(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrServiceUnavailable, err)
+	}
+	result, err := c.extract(ctx, pdfPath, req)
+	var clientErr *clientError
+	if err == nil || errors.As(err, &clientErr) {
+		c.Breaker.Success() // Dịch vụ vẫn trả lời được, lỗi 4xx là do request
+	} else {
+		c.Breaker.Failure(err)
+	}
+	return result, err
+}
+
+// clientError là lỗi 4xx từ dịch vụ (file hoặc tham số không hợp lệ)
+type clientError struct {
+	status int
+	body   string
+}
+
+func (e *clientError) Error() string {
+	return fmt.Sprintf("API trả về lỗi %d: %s", e.status, e.body)
+}
+
+func (c *HTTPExtractor) extract(ctx context.Context, pdfPath string, req Request) (*Result, error) {
+	if err := c.Healthcheck(ctx); err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(pdfPath)
+	if err != nil {
+		return nil, fmt.Errorf("mở file PDF thất bại: %w", err)
+	}
+	defer file.Close()
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+
+	part, err := writer.CreateFormFile("file", filepath.Base(pdfPath))
+	if err != nil {
+		return nil, fmt.Errorf("tạo form file thất bại: %w", err)
+	}
+	if _, err := io.Copy(part, file); err != nil {
+		return nil, fmt.Errorf("ghi dữ liệu file thất bại: %w", err)
+	}
+
+	_ = writer.WriteField("book_title", req.Title)
+	_ = writer.WriteField("authors", req.Author)
+	_ = writer.WriteField("topic", req.Topic)
+	if req.TOCPages != "" {
+		_ = writer.WriteField("toc_pages", req.TOCPages)
+	}
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("đóng writer thất bại: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.BaseURL+"/extract-keywords", body)
+	if err != nil {
+		return nil, fmt.Errorf("tạo request thất bại: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", writer.FormDataContentType())
+
+	resp, err := c.HTTP.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("gửi request thất bại: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		responseBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		if resp.StatusCode >= 400 && resp.StatusCode < 500 {
+			return nil, &clientError{status: resp.StatusCode, body: string(responseBody)}
+		}
+		return nil, fmt.Errorf("API trả về lỗi %d: %s", resp.StatusCode, string(responseBody))
+	}
+
+	var result Result
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("decode JSON thất bại: %w", err)
+	}
+	return &result, nil
+}
+
+// Healthcheck gọi /healthcheck của dịch vụ (không đi qua breaker)
+func (c *HTTPExtractor) Healthcheck(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, c.HealthTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.BaseURL+"/healthcheck", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrServiceUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w: healthcheck status %d", ErrServiceUnavailable, resp.StatusCode)
+	}
+	var healthStatus struct {
+		Status string `json:"status"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&healthStatus); err != nil || healthStatus.Status != "healthy" {
+		return fmt.Errorf("%w: status %q", ErrServiceUnavailable, healthStatus.Status)
+	}
+	return nil
+}
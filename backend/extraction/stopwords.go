@@ -0,0 +1,34 @@
+package extraction
+
+import "strings"
+
+// Từ dừng (tiếng Anh và tiếng Việt) dùng để tách cụm từ khi tính keyword RAKE.
+// Tiếng Việt tách theo âm tiết nên danh sách gồm các âm tiết hư từ phổ biến.
+var stopwords = makeWordSet(`
+a about above after again against all almost also although always am among an and another any
+are aren around as at be because been before being below between both but by can cannot could
+did do does doing done down during each either else enough etc even ever every few for from
+further get gets got had has have having he her here hers herself him himself his how however i
+if in into is isn it its itself just least less let like made make many may me might more most
+much must my myself neither no nor not now of off often on once one only onto or other others
+otherwise our ours ourselves out over own per perhaps quite rather really same see seen shall
+she should since so some still such than that the their theirs them themselves then there
+these they this those though through thus to too toward towards two under until up upon us use
+used using very via was we well were what whatever when where whether which while who whom
+whose why will with within without would yet you your yours yourself yourselves
+chapter chapters page pages figure figures table tables section sections part contents
+copyright isbn edition press vol new first second third chương trang mục lục hình bảng phần
+và của là có các những một cho trong với được không này đã người để khi từ thì cũng như nhưng
+đến về ra lại mà sẽ nên vì theo rất nhiều ở trên dưới đó nào bị hay hoặc còn đang chỉ nếu
+tại sau trước vào lên xuống thế vậy đây kia ấy họ chúng ta tôi bạn anh em nó mình ai gì sao
+bao giờ lúc nơi cả mọi mỗi vẫn đều luôn rồi chưa hơn nhất cùng qua do bởi tuy dù nữa thêm
+việc điều cái con chiếc sự cách rằng thường phải cần muốn biết làm nói thấy
+`)
+
+func makeWordSet(words string) map[string]bool {
+	set := make(map[string]bool)
+	for _, w := range strings.Fields(words) {
+		set[w] = true
+	}
+	return set
+}
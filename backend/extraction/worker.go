@@ -0,0 +1,198 @@
+package extraction
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"os"
+	"time"
+
+	"github.com/Poloni84Learning/ebook-store/config"
+	"github.com/Poloni84Learning/ebook-store/models"
+	"github.com/Poloni84Learning/ebook-store/storage"
+	"gorm.io/gorm"
+)
+
+// Job processing lâu hơn timeout cộng khoảng này coi như worker đã chết giữa chừng
+const staleGrace = 5 * time.Minute
+
+// Worker lấy job trích xuất từ bảng extraction_jobs và xử lý lần lượt
+type Worker struct {
+	DB        *gorm.DB
+	Blob      storage.Blob
+	Extractor KeywordExtractor
+
+	PollInterval time.Duration
+	JobTimeout   time.Duration // Thời gian tối đa cho một lượt trích xuất
+	RetryBase    time.Duration
+	RetryMax     time.Duration
+}
+
+// StartWorker khởi động worker chạy ngầm
+func StartWorker(db *gorm.DB, cfg *config.Config) error {
+	blob, err := storage.NewBlob(cfg)
+	if err != nil {
+		return err
+	}
+	extractor, err := NewExtractor(cfg)
+	if err != nil {
+		return err
+	}
+	w := &Worker{
+		DB:           db,
+		Blob:         blob,
+		Extractor:    extractor,
+		PollInterval: cfg.ExtractionPollInterval,
+		JobTimeout:   cfg.ExtractionTimeout,
+		RetryBase:    cfg.ExtractionRetryBase,
+		RetryMax:     cfg.ExtractionRetryMax,
+	}
+	go w.run()
+	return nil
+}
+
+func (w *Worker) run() {
+	for {
+		processed, err := w.runOnceSafe(context.Background())
+		if err != nil {
+			log.Printf("[Extraction] Lỗi lấy job: %v", err)
+		}
+		if !processed {
+			time.Sleep(w.PollInterval)
+		}
+	}
+}
+
+// runOnceSafe gọi RunOnce và đổi panic ngoài phần xử lý job (lấy job, lưu trạng thái) thành lỗi
+// để vòng lặp của worker không dừng
+func (w *Worker) runOnceSafe(ctx context.Context) (processed bool, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			processed, err = false, fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return w.RunOnce(ctx)
+}
+
+// RunOnce xử lý một job đến hạn (nếu có). Trả về true nếu đã lấy được job.
+func (w *Worker) RunOnce(ctx context.Context) (bool, error) {
+	job, err := models.ClaimExtractionJob(w.DB, w.JobTimeout+staleGrace)
+	if err != nil || job == nil {
+		return false, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, w.JobTimeout)
+	defer cancel()
+
+	keywords, tocTitles, err := w.processSafe(ctx, job)
+	if err != nil {
+		var panicErr *jobPanicError
+		if errors.As(err, &panicErr) {
+			// Panic do dữ liệu sách (PDF hỏng) sẽ lặp lại ở mọi lần thử: đánh dấu failed luôn
+			job.Attempts = job.MaxAttempts
+		}
+		delay := w.retryDelay(job.Attempts)
+		log.Printf("[Extraction] Job %d (sách %d) lỗi lần %d/%d: %v", job.ID, job.BookID, job.Attempts, job.MaxAttempts, err)
+		if err := models.FailExtractionJob(w.DB, job, err, delay); err != nil {
+			log.Printf("[Extraction] Không lưu được trạng thái job %d: %v", job.ID, err)
+		}
+		return true, nil
+	}
+
+	if err := models.CompleteExtractionJob(w.DB, job, keywords, tocTitles); err != nil {
+		log.Printf("[Extraction] Không lưu được kết quả job %d: %v", job.ID, err)
+	}
+	return true, nil
+}
+
+// jobPanicError là panic xảy ra khi xử lý một job
+type jobPanicError struct {
+	value interface{}
+}
+
+func (e *jobPanicError) Error() string {
+	return fmt.Sprintf("panic khi xử lý job: %v", e.value)
+}
+
+// processSafe gọi process và đổi panic thành jobPanicError, một file hỏng không làm sập worker
+func (w *Worker) processSafe(ctx context.Context, job *models.ExtractionJob) (keywords, tocTitles []string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			keywords, tocTitles, err = nil, nil, &jobPanicError{value: r}
+		}
+	}()
+	return w.process(ctx, job)
+}
+
+func (w *Worker) process(ctx context.Context, job *models.ExtractionJob) ([]string, []string, error) {
+	var book models.Book
+	if err := w.DB.First(&book, job.BookID).Error; err != nil {
+		return nil, nil, fmt.Errorf("không tìm thấy sách: %w", err)
+	}
+	file, err := models.FindBookFile(w.DB, &book, models.FormatPDF)
+	if err != nil {
+		return nil, nil, fmt.Errorf("không tìm thấy file PDF: %w", err)
+	}
+
+	pdfPath, err := w.download(ctx, file.StorageKey())
+	if err != nil {
+		return nil, nil, err
+	}
+	defer os.Remove(pdfPath)
+
+	result, err := w.Extractor.Extract(ctx, pdfPath, Request{
+		Title:    book.Title,
+		Author:   book.Author,
+		Topic:    string(book.Category),
+		TOCPages: job.TOCPages,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return result.Keywords, result.TOCTitles, nil
+}
+
+// download tải file PDF từ storage về file tạm (dịch vụ trích xuất cần file hoàn chỉnh)
+func (w *Worker) download(ctx context.Context, key string) (string, error) {
+	src, _, err := w.Blob.Get(ctx, key)
+	if err != nil {
+		return "", fmt.Errorf("đọc file PDF từ storage thất bại: %w", err)
+	}
+	defer src.Close()
+
+	tmp, err := os.CreateTemp("", "extract-*.pdf")
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(tmp, src); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return tmp.Name(), nil
+}
+
+// retryDelay là backoff lũy thừa theo số lần đã thử, có giới hạn trên và jitter ±20%
+func (w *Worker) retryDelay(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	delay := w.RetryMax
+	if attempt <= 16 {
+		if d := w.RetryBase << (attempt - 1); d < w.RetryMax {
+			delay = d
+		}
+	}
+	if delay <= 0 {
+		return 0
+	}
+	jitter := time.Duration(rand.Int63n(int64(delay)/5*2+1)) - delay/5
+	return delay + jitter
+}
@@ -0,0 +1,92 @@
+package imaging
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	_ "image/jpeg" // Đăng ký decoder cho image.Decode
+	_ "image/png"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/Poloni84Learning/ebook-store/pdf"
+)
+
+const (
+	renderTimeout  = 30 * time.Second // Thời gian tối đa cho mỗi lần gọi công cụ render ngoài
+	maxEmbeddedPix = 40_000_000       // Không giải mã ảnh nhúng lớn hơn số pixel này
+)
+
+// RenderFirstPage dựng ảnh trang đầu của file PDF với chiều rộng width.
+// Thứ tự thử: pdftoppm (poppler-utils), mutool (MuPDF) nếu có trên máy, cuối cùng là
+// ảnh JPEG lớn nhất nhúng trong trang đầu (pure Go, không cần công cụ ngoài).
+func RenderFirstPage(ctx context.Context, pdfPath string, width int) (image.Image, error) {
+	tmpDir, err := os.MkdirTemp("", "pdfcover-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+	out := filepath.Join(tmpDir, "cover")
+
+	renderers := []struct {
+		name string
+		args []string
+		file string
+	}{
+		{"pdftoppm", []string{"-f", "1", "-l", "1", "-singlefile", "-png", "-scale-to-x", strconv.Itoa(width), "-scale-to-y", "-1", pdfPath, out}, out + ".png"},
+		{"mutool", []string{"draw", "-q", "-w", strconv.Itoa(width), "-F", "png", "-o", out + ".png", pdfPath, "1"}, out + ".png"},
+	}
+	for _, r := range renderers {
+		bin, err := exec.LookPath(r.name)
+		if err != nil {
+			continue
+		}
+		img, err := runRenderer(ctx, bin, r.args, r.file)
+		if err == nil {
+			return img, nil
+		}
+		log.Printf("[Cover] %s không render được %s: %v", r.name, pdfPath, err)
+	}
+
+	src, err := os.ReadFile(pdfPath)
+	if err != nil {
+		return nil, err
+	}
+	data, err := pdf.FirstPageImage(src)
+	if err != nil {
+		return nil, err
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("decode embedded image: %w", err)
+	}
+	if config.Width*config.Height > maxEmbeddedPix {
+		return nil, fmt.Errorf("embedded image too large: %dx%d", config.Width, config.Height)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("decode embedded image: %w", err)
+	}
+	return img, nil
+}
+
+func runRenderer(ctx context.Context, bin string, args []string, file string) (image.Image, error) {
+	ctx, cancel := context.WithTimeout(ctx, renderTimeout)
+	defer cancel()
+
+	if output, err := exec.CommandContext(ctx, bin, args...).CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("%w: %s", err, bytes.TrimSpace(output))
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	return img, err
+}
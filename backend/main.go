@@ -6,7 +6,9 @@ import (
 	"time"
 
 	"github.com/Poloni84Learning/ebook-store/config"
+	"github.com/Poloni84Learning/ebook-store/extraction"
 	"github.com/Poloni84Learning/ebook-store/models"
+	"github.com/Poloni84Learning/ebook-store/recommendation"
 	"github.com/Poloni84Learning/ebook-store/routes"
 	"github.com/Poloni84Learning/ebook-store/seeds"
 	"github.com/Poloni84Learning/ebook-store/utils"
@@ -37,6 +39,14 @@ func main() {
 	// Khởi động GC dọn token hết hạn (chạy ngầm mỗi 10 phút)
 	utils.StartTokenBlacklistGC(10 * time.Minute)
 
+	// Khởi động worker trích xuất keyword/mục lục từ PDF
+	if err := extraction.StartWorker(db, cfg); err != nil {
+		log.Fatalf("Failed to start extraction worker: %v", err)
+	}
+
+	// Tính lại độ tương đồng sách cho API gợi ý, định kỳ
+	recommendation.StartWorker(db, cfg)
+
 	// Chạy server
 	runServer(router, cfg)
 }
@@ -82,12 +92,25 @@ func autoMigrate(db *gorm.DB) {
 	modelsToMigrate := []interface{}{
 		&models.User{},
 		&models.Book{},
+		&models.BookFile{},
 		&models.Order{},
 		&models.OrderItem{},
+		&models.OrderStatusHistory{},
+		&models.Payment{},
 		&models.Review{},
 		&models.SystemConfig{},
 		&models.BookCombo{},
 		&models.ComboItem{},
+		&models.Cart{},
+		&models.CartItem{},
+		&models.Coupon{},
+		&models.CouponRedemption{},
+		&models.LibraryEntitlement{},
+		&models.DownloadLog{},
+		&models.ExtractionJob{},
+		&models.SearchLog{},
+		&models.SearchClick{},
+		&models.BookSimilarity{},
 	}
 
 	for _, model := range modelsToMigrate {
@@ -95,6 +118,11 @@ func autoMigrate(db *gorm.DB) {
 			log.Fatalf("Failed to auto-migrate model: %v", err)
 		}
 	}
+
+	// Cột tsvector + index GIN cho tìm kiếm toàn văn (cần bảng books đã migrate)
+	if err := models.SetupBookSearch(db); err != nil {
+		log.Fatalf("Failed to set up book search: %v", err)
+	}
 	log.Println("Auto migration completed")
 }
 
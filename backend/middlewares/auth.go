@@ -37,12 +37,7 @@ func JWTAuthMiddleware(cfg *config.Config) gin.HandlerFunc {
 			return
 		}
 		// Parse và validate token
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, jwt.ErrSignatureInvalid
-			}
-			return []byte(cfg.JWTSecret), nil
-		})
+		token, err := parseToken(tokenString, cfg)
 
 		if err != nil {
 			log.Printf("[DEBUG] Token parse error: %v", err)
@@ -70,6 +65,35 @@ func JWTAuthMiddleware(cfg *config.Config) gin.HandlerFunc {
 	}
 }
 
+// OptionalJWTAuthMiddleware dùng cho route public cần biết người dùng (ví dụ ghi log tìm kiếm):
+// token hợp lệ thì lưu thông tin user vào context, thiếu hoặc sai token thì xử lý như khách, không trả 401
+func OptionalJWTAuthMiddleware(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tokenString := utils.ExtractToken(c)
+		if tokenString == "" || utils.IsTokenBlacklisted(tokenString) {
+			c.Next()
+			return
+		}
+		token, err := parseToken(tokenString, cfg)
+		if err == nil && token.Valid {
+			if claims, ok := token.Claims.(jwt.MapClaims); ok {
+				setUserContext(c, claims, cfg)
+			}
+		}
+		c.Next()
+	}
+}
+
+// parseToken parse và kiểm tra chữ ký token (chỉ chấp nhận HMAC)
+func parseToken(tokenString string, cfg *config.Config) (*jwt.Token, error) {
+	return jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, jwt.ErrSignatureInvalid
+		}
+		return []byte(cfg.JWTSecret), nil
+	})
+}
+
 // isPublicRoute kiểm tra route có public không
 func isPublicRoute(c *gin.Context) bool {
 	publicPaths := map[string]bool{
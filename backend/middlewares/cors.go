@@ -19,7 +19,7 @@ func CORSMiddleware() gin.HandlerFunc {
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
 		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Expose-Headers", "Content-Length, X-Total-Count")
+		c.Writer.Header().Set("Access-Control-Expose-Headers", "Content-Length, X-Total-Count, X-Search-ID")
 
 		// Nếu là preflight request (OPTIONS), trả về status 204
 		if c.Request.Method == "OPTIONS" {
@@ -47,7 +47,7 @@ func DynamicCORSMiddleware(allowedOrigins []string) gin.HandlerFunc {
 		// Các header còn lại giống CORSMiddleware cơ bản
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
 		c.Writer.Header().Set("Access-Control-Allow-Headers", "Origin, Content-Type, Content-Length, Accept-Encoding, Authorization, X-Requested-With")
-		c.Writer.Header().Set("Access-Control-Expose-Headers", "Content-Length, X-Total-Count")
+		c.Writer.Header().Set("Access-Control-Expose-Headers", "Content-Length, X-Total-Count, X-Search-ID")
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
 
 		if c.Request.Method == "OPTIONS" {
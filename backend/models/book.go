@@ -48,10 +48,16 @@ type Book struct {
 	PublishedAt   string       `json:"published_at"`
 	AverageRating float64      `gorm:"type:decimal(3,2);default:0" json:"average_rating"`
 
-	PDFUrl    string         `gorm:"size:255" json:"pdf_url,omitempty"` // <<< Trường URL PDF
-	Keywords  pq.StringArray `gorm:"type:text[]" json:"keywords"`       // Sử dụng pq.StringArray
-	TOCTitles pq.StringArray `gorm:"type:text[]" json:"toc_titles"`     // <<< Thay đổi kiểu thành array
+	CoverThumbnails map[string]string `gorm:"type:jsonb;serializer:json" json:"-"` // URL ảnh thu nhỏ: small, medium, large
+	CoverImages     map[string]string `gorm:"-" json:"cover_images,omitempty"`     // Gồm cả "original", dùng cho srcset
 
+	PDFUrl    string         `gorm:"size:255" json:"-"`             // Đường dẫn file PDF trong storage, không trả về client
+	Keywords  pq.StringArray `gorm:"type:text[]" json:"keywords"`   // Sử dụng pq.StringArray
+	TOCTitles pq.StringArray `gorm:"type:text[]" json:"toc_titles"` // <<< Thay đổi kiểu thành array
+
+	ExtractionStatus ExtractionStatus `gorm:"size:20;index" json:"extraction_status,omitempty"` // Trạng thái trích xuất keyword/mục lục
+
+	Files      []BookFile  `gorm:"foreignKey:BookID" json:"files,omitempty"`
 	OrderItems []OrderItem `gorm:"foreignKey:BookID" json:"-"`
 	Reviews    []Review    `gorm:"foreignKey:BookID" json:"-"`
 }
@@ -86,6 +92,8 @@ type BookResponse struct {
 	Language      string       `json:"language,omitempty"`
 	AverageRating float64      `json:"average_rating"`
 	CreatedAt     time.Time    `json:"created_at"`
+
+	CoverImages map[string]string `json:"cover_images,omitempty"`
 }
 
 func (b *Book) ToResponse() *BookResponse {
@@ -104,6 +112,7 @@ func (b *Book) ToResponse() *BookResponse {
 		Language:      b.Language,
 		AverageRating: b.AverageRating,
 		CreatedAt:     b.CreatedAt,
+		CoverImages:   CoverImageSet(b.CoverImage, b.CoverThumbnails),
 	}
 }
 
@@ -132,7 +141,7 @@ func SafeDeleteBook(db *gorm.DB, bookID uint) error {
 	// Kiểm tra xem sách có nằm trong Order chưa xử lý không
 	err := db.Model(&OrderItem{}).
 		Joins("JOIN orders ON orders.id = order_items.order_id").
-		Where("order_items.book_id = ? AND orders.status IN ?", bookID, []string{"pending", "confirmed"}).
+		Where("order_items.book_id = ? AND orders.status IN ?", bookID, []OrderStatus{OrderStatusPending, OrderStatusProcessing}).
 		Count(&count).Error
 
 	if err != nil {
@@ -0,0 +1,378 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/Poloni84Learning/ebook-store/utils"
+	"gorm.io/gorm"
+)
+
+// Duyệt danh mục sách: bộ lọc kết hợp (AND giữa các chiều, OR trong cùng một chiều),
+// sắp xếp, phân trang bằng cursor (keyset) và đếm facet cho từng chiều lọc.
+
+type CatalogSort string
+
+const (
+	CatalogSortNewest      CatalogSort = "newest"
+	CatalogSortPriceAsc    CatalogSort = "price_asc"
+	CatalogSortPriceDesc   CatalogSort = "price_desc"
+	CatalogSortRating      CatalogSort = "rating"
+	CatalogSortBestSelling CatalogSort = "best_selling"
+)
+
+// Biểu thức sắp xếp, kiểu để ép giá trị cursor và chiều sắp xếp; books.id luôn là khóa phụ
+var catalogSorts = map[CatalogSort]struct {
+	expr string
+	cast string
+	desc bool
+}{
+	CatalogSortNewest:      {"books.created_at", "timestamptz", true},
+	CatalogSortPriceAsc:    {"books.price", "numeric", false},
+	CatalogSortPriceDesc:   {"books.price", "numeric", true},
+	CatalogSortRating:      {"COALESCE(books.average_rating, 0)", "numeric", true},
+	CatalogSortBestSelling: {"COALESCE(sales.sold, 0)", "bigint", true},
+}
+
+func (s CatalogSort) IsValid() bool {
+	_, ok := catalogSorts[s]
+	return ok
+}
+
+// Số lượng đã bán tính trên đơn hàng đã hoàn thành
+const bookSalesJoin = `LEFT JOIN (
+	SELECT order_items.book_id, SUM(order_items.quantity) AS sold
+	FROM order_items JOIN orders ON orders.id = order_items.order_id
+	WHERE orders.status = 'completed' AND orders.deleted_at IS NULL
+	GROUP BY order_items.book_id
+) sales ON sales.book_id = books.id`
+
+// Năm xuất bản lấy từ 4 chữ số đầu tiên trong published_at (trường nhập tự do)
+const bookPublishedYearSQL = "CAST(substring(books.published_at FROM '[0-9]{4}') AS integer)"
+
+// Các chiều lọc, dùng để bỏ qua bộ lọc của chính chiều đó khi đếm facet
+const (
+	FacetCategory  = "category"
+	FacetLanguage  = "language"
+	FacetPublisher = "publisher"
+	FacetPrice     = "price"
+	FacetRating    = "rating"
+	FacetYear      = "year"
+	FacetInStock   = "in_stock"
+)
+
+type CatalogFilter struct {
+	Categories []BookCategory
+	Languages  []string
+	Publishers []string
+	MinPrice   *float64
+	MaxPrice   *float64
+	MinRating  *float64
+	InStock    bool
+	YearFrom   *int
+	YearTo     *int
+	Title      string // Chứa chuỗi, không phân biệt dấu, dùng cho /books/by-title
+	Author     string // Chứa chuỗi, không phân biệt dấu, dùng cho /books/by-author
+}
+
+// Apply thêm điều kiện lọc vào query trên bảng books, trừ chiều except (chuỗi rỗng: áp dụng tất cả)
+func (f *CatalogFilter) Apply(db *gorm.DB, except string) *gorm.DB {
+	if len(f.Categories) > 0 && except != FacetCategory {
+		db = db.Where("books.category IN ?", f.Categories)
+	}
+	if len(f.Languages) > 0 && except != FacetLanguage {
+		db = db.Where("books.language IN ?", f.Languages)
+	}
+	if len(f.Publishers) > 0 && except != FacetPublisher {
+		db = db.Where("books.publisher IN ?", f.Publishers)
+	}
+	if except != FacetPrice {
+		if f.MinPrice != nil {
+			db = db.Where("books.price >= ?", *f.MinPrice)
+		}
+		if f.MaxPrice != nil {
+			db = db.Where("books.price <= ?", *f.MaxPrice)
+		}
+	}
+	if f.MinRating != nil && except != FacetRating {
+		db = db.Where("books.average_rating >= ?", *f.MinRating)
+	}
+	if f.InStock && except != FacetInStock {
+		db = db.Where("books.stock > 0")
+	}
+	if except != FacetYear {
+		if f.YearFrom != nil {
+			db = db.Where(bookPublishedYearSQL+" >= ?", *f.YearFrom)
+		}
+		if f.YearTo != nil {
+			db = db.Where(bookPublishedYearSQL+" <= ?", *f.YearTo)
+		}
+	}
+	if f.Title != "" {
+		db = db.Where("fold_text(books.title) LIKE ?", utils.FoldedContains(f.Title))
+	}
+	if f.Author != "" {
+		db = db.Where("fold_text(books.author) LIKE ?", utils.FoldedContains(f.Author))
+	}
+	return db
+}
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// catalogCursor là vị trí dòng cuối của trang trước; client nhận dưới dạng chuỗi base64 không cần hiểu
+type catalogCursor struct {
+	Sort  CatalogSort `json:"s"`
+	Value string      `json:"v"`
+	ID    uint        `json:"id"`
+}
+
+func encodeCatalogCursor(cursor catalogCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCatalogCursor(s string, sort CatalogSort) (*catalogCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var cursor catalogCursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.ID == 0 {
+		return nil, ErrInvalidCursor
+	}
+	// Cursor chỉ có nghĩa với cách sắp xếp đã tạo ra nó
+	if cursor.Sort != sort {
+		return nil, ErrInvalidCursor
+	}
+	return &cursor, nil
+}
+
+type CatalogPage struct {
+	Books      []Book
+	Total      int64
+	NextCursor string // Rỗng khi đã hết dữ liệu
+}
+
+// QueryCatalog trả về một trang sách theo bộ lọc và cách sắp xếp, bắt đầu sau cursor (nếu có)
+func QueryCatalog(db *gorm.DB, filter *CatalogFilter, sort CatalogSort, cursor string, limit int) (*CatalogPage, error) {
+	spec, ok := catalogSorts[sort]
+	if !ok {
+		return nil, errors.New("invalid sort")
+	}
+
+	page := &CatalogPage{Books: []Book{}}
+	if err := filter.Apply(db.Model(&Book{}), "").Count(&page.Total).Error; err != nil {
+		return nil, err
+	}
+
+	query := filter.Apply(db.Model(&Book{}), "")
+	if sort == CatalogSortBestSelling {
+		query = query.Joins(bookSalesJoin)
+	}
+	if cursor != "" {
+		after, err := decodeCatalogCursor(cursor, sort)
+		if err != nil {
+			return nil, err
+		}
+		op := ">"
+		if spec.desc {
+			op = "<"
+		}
+		value := "CAST(? AS " + spec.cast + ")"
+		query = query.Where("("+spec.expr+" "+op+" "+value+" OR ("+spec.expr+" = "+value+" AND books.id "+op+" ?))",
+			after.Value, after.Value, after.ID)
+	}
+	direction := " ASC"
+	if spec.desc {
+		direction = " DESC"
+	}
+
+	// Lấy id và khóa sắp xếp (dạng text để cursor giữ nguyên độ chính xác) trước, thêm một dòng để biết còn trang sau
+	var keys []struct {
+		ID      uint
+		SortKey string
+	}
+	err := query.Select("books.id, (" + spec.expr + ")::text AS sort_key").
+		Order(spec.expr + direction).Order("books.id" + direction).
+		Limit(limit + 1).
+		Scan(&keys).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) > limit {
+		keys = keys[:limit]
+		last := keys[len(keys)-1]
+		page.NextCursor = encodeCatalogCursor(catalogCursor{Sort: sort, Value: last.SortKey, ID: last.ID})
+	}
+	if len(keys) == 0 {
+		return page, nil
+	}
+
+	ids := make([]uint, len(keys))
+	for i, key := range keys {
+		ids[i] = key.ID
+	}
+	var books []Book
+	if err := db.Where("id IN ?", ids).Find(&books).Error; err != nil {
+		return nil, err
+	}
+	byID := make(map[uint]Book, len(books))
+	for _, book := range books {
+		byID[book.ID] = book
+	}
+	for _, id := range ids {
+		if book, ok := byID[id]; ok {
+			page.Books = append(page.Books, book)
+		}
+	}
+	return page, nil
+}
+
+type FacetValue struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+type YearFacet struct {
+	Year  int   `json:"year"`
+	Count int64 `json:"count"`
+}
+
+type PriceRangeFacet struct {
+	Min   float64  `json:"min"`
+	Max   *float64 `json:"max,omitempty"` // nil: không giới hạn trên
+	Count int64    `json:"count"`
+}
+
+type RatingFacet struct {
+	MinRating float64 `json:"min_rating"`
+	Count     int64   `json:"count"`
+}
+
+// CatalogFacets là số sách theo từng giá trị của mỗi chiều lọc. Mỗi chiều được đếm với
+// các bộ lọc còn lại (không tính bộ lọc của chính nó) để client chọn nhiều giá trị cùng lúc.
+type CatalogFacets struct {
+	Categories     []FacetValue      `json:"categories"`
+	Languages      []FacetValue      `json:"languages"`
+	Publishers     []FacetValue      `json:"publishers"`
+	PriceRanges    []PriceRangeFacet `json:"price_ranges"`
+	Ratings        []RatingFacet     `json:"ratings"`
+	PublishedYears []YearFacet       `json:"published_years"`
+	InStock        int64             `json:"in_stock"`
+}
+
+// Khoảng giá của facet [min, max); khoảng cuối không giới hạn trên
+var catalogPriceBounds = []float64{0, 10, 20, 50, 100}
+
+// Mức đánh giá tối thiểu của facet (đếm lũy tiến, khớp với bộ lọc min_rating)
+var catalogRatingLevels = []float64{4, 3, 2, 1}
+
+const catalogMaxFacetValues = 50
+
+func CatalogFacetCounts(db *gorm.DB, filter *CatalogFilter) (*CatalogFacets, error) {
+	facets := &CatalogFacets{
+		Categories:     []FacetValue{},
+		Languages:      []FacetValue{},
+		Publishers:     []FacetValue{},
+		PublishedYears: []YearFacet{},
+	}
+
+	valueFacets := []struct {
+		dimension string
+		column    string
+		dest      *[]FacetValue
+	}{
+		{FacetCategory, "books.category", &facets.Categories},
+		{FacetLanguage, "books.language", &facets.Languages},
+		{FacetPublisher, "books.publisher", &facets.Publishers},
+	}
+	for _, facet := range valueFacets {
+		err := filter.Apply(db.Model(&Book{}), facet.dimension).
+			Select(facet.column + " AS value, COUNT(*) AS count").
+			Where("COALESCE(" + facet.column + ", '') <> ''").
+			Group(facet.column).
+			Order("count DESC, value").
+			Limit(catalogMaxFacetValues).
+			Scan(facet.dest).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err := filter.Apply(db.Model(&Book{}), FacetYear).
+		Select(bookPublishedYearSQL + " AS year, COUNT(*) AS count").
+		Where(bookPublishedYearSQL + " IS NOT NULL").
+		Group("year").
+		Order("year DESC").
+		Limit(catalogMaxFacetValues).
+		Scan(&facets.PublishedYears).Error
+	if err != nil {
+		return nil, err
+	}
+
+	// Các khoảng giá và mức đánh giá đếm trong một câu lệnh bằng FILTER
+	var selects []string
+	var args []interface{}
+	for i, min := range catalogPriceBounds {
+		if i+1 < len(catalogPriceBounds) {
+			selects = append(selects, "COUNT(*) FILTER (WHERE books.price >= ? AND books.price < ?)")
+			args = append(args, min, catalogPriceBounds[i+1])
+		} else {
+			selects = append(selects, "COUNT(*) FILTER (WHERE books.price >= ?)")
+			args = append(args, min)
+		}
+	}
+	priceCounts, err := scanCounts(filter.Apply(db.Model(&Book{}), FacetPrice), selects, args)
+	if err != nil {
+		return nil, err
+	}
+	for i, min := range catalogPriceBounds {
+		facet := PriceRangeFacet{Min: min, Count: priceCounts[i]}
+		if i+1 < len(catalogPriceBounds) {
+			max := catalogPriceBounds[i+1]
+			facet.Max = &max
+		}
+		facets.PriceRanges = append(facets.PriceRanges, facet)
+	}
+
+	selects, args = nil, nil
+	for _, level := range catalogRatingLevels {
+		selects = append(selects, "COUNT(*) FILTER (WHERE books.average_rating >= ?)")
+		args = append(args, level)
+	}
+	ratingCounts, err := scanCounts(filter.Apply(db.Model(&Book{}), FacetRating), selects, args)
+	if err != nil {
+		return nil, err
+	}
+	for i, level := range catalogRatingLevels {
+		facets.Ratings = append(facets.Ratings, RatingFacet{MinRating: level, Count: ratingCounts[i]})
+	}
+
+	if err := filter.Apply(db.Model(&Book{}), FacetInStock).Where("books.stock > 0").Count(&facets.InStock).Error; err != nil {
+		return nil, err
+	}
+	return facets, nil
+}
+
+// scanCounts chạy một SELECT gồm nhiều biểu thức COUNT và trả về kết quả theo thứ tự
+func scanCounts(query *gorm.DB, selects []string, args []interface{}) ([]int64, error) {
+	rows, err := query.Select(strings.Join(selects, ", "), args...).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make([]int64, len(selects))
+	dest := make([]interface{}, len(counts))
+	for i := range counts {
+		dest[i] = &counts[i]
+	}
+	if rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+	}
+	return counts, rows.Err()
+}
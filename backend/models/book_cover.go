@@ -0,0 +1,54 @@
+package models
+
+import "gorm.io/gorm"
+
+// CoverSize là một kích thước ảnh bìa thu nhỏ (theo chiều rộng, pixel)
+type CoverSize struct {
+	Name  string
+	Width int
+}
+
+var CoverSizes = []CoverSize{
+	{Name: "small", Width: 160},
+	{Name: "medium", Width: 320},
+	{Name: "large", Width: 640},
+}
+
+// CoverImageSet trả về URL ảnh bìa theo từng kích thước (kèm "original") cho srcset.
+// Kích thước chưa có bản thu nhỏ (ảnh cũ, ảnh nhỏ hơn kích thước đó) dùng ảnh gốc.
+func CoverImageSet(cover string, thumbnails map[string]string) map[string]string {
+	if cover == "" {
+		return nil
+	}
+	set := map[string]string{"original": cover}
+	for _, size := range CoverSizes {
+		if url, ok := thumbnails[size.Name]; ok {
+			set[size.Name] = url
+		} else {
+			set[size.Name] = cover
+		}
+	}
+	return set
+}
+
+// BookCover nhúng vào các struct danh sách sách đọc bằng Scan (select thêm books.cover_thumbnails)
+type BookCover struct {
+	CoverImage      string            `json:"cover_image"`
+	CoverThumbnails map[string]string `gorm:"serializer:json" json:"-"`
+	CoverImages     map[string]string `gorm:"-" json:"cover_images,omitempty"`
+}
+
+// ResolveCoverImages điền CoverImages sau khi Scan (Scan không chạy hook AfterFind)
+func (c *BookCover) ResolveCoverImages() {
+	c.CoverImages = CoverImageSet(c.CoverImage, c.CoverThumbnails)
+}
+
+func (b *Book) AfterFind(tx *gorm.DB) error {
+	b.CoverImages = CoverImageSet(b.CoverImage, b.CoverThumbnails)
+	return nil
+}
+
+func (b *Book) AfterSave(tx *gorm.DB) error {
+	b.CoverImages = CoverImageSet(b.CoverImage, b.CoverThumbnails)
+	return nil
+}
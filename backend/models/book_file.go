@@ -0,0 +1,87 @@
+package models
+
+import (
+	"errors"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+type BookFormat string
+
+const (
+	FormatPDF  BookFormat = "pdf"
+	FormatEPUB BookFormat = "epub"
+	FormatMOBI BookFormat = "mobi"
+)
+
+// BookFormatSpec mô tả MIME type hợp lệ (theo nội dung file) của từng định dạng
+type BookFormatSpec struct {
+	MimeType  string
+	Extension string
+}
+
+var BookFormats = map[BookFormat]BookFormatSpec{
+	FormatPDF:  {MimeType: "application/pdf", Extension: ".pdf"},
+	FormatEPUB: {MimeType: "application/epub+zip", Extension: ".epub"},
+	FormatMOBI: {MimeType: "application/x-mobipocket-ebook", Extension: ".mobi"},
+}
+
+var ErrBookFileNotFound = errors.New("book file not found")
+
+func (f BookFormat) IsValid() bool {
+	_, ok := BookFormats[f]
+	return ok
+}
+
+// BookFile là một định dạng tải về của sách
+type BookFile struct {
+	gorm.Model
+	BookID       uint       `gorm:"not null;index:idx_book_format,unique,where:deleted_at is null" json:"book_id"`
+	Format       BookFormat `gorm:"type:varchar(10);not null;index:idx_book_format,unique,where:deleted_at is null" json:"format"`
+	URL          string     `gorm:"size:255;not null" json:"-"`
+	MimeType     string     `gorm:"size:100;not null" json:"mime_type"`
+	Size         int64      `gorm:"not null" json:"size"`
+	Checksum     string     `gorm:"size:64;not null" json:"checksum"` // SHA-256 hex
+	OriginalName string     `gorm:"size:255" json:"-"`                // Không trả về để không lộ tên file của người upload
+}
+
+// StorageKey trả về key của file trong storage (URL lưu dạng /storage/<key>)
+func (f *BookFile) StorageKey() string {
+	return strings.TrimPrefix(f.URL, "/storage/")
+}
+
+// FindBookFile trả về file theo định dạng; sách cũ chỉ có PDFUrl được coi như có file PDF
+func FindBookFile(db *gorm.DB, book *Book, format BookFormat) (*BookFile, error) {
+	var file BookFile
+	err := db.Where("book_id = ? AND format = ?", book.ID, format).First(&file).Error
+	if err == nil {
+		return &file, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	if format == FormatPDF && book.PDFUrl != "" {
+		return &BookFile{BookID: book.ID, Format: FormatPDF, URL: book.PDFUrl, MimeType: BookFormats[FormatPDF].MimeType}, nil
+	}
+	return nil, ErrBookFileNotFound
+}
+
+// AvailableFormats liệt kê các định dạng tải được của sách
+func AvailableFormats(db *gorm.DB, book *Book) ([]BookFormat, error) {
+	var formats []BookFormat
+	if err := db.Model(&BookFile{}).Where("book_id = ?", book.ID).Order("format").Pluck("format", &formats).Error; err != nil {
+		return nil, err
+	}
+	if book.PDFUrl != "" {
+		hasPDF := false
+		for _, f := range formats {
+			hasPDF = hasPDF || f == FormatPDF
+		}
+		if !hasPDF {
+			formats = append([]BookFormat{FormatPDF}, formats...)
+		}
+	}
+	return formats, nil
+}
@@ -0,0 +1,123 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Tìm kiếm toàn văn trên bảng books: cột sinh tự động books.search_vector (tsvector) có
+// trọng số theo trường, đánh index GIN. Mỗi trường được phân tích bằng hai cấu hình:
+// vi_unaccent (tách âm tiết, bỏ dấu, không stem) và en_unaccent (bỏ dấu + stem tiếng Anh).
+// Cột không nằm trong struct Book vì GORM không ghi được cột sinh tự động.
+
+const (
+	SearchConfigVI = "vi_unaccent"
+	SearchConfigEN = "en_unaccent"
+
+	// Đổi khi sửa biểu thức search_vector để SetupBookSearch tạo lại cột
+	bookSearchVersion = "search_vector v1"
+)
+
+// Trường được đánh index và trọng số (A cao nhất)
+var bookSearchFields = []struct {
+	expr   string
+	weight string
+}{
+	{"coalesce(title, '')", "A"},
+	{"coalesce(author, '')", "B"},
+	{"immutable_array_to_string(keywords)", "B"},
+	{"immutable_array_to_string(toc_titles)", "C"},
+	{"coalesce(category, '')", "C"},
+	{"coalesce(description, '')", "D"},
+}
+
+// BookSearchQuery là biểu thức tsquery cho tham số đặt tên @q, khớp cả hai cấu hình
+const BookSearchQuery = "websearch_to_tsquery('" + SearchConfigVI + "', @q) || websearch_to_tsquery('" + SearchConfigEN + "', @q)"
+
+// Index trigram trên chuỗi đã chuẩn hóa (fold_text) cho gợi ý và tìm kiếm gần đúng
+// (LIKE '%...%' và so khớp gần đúng của pg_trgm)
+var bookTrigramIndexes = []string{
+	`DROP INDEX IF EXISTS idx_books_title_trgm`,
+	`DROP INDEX IF EXISTS idx_books_author_trgm`,
+	`DROP INDEX IF EXISTS idx_books_keywords_trgm`,
+	`CREATE INDEX IF NOT EXISTS idx_books_title_fold_trgm ON books USING GIN (fold_text(title) gin_trgm_ops)`,
+	`CREATE INDEX IF NOT EXISTS idx_books_author_fold_trgm ON books USING GIN (fold_text(author) gin_trgm_ops)`,
+	`CREATE INDEX IF NOT EXISTS idx_books_keywords_fold_trgm ON books USING GIN (fold_text(immutable_array_to_string(keywords)) gin_trgm_ops)`,
+}
+
+// SetupBookSearch tạo extension, cấu hình text search, hàm fold_text, cột search_vector và index (idempotent)
+func SetupBookSearch(db *gorm.DB) error {
+	statements := []string{
+		`CREATE EXTENSION IF NOT EXISTS unaccent`,
+		`CREATE EXTENSION IF NOT EXISTS pg_trgm`,
+		textSearchConfigSQL(SearchConfigVI, "simple", "simple"),
+		textSearchConfigSQL(SearchConfigEN, "english", "english_stem"),
+		// array_to_string chỉ là STABLE, cột sinh tự động cần hàm IMMUTABLE
+		`CREATE OR REPLACE FUNCTION immutable_array_to_string(text[]) RETURNS text
+			LANGUAGE sql IMMUTABLE PARALLEL SAFE
+			AS $$ SELECT array_to_string(coalesce($1, '{}'::text[]), ' ') $$`,
+		// Bỏ dấu + chữ thường, dùng chung cho mọi tìm kiếm gần đúng (khớp với utils.FoldText).
+		// unaccent(text) chỉ là STABLE; chỉ rõ dictionary để khai báo IMMUTABLE và đánh index được.
+		`CREATE OR REPLACE FUNCTION fold_text(text) RETURNS text
+			LANGUAGE sql IMMUTABLE STRICT PARALLEL SAFE
+			AS $$ SELECT lower(public.unaccent('public.unaccent'::regdictionary, $1)) $$`,
+	}
+	for _, stmt := range statements {
+		if err := db.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+
+	var version string
+	if err := db.Raw(`SELECT coalesce(col_description('books'::regclass, attnum), '') FROM pg_attribute
+		WHERE attrelid = 'books'::regclass AND attname = 'search_vector' AND NOT attisdropped`).Scan(&version).Error; err != nil {
+		return err
+	}
+	if version != bookSearchVersion {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Exec(`ALTER TABLE books DROP COLUMN IF EXISTS search_vector`).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec(`ALTER TABLE books ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (` + bookSearchVectorSQL() + `) STORED`).Error; err != nil {
+				return err
+			}
+			return tx.Exec(`COMMENT ON COLUMN books.search_vector IS '` + bookSearchVersion + `'`).Error
+		})
+		if err != nil {
+			return fmt.Errorf("create books.search_vector: %w", err)
+		}
+	}
+	if err := db.Exec(`CREATE INDEX IF NOT EXISTS idx_books_search_vector ON books USING GIN (search_vector)`).Error; err != nil {
+		return err
+	}
+	for _, stmt := range bookTrigramIndexes {
+		if err := db.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// textSearchConfigSQL tạo cấu hình text search copy từ base, thêm bước bỏ dấu trước dictionary
+func textSearchConfigSQL(name, base, dictionary string) string {
+	return fmt.Sprintf(`DO $$
+BEGIN
+	IF NOT EXISTS (SELECT 1 FROM pg_ts_config WHERE cfgname = '%[1]s') THEN
+		CREATE TEXT SEARCH CONFIGURATION %[1]s (COPY = %[2]s);
+		ALTER TEXT SEARCH CONFIGURATION %[1]s
+			ALTER MAPPING FOR asciiword, asciihword, hword_asciipart, word, hword, hword_part WITH unaccent, %[3]s;
+	END IF;
+END $$`, name, base, dictionary)
+}
+
+func bookSearchVectorSQL() string {
+	var parts []string
+	for _, field := range bookSearchFields {
+		for _, cfg := range []string{SearchConfigVI, SearchConfigEN} {
+			parts = append(parts, fmt.Sprintf("setweight(to_tsvector('%s', %s), '%s')", cfg, field.expr, field.weight))
+		}
+	}
+	return strings.Join(parts, " || ")
+}
@@ -0,0 +1,124 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type SimilaritySource string
+
+const (
+	SimilarityCoPurchase SimilaritySource = "co_purchase" // Thường được mua cùng trong một đơn hoàn thành
+	SimilarityContent    SimilaritySource = "content"     // Cùng thể loại/tác giả/combo, chung keyword
+)
+
+// BookSimilarity là độ tương đồng từ sách BookID sang SimilarBookID, được job nền tính lại
+// định kỳ (RecomputeBookSimilarities). Mỗi sách chỉ giữ các sách tương đồng nhất theo từng nguồn.
+type BookSimilarity struct {
+	BookID        uint             `gorm:"primaryKey;autoIncrement:false" json:"book_id"`
+	SimilarBookID uint             `gorm:"primaryKey;autoIncrement:false;index" json:"similar_book_id"`
+	Source        SimilaritySource `gorm:"primaryKey;size:20" json:"source"`
+	Score         float64          `gorm:"not null" json:"score"`
+	Support       int              `gorm:"not null" json:"support"` // co_purchase: số đơn có cả hai sách; content: số keyword chung
+	ComputedAt    time.Time        `gorm:"not null" json:"computed_at"`
+}
+
+const (
+	similarityPerBook        = 20 // Số sách tương đồng giữ lại cho mỗi sách, mỗi nguồn
+	similarityMinCoPurchases = 2  // Cặp sách phải cùng xuất hiện ít nhất ngần này đơn
+	similarityLockKey        = 7325001
+)
+
+// Co-occurrence trong đơn hoàn thành, chuẩn hóa cosine: cùng mua / sqrt(đơn có A * đơn có B)
+const coPurchaseSimilaritySQL = `INSERT INTO book_similarities (book_id, similar_book_id, source, score, support, computed_at)
+WITH purchases AS (
+	SELECT DISTINCT order_items.order_id, order_items.book_id
+	FROM order_items JOIN orders ON orders.id = order_items.order_id
+	WHERE orders.status = 'completed' AND orders.deleted_at IS NULL AND order_items.deleted_at IS NULL
+),
+book_orders AS (
+	SELECT book_id, COUNT(*) AS orders FROM purchases GROUP BY book_id
+),
+pairs AS (
+	SELECT a.book_id, b.book_id AS similar_book_id, COUNT(*) AS support
+	FROM purchases a JOIN purchases b ON b.order_id = a.order_id AND b.book_id <> a.book_id
+	GROUP BY a.book_id, b.book_id
+	HAVING COUNT(*) >= @min_support
+),
+ranked AS (
+	SELECT pairs.book_id, pairs.similar_book_id, pairs.support,
+		pairs.support / sqrt(ba.orders * bb.orders) AS score
+	FROM pairs
+	JOIN book_orders ba ON ba.book_id = pairs.book_id
+	JOIN book_orders bb ON bb.book_id = pairs.similar_book_id
+)
+SELECT book_id, similar_book_id, 'co_purchase', score, support, NOW()
+FROM (
+	SELECT ranked.*, row_number() OVER (PARTITION BY book_id ORDER BY score DESC, similar_book_id) AS rn FROM ranked
+) r
+WHERE rn <= @per_book`
+
+// Tương đồng nội dung: Jaccard trên keyword (đã bỏ dấu) cộng điểm khi cùng thể loại,
+// cùng tác giả hoặc cùng nằm trong một combo đang bán
+const contentSimilaritySQL = `INSERT INTO book_similarities (book_id, similar_book_id, source, score, support, computed_at)
+WITH combo_pairs AS (
+	SELECT DISTINCT a.book_id, b.book_id AS similar_book_id
+	FROM combo_items a
+	JOIN combo_items b ON b.combo_id = a.combo_id AND b.book_id <> a.book_id
+	JOIN book_combos ON book_combos.id = a.combo_id
+	WHERE book_combos.deleted_at IS NULL AND a.deleted_at IS NULL AND b.deleted_at IS NULL
+		AND NOT a.is_hidden AND NOT b.is_hidden
+),
+candidates AS (
+	SELECT a.id AS book_id, b.id AS similar_book_id,
+		cardinality(ARRAY(SELECT fold_text(k) FROM unnest(a.keywords) k INTERSECT SELECT fold_text(k) FROM unnest(b.keywords) k)) AS shared,
+		cardinality(ARRAY(SELECT fold_text(k) FROM unnest(a.keywords) k UNION SELECT fold_text(k) FROM unnest(b.keywords) k)) AS total,
+		a.category = b.category AS same_category,
+		fold_text(a.author) = fold_text(b.author) AS same_author,
+		combo_pairs.book_id IS NOT NULL AS same_combo
+	FROM books a
+	JOIN books b ON b.id <> a.id AND b.deleted_at IS NULL
+	LEFT JOIN combo_pairs ON combo_pairs.book_id = a.id AND combo_pairs.similar_book_id = b.id
+	WHERE a.deleted_at IS NULL
+		AND (a.category = b.category OR a.keywords && b.keywords OR fold_text(a.author) = fold_text(b.author) OR combo_pairs.book_id IS NOT NULL)
+),
+scored AS (
+	SELECT book_id, similar_book_id, shared,
+		CASE WHEN total > 0 THEN shared::float8 / total ELSE 0 END
+		+ CASE WHEN same_category THEN 0.3 ELSE 0 END
+		+ CASE WHEN same_author THEN 0.3 ELSE 0 END
+		+ CASE WHEN same_combo THEN 0.4 ELSE 0 END AS score
+	FROM candidates
+)
+SELECT book_id, similar_book_id, 'content', score, shared, NOW()
+FROM (
+	SELECT scored.*, row_number() OVER (PARTITION BY book_id ORDER BY score DESC, similar_book_id) AS rn FROM scored
+) r
+WHERE rn <= @per_book AND score > 0`
+
+// RecomputeBookSimilarities tính lại toàn bộ bảng book_similarities trong một transaction
+// (người dùng vẫn đọc bản cũ cho đến khi commit). Trả về false nếu instance khác đang tính.
+func RecomputeBookSimilarities(db *gorm.DB) (bool, error) {
+	ran := false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var locked bool
+		if err := tx.Raw("SELECT pg_try_advisory_xact_lock(?)", similarityLockKey).Scan(&locked).Error; err != nil {
+			return err
+		}
+		if !locked {
+			return nil
+		}
+		ran = true
+
+		if err := tx.Exec("DELETE FROM book_similarities").Error; err != nil {
+			return err
+		}
+		args := map[string]interface{}{"per_book": similarityPerBook, "min_support": similarityMinCoPurchases}
+		if err := tx.Exec(coPurchaseSimilaritySQL, args).Error; err != nil {
+			return err
+		}
+		return tx.Exec(contentSimilaritySQL, args).Error
+	})
+	return ran, err
+}
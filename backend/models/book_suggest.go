@@ -0,0 +1,91 @@
+package models
+
+import (
+	"github.com/Poloni84Learning/ebook-store/utils"
+	"gorm.io/gorm"
+)
+
+// Gợi ý tìm kiếm (autocomplete) dựa trên pg_trgm, không phân biệt dấu: so khớp trên fold_text(...)
+// bằng LIKE '%...%' và so khớp gần đúng đều dùng được các index trigram tạo trong SetupBookSearch.
+
+type TitleSuggestion struct {
+	ID     uint   `json:"id"`
+	Title  string `json:"title"`
+	Author string `json:"author"`
+}
+
+type SearchSuggestions struct {
+	Titles     []TitleSuggestion `json:"titles"`
+	Authors    []string          `json:"authors"`
+	Keywords   []string          `json:"keywords"`
+	DidYouMean []string          `json:"did_you_mean"` // Tên tác giả gần đúng khi q có vẻ gõ sai
+}
+
+// Số gợi ý "did you mean" tối đa và độ dài tối thiểu của q để tìm tên gần đúng
+const (
+	didYouMeanLimit  = 3
+	didYouMeanMinLen = 3
+)
+
+// SuggestBooks trả về tiêu đề, tác giả và keyword bắt đầu bằng (hoặc chứa từ bắt đầu bằng) q.
+// Khi không có tác giả nào khớp, tìm tên tác giả gần giống q theo độ tương đồng trigram.
+func SuggestBooks(db *gorm.DB, q string, limit int) (*SearchSuggestions, error) {
+	folded := utils.FoldText(q)
+	args := map[string]interface{}{
+		"q":        folded,
+		"prefix":   utils.EscapeLike(folded) + "%",
+		"word":     "% " + utils.EscapeLike(folded) + "%",
+		"contains": "%" + utils.EscapeLike(folded) + "%",
+		"limit":    limit,
+	}
+	suggestions := &SearchSuggestions{
+		Titles:     []TitleSuggestion{},
+		Authors:    []string{},
+		Keywords:   []string{},
+		DidYouMean: []string{},
+	}
+
+	// Tiêu đề khớp đầu chuỗi xếp trước, sau đó đến khớp đầu một từ, rồi theo độ tương đồng
+	err := db.Raw(`SELECT id, title, author FROM books
+		WHERE deleted_at IS NULL AND (fold_text(title) LIKE @contains OR @q <% fold_text(title))
+		ORDER BY fold_text(title) LIKE @prefix DESC, fold_text(title) LIKE @word DESC, word_similarity(@q, fold_text(title)) DESC, average_rating DESC, id
+		LIMIT @limit`, args).Scan(&suggestions.Titles).Error
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Raw(`SELECT author FROM books
+		WHERE deleted_at IS NULL AND (fold_text(author) LIKE @prefix OR fold_text(author) LIKE @word)
+		GROUP BY author
+		ORDER BY fold_text(author) LIKE @prefix DESC, COUNT(*) DESC, author
+		LIMIT @limit`, args).Scan(&suggestions.Authors).Error
+	if err != nil {
+		return nil, err
+	}
+
+	// Lọc sách bằng index trigram trên chuỗi keyword trước, rồi mới tách từng keyword
+	err = db.Raw(`SELECT keyword FROM (
+			SELECT DISTINCT unnest(keywords) AS keyword FROM books
+			WHERE deleted_at IS NULL AND fold_text(immutable_array_to_string(keywords)) LIKE @contains
+		) k
+		WHERE fold_text(keyword) LIKE @prefix OR fold_text(keyword) LIKE @word
+		ORDER BY fold_text(keyword) LIKE @prefix DESC, length(keyword), keyword
+		LIMIT @limit`, args).Scan(&suggestions.Keywords).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if len(suggestions.Authors) == 0 && len([]rune(q)) >= didYouMeanMinLen {
+		args["limit"] = didYouMeanLimit
+		// % so cả tên, <% so với một phần tên (ví dụ chỉ gõ họ hoặc tên bị sai chính tả)
+		err = db.Raw(`SELECT author FROM books
+			WHERE deleted_at IS NULL AND (fold_text(author) % @q OR @q <% fold_text(author))
+			GROUP BY author
+			ORDER BY MAX(GREATEST(similarity(fold_text(author), @q), word_similarity(@q, fold_text(author)))) DESC, author
+			LIMIT @limit`, args).Scan(&suggestions.DidYouMean).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+	return suggestions, nil
+}
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+type Cart struct {
+	gorm.Model
+	UserID    uint       `gorm:"not null;uniqueIndex" json:"user_id"`
+	User      User       `gorm:"foreignKey:UserID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+	CartItems []CartItem `gorm:"foreignKey:CartID" json:"cart_items"`
+}
+
+// Subtotal tính tổng tiền giỏ hàng theo giá đã lưu trong từng dòng
+func (c *Cart) Subtotal() float64 {
+	var total float64
+	for _, item := range c.CartItems {
+		total += item.Price * float64(item.Quantity)
+	}
+	return total
+}
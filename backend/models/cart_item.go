@@ -0,0 +1,14 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+type CartItem struct {
+	gorm.Model
+	CartID   uint    `gorm:"not null;index:idx_cart_book,unique,where:deleted_at is null" json:"cart_id"`
+	BookID   uint    `gorm:"not null;index:idx_cart_book,unique,where:deleted_at is null" json:"book_id"`
+	Quantity int     `gorm:"not null;check:quantity > 0" json:"quantity"`
+	Price    float64 `gorm:"type:decimal(10,2);not null" json:"price"` // Giá tại thời điểm thêm vào giỏ
+	Book     Book    `gorm:"foreignKey:BookID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"book"`
+}
@@ -1,14 +1,64 @@
 package models
 
 import (
+	"errors"
+	"fmt"
+	"math"
+
 	"gorm.io/gorm"
 )
 
 type BookCombo struct {
 	gorm.Model
-	Title       string      `gorm:"size:100;not null;index;check:title <> ''"`
-	Description string      `gorm:"type:text;not null"`
-	CreatedBy   uint        `gorm:"not null"`
-	User        User        `gorm:"foreignKey:CreatedBy;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT"`
-	ComboItems  []ComboItem `gorm:"foreignKey:ComboID"`
+	Title           string      `gorm:"size:100;not null;index;check:title <> ''"`
+	Description     string      `gorm:"type:text;not null"`
+	BundlePrice     *float64    `gorm:"type:decimal(10,2);check:bundle_price IS NULL OR bundle_price > 0"`                   // Giá trọn gói, ưu tiên hơn DiscountPercent
+	DiscountPercent float64     `gorm:"type:decimal(5,2);default:0;check:discount_percent >= 0 AND discount_percent <= 100"` // % giảm trên tổng giá lẻ
+	CreatedBy       uint        `gorm:"not null"`
+	User            User        `gorm:"foreignKey:CreatedBy;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT"`
+	ComboItems      []ComboItem `gorm:"foreignKey:ComboID"`
+}
+
+// ComboUnavailableError trả về khi combo không thể đặt mua
+type ComboUnavailableError struct {
+	ComboID uint
+	Reason  string
+}
+
+func (e *ComboUnavailableError) Error() string {
+	return fmt.Sprintf("Combo %d is not available: %s", e.ComboID, e.Reason)
+}
+
+// LoadPurchasableCombo nạp combo kèm toàn bộ sách (kể cả dòng bị ẩn) và kiểm tra
+// combo còn bán được: mọi sách phải còn hiển thị và chưa bị xóa.
+func LoadPurchasableCombo(db *gorm.DB, comboID uint) (*BookCombo, error) {
+	var combo BookCombo
+	if err := db.Preload("ComboItems.Book").First(&combo, comboID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, &ComboUnavailableError{ComboID: comboID, Reason: "combo not found"}
+		}
+		return nil, err
+	}
+
+	if len(combo.ComboItems) == 0 {
+		return nil, &ComboUnavailableError{ComboID: comboID, Reason: "combo has no books"}
+	}
+	for _, item := range combo.ComboItems {
+		if item.IsHidden {
+			return nil, &ComboUnavailableError{ComboID: comboID, Reason: fmt.Sprintf("book %d is hidden", item.BookID)}
+		}
+		// Preload bỏ qua sách đã xóa mềm nên Book rỗng
+		if item.Book.ID == 0 {
+			return nil, &ComboUnavailableError{ComboID: comboID, Reason: fmt.Sprintf("book %d is no longer sold", item.BookID)}
+		}
+	}
+	return &combo, nil
+}
+
+// BundleTotal tính giá trọn gói từ tổng giá lẻ; không bao giờ vượt quá giá lẻ
+func (c *BookCombo) BundleTotal(listPrice float64) float64 {
+	if c.BundlePrice != nil {
+		return math.Min(*c.BundlePrice, listPrice)
+	}
+	return listPrice * (1 - math.Max(0, math.Min(100, c.DiscountPercent))/100)
 }
@@ -0,0 +1,178 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type CouponType string
+
+const (
+	CouponTypePercent CouponType = "percent"
+	CouponTypeFixed   CouponType = "fixed"
+)
+
+type Coupon struct {
+	gorm.Model
+	Code           string         `gorm:"size:50;uniqueIndex;not null" json:"code"`
+	Description    string         `gorm:"type:text" json:"description,omitempty"`
+	Type           CouponType     `gorm:"type:varchar(10);not null" json:"type"`
+	Value          float64        `gorm:"type:decimal(10,2);not null;check:value > 0" json:"value"`
+	MinOrderAmount float64        `gorm:"type:decimal(10,2);default:0" json:"min_order_amount"`
+	StartsAt       *time.Time     `json:"starts_at,omitempty"`
+	ExpiresAt      *time.Time     `json:"expires_at,omitempty"`
+	UsageLimit     int            `gorm:"default:0;check:usage_limit >= 0" json:"usage_limit"`       // 0 = không giới hạn
+	PerUserLimit   int            `gorm:"default:0;check:per_user_limit >= 0" json:"per_user_limit"` // 0 = không giới hạn
+	UsedCount      int            `gorm:"default:0;not null" json:"used_count"`
+	IsActive       bool           `gorm:"default:true;index" json:"is_active"`
+	Categories     pq.StringArray `gorm:"type:text[]" json:"categories"` // Rỗng = áp dụng mọi thể loại
+	BookIDs        pq.Int64Array  `gorm:"type:bigint[]" json:"book_ids"`
+	ComboIDs       pq.Int64Array  `gorm:"type:bigint[]" json:"combo_ids"`
+}
+
+// CouponRedemption ghi nhận một lần sử dụng coupon cho một đơn hàng
+type CouponRedemption struct {
+	gorm.Model
+	CouponID uint    `gorm:"not null;index" json:"coupon_id"`
+	UserID   uint    `gorm:"not null;index" json:"user_id"`
+	OrderID  uint    `gorm:"not null;uniqueIndex" json:"order_id"`
+	Discount float64 `gorm:"type:decimal(10,2);not null" json:"discount"`
+}
+
+// CouponError mô tả lý do coupon không dùng được
+type CouponError struct {
+	Code    string
+	Message string
+}
+
+func (e *CouponError) Error() string {
+	return e.Message
+}
+
+var (
+	ErrCouponNotFound      = &CouponError{Code: "coupon_not_found", Message: "Mã giảm giá không tồn tại"}
+	ErrCouponInactive      = &CouponError{Code: "coupon_inactive", Message: "Mã giảm giá đã bị vô hiệu hóa"}
+	ErrCouponNotStarted    = &CouponError{Code: "coupon_not_started", Message: "Mã giảm giá chưa đến thời gian sử dụng"}
+	ErrCouponExpired       = &CouponError{Code: "coupon_expired", Message: "Mã giảm giá đã hết hạn"}
+	ErrCouponUsageLimit    = &CouponError{Code: "coupon_usage_limit", Message: "Mã giảm giá đã hết lượt sử dụng"}
+	ErrCouponUserLimit     = &CouponError{Code: "coupon_user_limit", Message: "Bạn đã dùng hết lượt cho mã giảm giá này"}
+	ErrCouponMinOrder      = &CouponError{Code: "coupon_min_order", Message: "Đơn hàng chưa đạt giá trị tối thiểu để dùng mã"}
+	ErrCouponNotApplicable = &CouponError{Code: "coupon_not_applicable", Message: "Mã giảm giá không áp dụng cho sản phẩm trong đơn"}
+)
+
+// NormalizeCouponCode chuẩn hóa mã (bỏ khoảng trắng, viết hoa)
+func NormalizeCouponCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+func (c *Coupon) BeforeSave(tx *gorm.DB) error {
+	c.Code = NormalizeCouponCode(c.Code)
+	return nil
+}
+
+// HasRestrictions cho biết coupon có giới hạn theo thể loại/sách/combo không
+func (c *Coupon) HasRestrictions() bool {
+	return len(c.Categories) > 0 || len(c.BookIDs) > 0 || len(c.ComboIDs) > 0
+}
+
+// CheckAvailability kiểm tra trạng thái, thời gian hiệu lực và lượt sử dụng
+func (c *Coupon) CheckAvailability(db *gorm.DB, userID uint, now time.Time) error {
+	if !c.IsActive {
+		return ErrCouponInactive
+	}
+	if c.StartsAt != nil && now.Before(*c.StartsAt) {
+		return ErrCouponNotStarted
+	}
+	if c.ExpiresAt != nil && now.After(*c.ExpiresAt) {
+		return ErrCouponExpired
+	}
+	if c.UsageLimit > 0 && c.UsedCount >= c.UsageLimit {
+		return ErrCouponUsageLimit
+	}
+
+	if c.PerUserLimit > 0 {
+		var used int64
+		if err := db.Model(&CouponRedemption{}).
+			Where("coupon_id = ? AND user_id = ?", c.ID, userID).
+			Count(&used).Error; err != nil {
+			return err
+		}
+		if used >= int64(c.PerUserLimit) {
+			return ErrCouponUserLimit
+		}
+	}
+	return nil
+}
+
+// FindCoupon tìm coupon theo mã và kiểm tra còn dùng được không (không khóa)
+func FindCoupon(db *gorm.DB, code string, userID uint) (*Coupon, error) {
+	var coupon Coupon
+	if err := db.Where("code = ?", NormalizeCouponCode(code)).First(&coupon).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCouponNotFound
+		}
+		return nil, err
+	}
+	if err := coupon.CheckAvailability(db, userID, time.Now()); err != nil {
+		return nil, err
+	}
+	return &coupon, nil
+}
+
+// LockCoupon khóa dòng coupon (SELECT ... FOR UPDATE) rồi kiểm tra lượt dùng.
+// Các đơn dùng cùng mã sẽ lần lượt chờ nhau nên không thể vượt giới hạn.
+func LockCoupon(tx *gorm.DB, code string, userID uint) (*Coupon, error) {
+	var coupon Coupon
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("code = ?", NormalizeCouponCode(code)).
+		First(&coupon).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCouponNotFound
+		}
+		return nil, err
+	}
+	if err := coupon.CheckAvailability(tx, userID, time.Now()); err != nil {
+		return nil, err
+	}
+	return &coupon, nil
+}
+
+// RedeemCoupon ghi nhận lượt dùng; phải gọi trong cùng transaction với LockCoupon
+func RedeemCoupon(tx *gorm.DB, coupon *Coupon, userID, orderID uint, discount float64) error {
+	if err := tx.Create(&CouponRedemption{
+		CouponID: coupon.ID,
+		UserID:   userID,
+		OrderID:  orderID,
+		Discount: discount,
+	}).Error; err != nil {
+		return err
+	}
+
+	return tx.Model(&Coupon{}).
+		Where("id = ?", coupon.ID).
+		UpdateColumn("used_count", gorm.Expr("used_count + 1")).Error
+}
+
+// ReleaseCouponRedemption hoàn lại lượt dùng coupon của đơn hàng (khi hủy/sửa đơn)
+func ReleaseCouponRedemption(tx *gorm.DB, orderID uint) error {
+	var redemption CouponRedemption
+	err := tx.Where("order_id = ?", orderID).First(&redemption).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Unscoped().Delete(&redemption).Error; err != nil {
+		return err
+	}
+	return tx.Model(&Coupon{}).
+		Where("id = ? AND used_count > 0", redemption.CouponID).
+		UpdateColumn("used_count", gorm.Expr("used_count - 1")).Error
+}
@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+)
+
+// DownloadLog ghi nhận mỗi lượt tải ebook của user
+type DownloadLog struct {
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	UserID       uint       `gorm:"not null;index" json:"user_id"`
+	BookID       uint       `gorm:"not null;index" json:"book_id"`
+	Format       BookFormat `gorm:"type:varchar(10)" json:"format"`
+	IPAddress    string     `gorm:"size:45" json:"ip_address"`
+	UserAgent    string     `gorm:"size:255" json:"user_agent"`
+	TokenExpires time.Time  `json:"token_expires"`
+	CreatedAt    time.Time  `gorm:"index" json:"created_at"`
+}
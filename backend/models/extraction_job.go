@@ -0,0 +1,141 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ExtractionStatus là trạng thái trích xuất keyword/mục lục (dùng cho cả job và sách)
+type ExtractionStatus string
+
+const (
+	ExtractionPending    ExtractionStatus = "pending"    // Đang chờ (hoặc chờ thử lại)
+	ExtractionProcessing ExtractionStatus = "processing" // Worker đang xử lý
+	ExtractionCompleted  ExtractionStatus = "completed"
+	ExtractionFailed     ExtractionStatus = "failed" // Hết số lần thử
+)
+
+var ErrExtractionInProgress = errors.New("extraction already queued or running")
+
+// ExtractionJob là một lượt trích xuất keyword/mục lục từ PDF của sách, xử lý bởi worker nền
+type ExtractionJob struct {
+	gorm.Model
+	BookID      uint             `gorm:"not null;index" json:"book_id"`
+	Status      ExtractionStatus `gorm:"size:20;not null;index" json:"status"`
+	TOCPages    string           `gorm:"size:100" json:"toc_pages,omitempty"` // Trang chứa mục lục, chuyển cho dịch vụ trích xuất
+	Attempts    int              `gorm:"not null;default:0" json:"attempts"`
+	MaxAttempts int              `gorm:"not null" json:"max_attempts"`
+	RunAt       time.Time        `gorm:"not null;index" json:"run_at"` // Thời điểm sớm nhất được chạy (backoff)
+	LockedAt    *time.Time       `json:"locked_at,omitempty"`
+	LastError   string           `gorm:"type:text" json:"last_error,omitempty"`
+	FinishedAt  *time.Time       `json:"finished_at,omitempty"`
+}
+
+// EnqueueExtraction tạo job trích xuất cho sách và đặt trạng thái sách về pending.
+// Trả về ErrExtractionInProgress nếu sách đang có job chưa xong.
+func EnqueueExtraction(tx *gorm.DB, bookID uint, tocPages string, maxAttempts int) (*ExtractionJob, error) {
+	var active int64
+	if err := tx.Model(&ExtractionJob{}).
+		Where("book_id = ? AND status IN ?", bookID, []ExtractionStatus{ExtractionPending, ExtractionProcessing}).
+		Count(&active).Error; err != nil {
+		return nil, err
+	}
+	if active > 0 {
+		return nil, ErrExtractionInProgress
+	}
+
+	job := ExtractionJob{
+		BookID:      bookID,
+		Status:      ExtractionPending,
+		TOCPages:    tocPages,
+		MaxAttempts: maxAttempts,
+		RunAt:       time.Now(),
+	}
+	if err := tx.Create(&job).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Model(&Book{}).Where("id = ?", bookID).Update("extraction_status", ExtractionPending).Error; err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// ClaimExtractionJob lấy job đến hạn tiếp theo và đánh dấu processing. Dùng SKIP LOCKED nên
+// nhiều worker (nhiều replica) không lấy trùng job. Job processing quá staleAfter (worker chết
+// giữa chừng) được lấy lại. Trả về nil nếu không có job.
+func ClaimExtractionJob(db *gorm.DB, staleAfter time.Duration) (*ExtractionJob, error) {
+	var job ExtractionJob
+	err := db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("(status = ? AND run_at <= ?) OR (status = ? AND locked_at < ?)",
+				ExtractionPending, now, ExtractionProcessing, now.Add(-staleAfter)).
+			Order("run_at").
+			First(&job).Error
+		if err != nil {
+			return err
+		}
+
+		job.Status = ExtractionProcessing
+		job.Attempts++
+		job.LockedAt = &now
+		if err := tx.Model(&job).Updates(map[string]interface{}{
+			"status":    job.Status,
+			"attempts":  job.Attempts,
+			"locked_at": now,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&Book{}).Where("id = ?", job.BookID).Update("extraction_status", ExtractionProcessing).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// CompleteExtractionJob lưu kết quả vào sách và đóng job
+func CompleteExtractionJob(db *gorm.DB, job *ExtractionJob, keywords, tocTitles []string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Model(job).Updates(map[string]interface{}{
+			"status":      ExtractionCompleted,
+			"finished_at": now,
+			"last_error":  "",
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&Book{}).Where("id = ?", job.BookID).Updates(map[string]interface{}{
+			"keywords":          pq.StringArray(keywords),
+			"toc_titles":        pq.StringArray(tocTitles),
+			"extraction_status": ExtractionCompleted,
+		}).Error
+	})
+}
+
+// FailExtractionJob ghi lỗi; còn lượt thử thì hẹn chạy lại sau retryAfter, hết lượt thì đánh dấu failed
+func FailExtractionJob(db *gorm.DB, job *ExtractionJob, cause error, retryAfter time.Duration) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		updates := map[string]interface{}{"last_error": cause.Error()}
+		status := ExtractionPending
+		if job.Attempts >= job.MaxAttempts {
+			status = ExtractionFailed
+			updates["finished_at"] = time.Now()
+		} else {
+			updates["run_at"] = time.Now().Add(retryAfter)
+		}
+		updates["status"] = status
+
+		if err := tx.Model(job).Updates(updates).Error; err != nil {
+			return err
+		}
+		return tx.Model(&Book{}).Where("id = ?", job.BookID).Update("extraction_status", status).Error
+	})
+}
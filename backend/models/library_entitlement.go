@@ -0,0 +1,103 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// LibraryEntitlement là quyền sở hữu (tải/đọc) một ebook của user
+type LibraryEntitlement struct {
+	gorm.Model
+	UserID    uint      `gorm:"not null;index:idx_entitlement_user_book,unique,where:deleted_at is null" json:"user_id"`
+	BookID    uint      `gorm:"not null;index:idx_entitlement_user_book,unique,where:deleted_at is null" json:"book_id"`
+	OrderID   *uint     `gorm:"index" json:"order_id,omitempty"` // Đơn hàng đã cấp quyền
+	GrantedAt time.Time `gorm:"not null" json:"granted_at"`
+	Book      Book      `gorm:"foreignKey:BookID;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT" json:"book"`
+}
+
+// GrantOrderEntitlements cấp quyền sở hữu các sách trong đơn hàng cho người mua.
+// Sách đã sở hữu từ trước được bỏ qua.
+func GrantOrderEntitlements(tx *gorm.DB, order *Order) error {
+	var bookIDs []uint
+	if err := tx.Model(&OrderItem{}).
+		Where("order_id = ?", order.ID).
+		Distinct().
+		Pluck("book_id", &bookIDs).Error; err != nil {
+		return err
+	}
+	if len(bookIDs) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	entitlements := make([]LibraryEntitlement, 0, len(bookIDs))
+	for _, bookID := range bookIDs {
+		entitlements = append(entitlements, LibraryEntitlement{
+			UserID:    order.UserID,
+			BookID:    bookID,
+			OrderID:   &order.ID,
+			GrantedAt: now,
+		})
+	}
+
+	return tx.Clauses(clause.OnConflict{
+		Columns:     []clause.Column{{Name: "user_id"}, {Name: "book_id"}},
+		TargetWhere: clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: "deleted_at IS NULL"}}},
+		DoNothing:   true,
+	}).Create(&entitlements).Error
+}
+
+// Đơn khác của cùng user vẫn cấp quyền cho sách: đơn hoàn tất, hoặc đơn trả trước đã thanh toán
+// (giống điều kiện cấp quyền trong TransitionOrder); đơn cũ nhất được chọn
+const entitlementOtherOrdersSQL = `SELECT DISTINCT ON (orders.user_id, order_items.book_id)
+	orders.user_id, order_items.book_id, orders.id AS order_id
+FROM orders JOIN order_items ON order_items.order_id = orders.id
+WHERE orders.user_id = (SELECT user_id FROM orders WHERE id = @order) AND orders.id <> @order
+	AND orders.deleted_at IS NULL AND order_items.deleted_at IS NULL
+	AND (orders.status = @completed OR (orders.status = @processing AND orders.payment_method <> 'COD'))
+ORDER BY orders.user_id, order_items.book_id, orders.id`
+
+// RevokeOrderEntitlements thu hồi các quyền được cấp từ đơn hàng (khi hủy/hoàn tiền).
+// Sách user vẫn sở hữu qua một đơn khác được chuyển sang đơn đó thay vì bị xóa.
+func RevokeOrderEntitlements(tx *gorm.DB, orderID uint) error {
+	err := tx.Exec(`UPDATE library_entitlements SET order_id = other.order_id, updated_at = NOW()
+		FROM (`+entitlementOtherOrdersSQL+`) other
+		WHERE library_entitlements.order_id = @order AND library_entitlements.deleted_at IS NULL
+			AND other.user_id = library_entitlements.user_id AND other.book_id = library_entitlements.book_id`,
+		map[string]interface{}{
+			"order":      orderID,
+			"completed":  OrderStatusCompleted,
+			"processing": OrderStatusProcessing,
+		}).Error
+	if err != nil {
+		return err
+	}
+	return tx.Where("order_id = ?", orderID).Delete(&LibraryEntitlement{}).Error
+}
+
+// FindEntitlement trả về quyền sở hữu sách của user (nil nếu chưa sở hữu)
+func FindEntitlement(db *gorm.DB, userID, bookID uint) (*LibraryEntitlement, error) {
+	var entitlement LibraryEntitlement
+	err := db.Where("user_id = ? AND book_id = ?", userID, bookID).First(&entitlement).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &entitlement, nil
+}
+
+// HasEntitlement kiểm tra user có sở hữu sách không
+func HasEntitlement(db *gorm.DB, userID, bookID uint) (bool, error) {
+	var count int64
+	if err := db.Model(&LibraryEntitlement{}).
+		Where("user_id = ? AND book_id = ?", userID, bookID).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
@@ -6,10 +6,21 @@ import (
 
 type Order struct {
 	gorm.Model
-	UserID        uint        `gorm:"not null" json:"user_id"`
-	User          User        `gorm:"foreignKey:UserID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT" json:"user"`
-	TotalAmount   float64     `gorm:"type:decimal(10,2);not null;check:total_amount >= 0" json:"total_amount"`
-	Status        string      `gorm:"type:varchar(20);default:'pending'" json:"status"`
-	OrderItems    []OrderItem `gorm:"foreignKey:OrderID" json:"order_items"` // Liên kết với OrderItem
-	PaymentMethod string      `gorm:"type:varchar(20);default:'Card'" json:"payment_method"`
+	UserID         uint        `gorm:"not null" json:"user_id"`
+	User           User        `gorm:"foreignKey:UserID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT" json:"user"`
+	Subtotal       float64     `gorm:"type:decimal(10,2);default:0.00" json:"subtotal"`
+	DiscountAmount float64     `gorm:"type:decimal(10,2);default:0.00" json:"discount_amount"`
+	ShippingFee    float64     `gorm:"type:decimal(10,2);default:0.00" json:"shipping_fee"`
+	CouponID       *uint       `gorm:"index" json:"coupon_id,omitempty"`
+	CouponCode     string      `gorm:"size:50" json:"coupon_code,omitempty"`
+	TotalAmount    float64     `gorm:"type:decimal(10,2);not null;check:total_amount >= 0" json:"total_amount"`
+	Status         OrderStatus `gorm:"type:varchar(20);default:'pending'" json:"status"`
+	OrderItems     []OrderItem `gorm:"foreignKey:OrderID" json:"order_items"` // Liên kết với OrderItem
+	PaymentMethod  string      `gorm:"type:varchar(20);default:'Card'" json:"payment_method"`
+	Payments       []Payment   `gorm:"foreignKey:OrderID" json:"payments,omitempty"`
+}
+
+// RequiresPrepayment cho biết đơn có phải thanh toán trước khi xử lý không (COD thì không)
+func (o *Order) RequiresPrepayment() bool {
+	return o.PaymentMethod != "COD"
 }
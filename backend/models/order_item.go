@@ -10,7 +10,8 @@ type OrderItem struct {
 	BookID   uint    `gorm:"not null;index"`                                                                // Liên kết với Book
 	Quantity int     `gorm:"not null;check:quantity > 0"`                                                   // Số lượng sách trong đơn hàng
 	Price    float64 `gorm:"type:decimal(10,2);not null"`                                                   // Giá của sách
-	Discount float64 `gorm:"type:decimal(10,2);default:0.00"`                                               // Giảm giá cho sản phẩm, nếu có
+	Discount float64 `gorm:"type:decimal(10,2);default:0.00"`                                               // Tổng giảm giá của cả dòng, nếu có
+	ComboID  *uint   `gorm:"index"`                                                                         // Combo mà dòng này thuộc về, nếu mua theo combo
 	Book     Book    `gorm:"foreignKey:BookID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT"` // Liên kết với Book
 }
 
@@ -23,6 +24,7 @@ type OrderItemResponse struct {
 	Quantity int     `json:"quantity"`
 	Discount float64 `json:"discount"`
 	Price    float64 `json:"price"` // Giá
+	ComboID  *uint   `json:"combo_id,omitempty"`
 }
 
 func (oi *OrderItem) ToResponse() OrderItemResponse {
@@ -33,6 +35,7 @@ func (oi *OrderItem) ToResponse() OrderItemResponse {
 		Quantity: oi.Quantity,
 		Discount: oi.Discount,
 		Price:    oi.Price,
+		ComboID:  oi.ComboID,
 	}
 
 	// Kiểm tra quan hệ Book đã được preload chưa thông qua BookID
@@ -0,0 +1,146 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type OrderStatus string
+
+const (
+	OrderStatusPending    OrderStatus = "pending"
+	OrderStatusProcessing OrderStatus = "processing"
+	OrderStatusCompleted  OrderStatus = "completed"
+	OrderStatusCanceled   OrderStatus = "canceled"
+)
+
+// orderStatusTransitions liệt kê các bước chuyển trạng thái hợp lệ
+var orderStatusTransitions = map[OrderStatus][]OrderStatus{
+	OrderStatusPending:    {OrderStatusProcessing, OrderStatusCanceled},
+	OrderStatusProcessing: {OrderStatusCompleted, OrderStatusCanceled},
+	OrderStatusCompleted:  {},
+	OrderStatusCanceled:   {},
+}
+
+var (
+	ErrInvalidOrderStatus     = errors.New("invalid order status")
+	ErrInvalidOrderTransition = errors.New("invalid order status transition")
+	ErrPaymentNotConfirmed    = errors.New("order has no confirmed payment")
+)
+
+func (s OrderStatus) IsValid() bool {
+	_, ok := orderStatusTransitions[s]
+	return ok
+}
+
+// CanTransitionTo kiểm tra có được chuyển từ trạng thái hiện tại sang trạng thái mới không
+func (s OrderStatus) CanTransitionTo(to OrderStatus) bool {
+	for _, allowed := range orderStatusTransitions[s] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowedTransitions trả về các trạng thái có thể chuyển tới từ trạng thái hiện tại
+func (s OrderStatus) AllowedTransitions() []OrderStatus {
+	return orderStatusTransitions[s]
+}
+
+// OrderStatusHistory lưu lại mỗi lần đơn hàng đổi trạng thái
+type OrderStatusHistory struct {
+	gorm.Model
+	OrderID       uint        `gorm:"not null;index" json:"order_id"`
+	FromStatus    OrderStatus `gorm:"type:varchar(20)" json:"from_status"`
+	ToStatus      OrderStatus `gorm:"type:varchar(20);not null" json:"to_status"`
+	ChangedBy     *uint       `json:"changed_by"` // nil nghĩa là hệ thống tự chuyển
+	ChangedByUser *User       `gorm:"foreignKey:ChangedBy;references:ID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL" json:"changed_by_user,omitempty"`
+	Reason        string      `gorm:"type:text" json:"reason,omitempty"`
+}
+
+// OrderTransition mô tả một yêu cầu chuyển trạng thái đơn hàng
+type OrderTransition struct {
+	To        OrderStatus
+	ChangedBy *uint
+	Reason    string
+	// ExpectedFrom (nếu có) chỉ cho phép chuyển khi đơn đang ở đúng trạng thái này
+	ExpectedFrom OrderStatus
+}
+
+// RecordOrderStatus ghi một dòng lịch sử trạng thái
+func RecordOrderStatus(tx *gorm.DB, orderID uint, from, to OrderStatus, changedBy *uint, reason string) error {
+	return tx.Create(&OrderStatusHistory{
+		OrderID:    orderID,
+		FromStatus: from,
+		ToStatus:   to,
+		ChangedBy:  changedBy,
+		Reason:     reason,
+	}).Error
+}
+
+// TransitionOrder là điểm duy nhất để đổi trạng thái đơn hàng: khóa đơn,
+// kiểm tra bảng chuyển trạng thái, hoàn trả tồn kho khi hủy và ghi lịch sử.
+// Phải được gọi bên trong transaction.
+func TransitionOrder(tx *gorm.DB, orderID uint, t OrderTransition) (*Order, error) {
+	if !t.To.IsValid() {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidOrderStatus, t.To)
+	}
+
+	var order Order
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, orderID).Error; err != nil {
+		return nil, err
+	}
+
+	from := order.Status
+	if t.ExpectedFrom != "" && from != t.ExpectedFrom {
+		return nil, fmt.Errorf("%w: order is %s, expected %s", ErrInvalidOrderTransition, from, t.ExpectedFrom)
+	}
+	if !from.CanTransitionTo(t.To) {
+		return nil, fmt.Errorf("%w: %s -> %s", ErrInvalidOrderTransition, from, t.To)
+	}
+
+	// Đơn trả trước chỉ được xử lý khi đã có thanh toán thành công
+	if from == OrderStatusPending && t.To == OrderStatusProcessing && order.RequiresPrepayment() {
+		var paid int64
+		if err := tx.Model(&Payment{}).
+			Where("order_id = ? AND status = ?", order.ID, PaymentStatusSucceeded).
+			Count(&paid).Error; err != nil {
+			return nil, err
+		}
+		if paid == 0 {
+			return nil, ErrPaymentNotConfirmed
+		}
+	}
+
+	if t.To == OrderStatusCanceled {
+		if err := ReleaseOrderStock(tx, order.ID); err != nil {
+			return nil, err
+		}
+		if err := ReleaseCouponRedemption(tx, order.ID); err != nil {
+			return nil, err
+		}
+		if err := RevokeOrderEntitlements(tx, order.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	// Cấp quyền sở hữu ebook khi đơn hoàn tất, hoặc ngay khi đơn trả trước đã thanh toán
+	if t.To == OrderStatusCompleted || (t.To == OrderStatusProcessing && order.RequiresPrepayment()) {
+		if err := GrantOrderEntitlements(tx, &order); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Model(&order).Update("status", t.To).Error; err != nil {
+		return nil, err
+	}
+	if err := RecordOrderStatus(tx, order.ID, from, t.To, t.ChangedBy, t.Reason); err != nil {
+		return nil, err
+	}
+
+	return &order, nil
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type PaymentStatus string
+
+const (
+	PaymentStatusPending   PaymentStatus = "pending"
+	PaymentStatusSucceeded PaymentStatus = "succeeded"
+	PaymentStatusFailed    PaymentStatus = "failed"
+	PaymentStatusRefunded  PaymentStatus = "refunded"
+)
+
+type Payment struct {
+	gorm.Model
+	OrderID     uint          `gorm:"not null;index" json:"order_id"`
+	Order       Order         `gorm:"foreignKey:OrderID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT" json:"-"`
+	Provider    string        `gorm:"size:30;not null" json:"provider"`
+	ProviderRef string        `gorm:"size:100;not null;uniqueIndex" json:"provider_ref"` // ID intent bên cổng thanh toán
+	Amount      float64       `gorm:"type:decimal(10,2);not null;check:amount >= 0" json:"amount"`
+	Currency    string        `gorm:"size:3;not null" json:"currency"`
+	Status      PaymentStatus `gorm:"type:varchar(20);default:'pending';index" json:"status"`
+	ConfirmedAt *time.Time    `json:"confirmed_at,omitempty"`
+	RefundedAt  *time.Time    `json:"refunded_at,omitempty"`
+}
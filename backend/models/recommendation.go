@@ -0,0 +1,130 @@
+package models
+
+import "gorm.io/gorm"
+
+type RecommendationReason string
+
+const (
+	ReasonBoughtTogether RecommendationReason = "bought_together" // Khách mua sách của bạn cũng mua sách này
+	ReasonSimilar        RecommendationReason = "similar"         // Cùng thể loại/tác giả/keyword
+	ReasonPopular        RecommendationReason = "popular"         // Chưa đủ lịch sử: sách bán chạy, đánh giá cao
+)
+
+type BookRecommendation struct {
+	Book   *BookResponse        `json:"book"`
+	Score  float64              `json:"score"`
+	Reason RecommendationReason `json:"reason"`
+}
+
+type recommendationPick struct {
+	BookID uint
+	Score  float64
+	Reason RecommendationReason
+}
+
+// Trọng số của điểm nội dung so với điểm mua cùng khi gộp hai nguồn
+const contentSimilarityWeight = 0.25
+
+// Sách làm "hạt giống" gợi ý cho user @user, kèm trọng số: sách đã sở hữu (đơn hoàn thành
+// hoặc thư viện), sách đã đánh giá từ 4 sao, sách trong giỏ. Sách đã sở hữu không được gợi ý lại.
+const recommendationSeedsCTE = `owned AS (
+	SELECT book_id FROM library_entitlements WHERE user_id = @user AND deleted_at IS NULL
+	UNION
+	SELECT order_items.book_id FROM order_items JOIN orders ON orders.id = order_items.order_id
+	WHERE orders.user_id = @user AND orders.status = 'completed' AND orders.deleted_at IS NULL AND order_items.deleted_at IS NULL
+),
+seeds AS (
+	SELECT book_id, MAX(weight) AS weight FROM (
+		SELECT book_id, 1.0 AS weight FROM owned
+		UNION ALL
+		SELECT book_id, rating / 5.0 FROM reviews WHERE user_id = @user AND deleted_at IS NULL AND rating >= 4
+		UNION ALL
+		SELECT cart_items.book_id, 0.5 FROM cart_items JOIN carts ON carts.id = cart_items.cart_id
+		WHERE carts.user_id = @user AND carts.deleted_at IS NULL AND cart_items.deleted_at IS NULL
+	) s
+	GROUP BY book_id
+)`
+
+// RecommendBooks gợi ý sách cho user từ bảng book_similarities (mua cùng, rồi tương đồng nội dung),
+// thiếu thì bổ sung sách phổ biến. Không gợi ý sách user đã sở hữu hoặc đang có trong giỏ/đã đánh giá.
+func RecommendBooks(db *gorm.DB, userID uint, limit int) ([]BookRecommendation, error) {
+	args := map[string]interface{}{
+		"user":           userID,
+		"limit":          limit,
+		"content_weight": contentSimilarityWeight,
+		"co_purchase":    SimilarityCoPurchase,
+		"content":        SimilarityContent,
+	}
+
+	var picks []recommendationPick
+	err := db.Raw(`WITH `+recommendationSeedsCTE+`,
+		scores AS (
+			SELECT s.similar_book_id AS book_id,
+				COALESCE(SUM(s.score * seeds.weight) FILTER (WHERE s.source = @co_purchase), 0) AS co_purchase,
+				COALESCE(SUM(s.score * seeds.weight) FILTER (WHERE s.source = @content), 0) AS content
+			FROM book_similarities s JOIN seeds ON seeds.book_id = s.book_id
+			WHERE s.similar_book_id NOT IN (SELECT book_id FROM seeds)
+			GROUP BY s.similar_book_id
+		)
+		SELECT scores.book_id, co_purchase + content * @content_weight AS score,
+			CASE WHEN co_purchase > 0 THEN 'bought_together' ELSE 'similar' END AS reason
+		FROM scores JOIN books ON books.id = scores.book_id AND books.deleted_at IS NULL
+		ORDER BY score DESC, books.average_rating DESC, scores.book_id
+		LIMIT @limit`, args).Scan(&picks).Error
+	if err != nil {
+		return nil, err
+	}
+
+	// User mới hoặc lịch sử chưa đủ: bổ sung sách bán chạy 90 ngày qua, rồi theo đánh giá
+	if len(picks) < limit {
+		picked := []uint{0}
+		for _, pick := range picks {
+			picked = append(picked, pick.BookID)
+		}
+		args["picked"] = picked
+		args["limit"] = limit - len(picks)
+		var popular []uint
+		err := db.Raw(`WITH `+recommendationSeedsCTE+`,
+			sales AS (
+				SELECT order_items.book_id, SUM(order_items.quantity) AS sold
+				FROM order_items JOIN orders ON orders.id = order_items.order_id
+				WHERE orders.status = 'completed' AND orders.deleted_at IS NULL
+					AND orders.created_at >= NOW() - INTERVAL '90 days'
+				GROUP BY order_items.book_id
+			)
+			SELECT books.id FROM books LEFT JOIN sales ON sales.book_id = books.id
+			WHERE books.deleted_at IS NULL
+				AND books.id NOT IN (SELECT book_id FROM seeds) AND books.id NOT IN @picked
+			ORDER BY COALESCE(sales.sold, 0) DESC, books.average_rating DESC, books.id
+			LIMIT @limit`, args).Scan(&popular).Error
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range popular {
+			picks = append(picks, recommendationPick{BookID: id, Reason: ReasonPopular})
+		}
+	}
+
+	recommendations := []BookRecommendation{}
+	if len(picks) == 0 {
+		return recommendations, nil
+	}
+	ids := make([]uint, len(picks))
+	for i, pick := range picks {
+		ids[i] = pick.BookID
+	}
+	var books []Book
+	if err := db.Where("id IN ?", ids).Find(&books).Error; err != nil {
+		return nil, err
+	}
+	byID := make(map[uint]*Book, len(books))
+	for i := range books {
+		byID[books[i].ID] = &books[i]
+	}
+	for _, pick := range picks {
+		if book, ok := byID[pick.BookID]; ok {
+			recommendations = append(recommendations, BookRecommendation{Book: book.ToResponse(), Score: pick.Score, Reason: pick.Reason})
+		}
+	}
+	return recommendations, nil
+}
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/Poloni84Learning/ebook-store/utils"
+)
+
+// SearchLog ghi nhận một lượt tìm kiếm sách. ID do server sinh và trả cho client qua header
+// X-Search-ID; client gửi lại khi mở sách từ kết quả để tính click-through.
+type SearchLog struct {
+	ID              string    `gorm:"primaryKey;size:32" json:"id"`
+	CreatedAt       time.Time `gorm:"index" json:"created_at"`
+	Endpoint        string    `gorm:"size:30;index" json:"endpoint"`  // search, by-title, by-author, by-category, keywords
+	Query           string    `gorm:"size:200" json:"query"`          // Từ khóa như người dùng gõ
+	NormalizedQuery string    `gorm:"size:200;index" json:"-"`        // Bỏ dấu, chữ thường, gộp khoảng trắng: dùng để gom nhóm
+	ResultCount     int       `gorm:"not null" json:"result_count"`   // Số kết quả trả về
+	LatencyMs       int       `gorm:"not null" json:"latency_ms"`     // Thời gian xử lý trên server
+	UserHash        string    `gorm:"size:32;index" json:"user_hash"` // Định danh ẩn danh, không suy ngược được ra user
+}
+
+// SearchClick là một lần người dùng mở sách từ kết quả của một lượt tìm kiếm
+type SearchClick struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+	SearchID  string    `gorm:"size:32;not null;index" json:"search_id"`
+	BookID    uint      `gorm:"not null;index" json:"book_id"`
+}
+
+const searchLogMaxQueryLen = 200
+
+// NewSearchLog tạo bản ghi tìm kiếm, cắt query quá dài và chuẩn hóa để gom nhóm
+func NewSearchLog(id, endpoint, query string, resultCount int, latency time.Duration, userHash string) *SearchLog {
+	query = truncateRunes(strings.TrimSpace(query), searchLogMaxQueryLen)
+	return &SearchLog{
+		ID:              id,
+		Endpoint:        endpoint,
+		Query:           query,
+		NormalizedQuery: truncateRunes(strings.Join(strings.Fields(utils.FoldText(query)), " "), searchLogMaxQueryLen),
+		ResultCount:     resultCount,
+		LatencyMs:       int(latency.Milliseconds()),
+		UserHash:        userHash,
+	}
+}
+
+func truncateRunes(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	return string(runes[:max])
+}
@@ -0,0 +1,99 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// InsufficientStockError trả về khi tồn kho không đủ để giữ hàng
+type InsufficientStockError struct {
+	BookID    uint
+	Title     string
+	Requested int
+	Available int
+}
+
+func (e *InsufficientStockError) Error() string {
+	return fmt.Sprintf("Not enough stock for book %s (requested %d, available %d)", e.Title, e.Requested, e.Available)
+}
+
+// ReserveStock khóa các dòng sách (SELECT ... FOR UPDATE) và trừ tồn kho.
+// Phải được gọi bên trong transaction; sách được khóa theo thứ tự ID tăng dần
+// để tránh deadlock khi hai đơn hàng cùng giữ nhiều cuốn.
+func ReserveStock(tx *gorm.DB, quantities map[uint]int) (map[uint]Book, error) {
+	bookIDs := sortedBookIDs(quantities)
+	books := make(map[uint]Book, len(bookIDs))
+	for _, bookID := range bookIDs {
+		quantity := quantities[bookID]
+
+		var book Book
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&book, bookID).Error; err != nil {
+			return nil, err
+		}
+
+		if book.Stock < quantity {
+			return nil, &InsufficientStockError{
+				BookID:    book.ID,
+				Title:     book.Title,
+				Requested: quantity,
+				Available: book.Stock,
+			}
+		}
+
+		if err := tx.Model(&Book{}).
+			Where("id = ?", book.ID).
+			UpdateColumn("stock", gorm.Expr("stock - ?", quantity)).Error; err != nil {
+			return nil, err
+		}
+
+		book.Stock -= quantity
+		books[bookID] = book
+	}
+
+	return books, nil
+}
+
+// ReleaseStock hoàn trả tồn kho đã giữ (kể cả sách đã bị xóa mềm).
+// Cập nhật theo cùng thứ tự ID tăng dần như ReserveStock để tránh deadlock.
+func ReleaseStock(tx *gorm.DB, quantities map[uint]int) error {
+	for _, bookID := range sortedBookIDs(quantities) {
+		quantity := quantities[bookID]
+		if err := tx.Unscoped().Model(&Book{}).
+			Where("id = ?", bookID).
+			UpdateColumn("stock", gorm.Expr("stock + ?", quantity)).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sortedBookIDs trả về ID sách theo thứ tự tăng dần, là thứ tự khóa dòng thống nhất
+func sortedBookIDs(quantities map[uint]int) []uint {
+	bookIDs := make([]uint, 0, len(quantities))
+	for bookID := range quantities {
+		bookIDs = append(bookIDs, bookID)
+	}
+	sort.Slice(bookIDs, func(i, j int) bool { return bookIDs[i] < bookIDs[j] })
+	return bookIDs
+}
+
+// ReleaseOrderStock hoàn trả tồn kho của toàn bộ sách trong một đơn hàng
+func ReleaseOrderStock(tx *gorm.DB, orderID uint) error {
+	var items []OrderItem
+	if err := tx.Where("order_id = ?", orderID).Find(&items).Error; err != nil {
+		return err
+	}
+	return ReleaseStock(tx, OrderItemQuantities(items))
+}
+
+// OrderItemQuantities gộp số lượng theo từng sách
+func OrderItemQuantities(items []OrderItem) map[uint]int {
+	quantities := make(map[uint]int)
+	for _, item := range items {
+		quantities[item.BookID] += item.Quantity
+	}
+	return quantities
+}
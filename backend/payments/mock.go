@@ -0,0 +1,196 @@
+package payments
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SignatureHeader là header chứa chữ ký webhook của mock provider
+const SignatureHeader = "X-Mock-Signature"
+
+// Chữ ký quá thời gian này sẽ bị từ chối (chống replay)
+const signatureTolerance = 5 * time.Minute
+
+// MockProvider giả lập cổng thanh toán chạy trong cùng process.
+// Mỗi thay đổi trạng thái được gửi lại qua webhook có ký HMAC giống cổng thật.
+type MockProvider struct {
+	secret  []byte
+	intents map[string]*Intent
+	handler WebhookHandler
+	sync.Mutex
+}
+
+func NewMockProvider(secret string) *MockProvider {
+	return &MockProvider{secret: []byte(secret), intents: make(map[string]*Intent)}
+}
+
+func (m *MockProvider) Name() string {
+	return "mock"
+}
+
+// SetWebhookHandler đăng ký nơi nhận webhook in-process
+func (m *MockProvider) SetWebhookHandler(handler WebhookHandler) {
+	m.Lock()
+	m.handler = handler
+	m.Unlock()
+}
+
+func (m *MockProvider) CreateIntent(ctx context.Context, amount float64, currency string, metadata map[string]string) (*Intent, error) {
+	if amount <= 0 {
+		return nil, fmt.Errorf("amount must be positive")
+	}
+
+	intent := &Intent{
+		ID:           "pi_mock_" + randomHex(12),
+		Amount:       amount,
+		Currency:     currency,
+		Status:       IntentRequiresConfirmation,
+		ClientSecret: randomHex(16),
+		Metadata:     metadata,
+	}
+
+	m.Lock()
+	m.intents[intent.ID] = intent
+	m.Unlock()
+
+	copied := *intent
+	return &copied, nil
+}
+
+func (m *MockProvider) Confirm(ctx context.Context, intentID string) (*Intent, error) {
+	m.Lock()
+	intent, ok := m.intents[intentID]
+	if !ok {
+		m.Unlock()
+		return nil, ErrIntentNotFound
+	}
+	if intent.Status != IntentRequiresConfirmation {
+		m.Unlock()
+		return nil, ErrInvalidState
+	}
+	intent.Status = IntentSucceeded
+	copied := *intent
+	m.Unlock()
+
+	if err := m.dispatch(EventPaymentSucceeded, &copied); err != nil {
+		return nil, err
+	}
+	return &copied, nil
+}
+
+func (m *MockProvider) Refund(ctx context.Context, intentID string, amount float64) (*Intent, error) {
+	m.Lock()
+	intent, ok := m.intents[intentID]
+	if !ok {
+		m.Unlock()
+		return nil, ErrIntentNotFound
+	}
+	if intent.Status != IntentSucceeded {
+		m.Unlock()
+		return nil, ErrInvalidState
+	}
+	if amount <= 0 || amount > intent.Amount {
+		amount = intent.Amount
+	}
+	intent.Status = IntentRefunded
+	copied := *intent
+	copied.Amount = amount
+	m.Unlock()
+
+	if err := m.dispatch(EventPaymentRefunded, &copied); err != nil {
+		return nil, err
+	}
+	return &copied, nil
+}
+
+func (m *MockProvider) VerifyWebhook(payload []byte, signature string) (*Event, error) {
+	var timestamp, provided string
+	for _, part := range strings.Split(signature, ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "t":
+			timestamp = kv[1]
+		case "v1":
+			provided = kv[1]
+		}
+	}
+	if timestamp == "" || provided == "" {
+		return nil, ErrInvalidSignature
+	}
+
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || time.Since(time.Unix(ts, 0)) > signatureTolerance {
+		return nil, ErrInvalidSignature
+	}
+
+	expected := m.sign(timestamp, payload)
+	if !hmac.Equal([]byte(expected), []byte(provided)) {
+		return nil, ErrInvalidSignature
+	}
+
+	var event Event
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, fmt.Errorf("decode webhook payload: %w", err)
+	}
+	return &event, nil
+}
+
+// SignPayload tạo header chữ ký cho payload (dùng khi gửi webhook giả lập)
+func (m *MockProvider) SignPayload(payload []byte) string {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	return fmt.Sprintf("t=%s,v1=%s", timestamp, m.sign(timestamp, payload))
+}
+
+func (m *MockProvider) sign(timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, m.secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// dispatch gửi webhook đã ký tới handler in-process (nếu đã đăng ký)
+func (m *MockProvider) dispatch(eventType string, intent *Intent) error {
+	m.Lock()
+	handler := m.handler
+	m.Unlock()
+
+	if handler == nil {
+		log.Printf("[Payment] Mock provider chưa có webhook handler, bỏ qua sự kiện %s", eventType)
+		return nil
+	}
+
+	payload, err := json.Marshal(Event{
+		ID:       "evt_mock_" + randomHex(12),
+		Type:     eventType,
+		IntentID: intent.ID,
+		Amount:   intent.Amount,
+		Created:  time.Now().Unix(),
+	})
+	if err != nil {
+		return err
+	}
+
+	return handler(payload, m.SignPayload(payload))
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,72 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Poloni84Learning/ebook-store/config"
+)
+
+type IntentStatus string
+
+const (
+	IntentRequiresConfirmation IntentStatus = "requires_confirmation"
+	IntentSucceeded            IntentStatus = "succeeded"
+	IntentFailed               IntentStatus = "failed"
+	IntentRefunded             IntentStatus = "refunded"
+)
+
+// Các loại sự kiện webhook mà provider gửi về
+const (
+	EventPaymentSucceeded = "payment.succeeded"
+	EventPaymentFailed    = "payment.failed"
+	EventPaymentRefunded  = "payment.refunded"
+)
+
+var (
+	ErrIntentNotFound   = errors.New("payment intent not found")
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	ErrInvalidState     = errors.New("payment intent is not in a valid state for this operation")
+)
+
+// Intent là một yêu cầu thu tiền đã được tạo ở phía cổng thanh toán
+type Intent struct {
+	ID           string            `json:"id"`
+	Amount       float64           `json:"amount"`
+	Currency     string            `json:"currency"`
+	Status       IntentStatus      `json:"status"`
+	ClientSecret string            `json:"client_secret,omitempty"`
+	Metadata     map[string]string `json:"metadata,omitempty"`
+}
+
+// Event là nội dung webhook đã được xác thực chữ ký
+type Event struct {
+	ID       string  `json:"id"`
+	Type     string  `json:"type"`
+	IntentID string  `json:"intent_id"`
+	Amount   float64 `json:"amount"`
+	Created  int64   `json:"created"`
+}
+
+// PaymentProvider là giao diện chung cho mọi cổng thanh toán
+type PaymentProvider interface {
+	Name() string
+	CreateIntent(ctx context.Context, amount float64, currency string, metadata map[string]string) (*Intent, error)
+	Confirm(ctx context.Context, intentID string) (*Intent, error)
+	Refund(ctx context.Context, intentID string, amount float64) (*Intent, error)
+	VerifyWebhook(payload []byte, signature string) (*Event, error)
+}
+
+// WebhookHandler nhận webhook đã ký (dùng cho provider chạy in-process)
+type WebhookHandler func(payload []byte, signature string) error
+
+// NewProvider khởi tạo provider theo cấu hình
+func NewProvider(cfg *config.Config) (PaymentProvider, error) {
+	switch cfg.PaymentProvider {
+	case "", "mock":
+		return NewMockProvider(cfg.PaymentWebhookSecret), nil
+	default:
+		return nil, fmt.Errorf("unsupported payment provider: %s", cfg.PaymentProvider)
+	}
+}
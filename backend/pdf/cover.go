@@ -0,0 +1,89 @@
+package pdf
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrNoPageImage = errors.New("no embedded image on first page")
+
+// Ảnh nhỏ hơn kích thước này thường là logo/biểu tượng, không phải ảnh bìa
+const minCoverImageSide = 150
+
+// FirstPageImage trả về dữ liệu JPEG của ảnh lớn nhất nhúng trong trang đầu (thường là
+// trang bìa của ebook). Dùng bộ đọc PDF của package này, không cần công cụ render ngoài.
+func FirstPageImage(src []byte) ([]byte, error) {
+	doc, err := Parse(src)
+	if err != nil {
+		return nil, err
+	}
+	root, err := doc.DerefDict(doc.trailer.Get("Root"))
+	if err != nil {
+		return nil, err
+	}
+	_, page, _, err := doc.FirstPage(root.Get("Pages"))
+	if err != nil {
+		return nil, err
+	}
+
+	resources, err := doc.pageResources(page)
+	if err != nil {
+		return nil, err
+	}
+	xobjects, err := doc.DerefDict(resources.Get("XObject"))
+	if err != nil {
+		return nil, ErrNoPageImage
+	}
+
+	var best *Stream
+	bestArea := 0
+	for _, key := range xobjects.keys {
+		obj, err := doc.Deref(xobjects.Get(key))
+		if err != nil {
+			continue
+		}
+		s, ok := obj.(*Stream)
+		if !ok || s.Dict.Get("Subtype") != Name("Image") || !isDCT(s.Dict.Get("Filter")) {
+			continue
+		}
+		w, h := IntValue(s.Dict.Get("Width")), IntValue(s.Dict.Get("Height"))
+		if w < minCoverImageSide || h < minCoverImageSide {
+			continue
+		}
+		if w*h > bestArea {
+			best, bestArea = s, w*h
+		}
+	}
+	if best == nil {
+		return nil, ErrNoPageImage
+	}
+	return best.Data, nil
+}
+
+// pageResources trả về /Resources của trang, có thể kế thừa từ node cha trong cây trang
+func (d *Document) pageResources(page *Dict) (*Dict, error) {
+	node := page
+	for depth := 0; depth < 64 && node != nil; depth++ {
+		if node.Get("Resources") != nil {
+			return d.DerefDict(node.Get("Resources"))
+		}
+		parent := node.Get("Parent")
+		if parent == nil {
+			break
+		}
+		next, err := d.DerefDict(parent)
+		if err != nil {
+			return nil, err
+		}
+		node = next
+	}
+	return nil, fmt.Errorf("%w: page has no /Resources", ErrMalformedPDF)
+}
+
+// isDCT cho biết stream chỉ dùng bộ lọc DCTDecode (dữ liệu là file JPEG hoàn chỉnh)
+func isDCT(filter Object) bool {
+	if arr, ok := filter.(Array); ok && len(arr) == 1 {
+		filter = arr[0]
+	}
+	return filter == Name("DCTDecode")
+}
@@ -0,0 +1,841 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Bộ đọc PDF tối giản: chỉ đủ để đọc xref (bảng hoặc xref stream), trailer,
+// object stream và các dictionary cần thiết cho việc ghi incremental update
+// (đóng dấu), trích text/mục lục và lấy ảnh bìa. Mọi offset, độ dài và độ sâu
+// lồng nhau đều được kiểm tra: file hỏng trả về ErrMalformedPDF, không panic.
+
+var (
+	ErrMalformedPDF = errors.New("malformed PDF")
+	ErrEncryptedPDF = errors.New("encrypted PDF is not supported")
+)
+
+const (
+	maxNesting      = 256 // Độ sâu tối đa của array/dictionary lồng nhau
+	maxResolveDepth = 32  // Độ sâu tối đa khi resolve tham chiếu lồng nhau (ví dụ /Length trỏ vòng)
+	maxFieldWidth   = 8   // Độ rộng tối đa (byte) của một trường trong xref stream
+
+	maxObjects     = 1 << 20  // Số object tối đa khai báo trong xref
+	maxDecodedSize = 64 << 20 // Kích thước tối đa của một stream sau khi giải nén
+)
+
+type (
+	Object    interface{}
+	Name      string // Tên PDF ở dạng thô, không có dấu "/"
+	Keyword   string // Số, true/false, null ở dạng thô
+	RawString []byte // Chuỗi PDF ở dạng thô, gồm cả dấu () hoặc <>
+	Array     []Object
+	Ref       struct{ Num, Gen int }
+)
+
+// Dict giữ thứ tự key như trong file để ghi lại không làm đổi nội dung
+type Dict struct {
+	keys []string
+	vals map[string]Object
+}
+
+// Stream là stream object: dictionary và dữ liệu thô
+type Stream struct {
+	Dict *Dict
+	Data []byte // Dữ liệu đã mã hóa (chưa giải nén)
+}
+
+func NewDict() *Dict {
+	return &Dict{vals: make(map[string]Object)}
+}
+
+func (d *Dict) Get(key string) Object {
+	return d.vals[key]
+}
+
+func (d *Dict) Set(key string, val Object) {
+	if _, ok := d.vals[key]; !ok {
+		d.keys = append(d.keys, key)
+	}
+	d.vals[key] = val
+}
+
+func (d *Dict) Clone() *Dict {
+	c := NewDict()
+	for _, k := range d.keys {
+		c.Set(k, d.vals[k])
+	}
+	return c
+}
+
+// ---- Lexer / parser ----
+
+type parser struct {
+	buf   []byte
+	pos   int
+	depth int // Độ sâu array/dictionary đang đọc
+}
+
+func isWhite(b byte) bool {
+	return b == ' ' || b == '\n' || b == '\r' || b == '\t' || b == '\f' || b == 0
+}
+
+func isDelim(b byte) bool {
+	return bytes.IndexByte([]byte("()<>[]{}/%"), b) >= 0
+}
+
+func (p *parser) skipWhite() {
+	for p.pos < len(p.buf) {
+		b := p.buf[p.pos]
+		if isWhite(b) {
+			p.pos++
+		} else if b == '%' {
+			for p.pos < len(p.buf) && p.buf[p.pos] != '\n' && p.buf[p.pos] != '\r' {
+				p.pos++
+			}
+		} else {
+			return
+		}
+	}
+}
+
+func (p *parser) regular() string {
+	if p.pos >= len(p.buf) {
+		return ""
+	}
+	start := p.pos
+	for p.pos < len(p.buf) && !isWhite(p.buf[p.pos]) && !isDelim(p.buf[p.pos]) {
+		p.pos++
+	}
+	return string(p.buf[start:p.pos])
+}
+
+func (p *parser) hasPrefix(s string) bool {
+	return p.pos < len(p.buf) && bytes.HasPrefix(p.buf[p.pos:], []byte(s))
+}
+
+func (p *parser) parseObject() (Object, error) {
+	p.skipWhite()
+	if p.pos >= len(p.buf) {
+		return nil, ErrMalformedPDF
+	}
+
+	switch b := p.buf[p.pos]; {
+	case p.hasPrefix("<<"):
+		return p.parseDict()
+	case b == '<':
+		end := bytes.IndexByte(p.buf[p.pos:], '>')
+		if end < 0 {
+			return nil, ErrMalformedPDF
+		}
+		s := RawString(p.buf[p.pos : p.pos+end+1])
+		p.pos += end + 1
+		return s, nil
+	case b == '(':
+		return p.parseLiteral()
+	case b == '[':
+		if p.depth >= maxNesting {
+			return nil, fmt.Errorf("%w: nesting too deep", ErrMalformedPDF)
+		}
+		p.depth++
+		defer func() { p.depth-- }()
+		p.pos++
+		var arr Array
+		for {
+			p.skipWhite()
+			if p.pos >= len(p.buf) {
+				return nil, ErrMalformedPDF
+			}
+			if p.buf[p.pos] == ']' {
+				p.pos++
+				return arr, nil
+			}
+			obj, err := p.parseObject()
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, obj)
+		}
+	case b == '/':
+		p.pos++
+		return Name(p.regular()), nil
+	default:
+		tok := p.regular()
+		if tok == "" {
+			return nil, fmt.Errorf("%w: unexpected byte %q at %d", ErrMalformedPDF, b, p.pos)
+		}
+		// "n g R" là tham chiếu gián tiếp
+		if num, err := strconv.Atoi(tok); err == nil {
+			save := p.pos
+			p.skipWhite()
+			genTok := p.regular()
+			if gen, err := strconv.Atoi(genTok); err == nil {
+				p.skipWhite()
+				if p.pos < len(p.buf) && p.buf[p.pos] == 'R' &&
+					(p.pos+1 == len(p.buf) || isWhite(p.buf[p.pos+1]) || isDelim(p.buf[p.pos+1])) {
+					p.pos++
+					return Ref{num, gen}, nil
+				}
+			}
+			p.pos = save
+		}
+		return Keyword(tok), nil
+	}
+}
+
+func (p *parser) parseLiteral() (Object, error) {
+	start := p.pos
+	depth := 0
+	for p.pos < len(p.buf) {
+		switch p.buf[p.pos] {
+		case '\\':
+			p.pos++
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				p.pos++
+				return RawString(p.buf[start:p.pos]), nil
+			}
+		}
+		p.pos++
+	}
+	return nil, ErrMalformedPDF
+}
+
+func (p *parser) parseDict() (*Dict, error) {
+	if p.depth >= maxNesting {
+		return nil, fmt.Errorf("%w: nesting too deep", ErrMalformedPDF)
+	}
+	p.depth++
+	defer func() { p.depth-- }()
+	p.pos += 2
+	d := NewDict()
+	for {
+		p.skipWhite()
+		if p.pos >= len(p.buf) {
+			return nil, ErrMalformedPDF
+		}
+		if p.hasPrefix(">>") {
+			p.pos += 2
+			return d, nil
+		}
+		key, err := p.parseObject()
+		if err != nil {
+			return nil, err
+		}
+		k, ok := key.(Name)
+		if !ok {
+			return nil, fmt.Errorf("%w: dictionary key is not a name", ErrMalformedPDF)
+		}
+		val, err := p.parseObject()
+		if err != nil {
+			return nil, err
+		}
+		d.Set(string(k), val)
+	}
+}
+
+// ---- Document ----
+
+type xrefEntry struct {
+	offset int // Vị trí trong file (type 1)
+	stream int // Số object stream chứa object (type 2)
+	index  int
+	inStm  bool
+}
+
+type Document struct {
+	buf       []byte
+	base      int // Vị trí của buf[0] trong file: khác 0 khi chỉ nạp một đoạn file (ValidateXref)
+	size      int // Kích thước cả file
+	xref      map[int]xrefEntry
+	trailer   *Dict
+	startx    int // Vị trí xref mới nhất, dùng làm /Prev
+	objStms   map[int][]byte
+	resolving int // Số lần resolve đang lồng nhau
+}
+
+// Parse đọc xref và trailer của file; object chỉ được đọc khi cần (Deref)
+func Parse(buf []byte) (*Document, error) {
+	doc, err := readDocumentXref(buf)
+	if err != nil {
+		return nil, err
+	}
+	if doc.trailer == nil || doc.trailer.Get("Root") == nil {
+		return nil, fmt.Errorf("%w: trailer has no /Root", ErrMalformedPDF)
+	}
+	if doc.trailer.Get("Encrypt") != nil {
+		return nil, ErrEncryptedPDF
+	}
+	return doc, nil
+}
+
+// readDocumentXref đọc startxref và các section xref của file
+func readDocumentXref(buf []byte) (*Document, error) {
+	idx := bytes.LastIndex(buf, []byte("startxref"))
+	if idx < 0 {
+		return nil, fmt.Errorf("%w: startxref not found", ErrMalformedPDF)
+	}
+	p := &parser{buf: buf, pos: idx + len("startxref")}
+	p.skipWhite()
+	startx, err := strconv.Atoi(p.regular())
+	if err != nil || startx <= 0 || startx >= len(buf) {
+		return nil, fmt.Errorf("%w: bad startxref", ErrMalformedPDF)
+	}
+
+	doc := &Document{buf: buf, size: len(buf), xref: make(map[int]xrefEntry), startx: startx, objStms: make(map[int][]byte)}
+	visited := make(map[int]bool)
+	if err := doc.readXref(startx, visited); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// Trailer trả về trailer dictionary của section xref mới nhất
+func (d *Document) Trailer() *Dict {
+	return d.trailer
+}
+
+// Version trả về phiên bản PDF hiệu lực: lớn hơn giữa header và /Version trong catalog
+func (d *Document) Version() float64 {
+	v := 0.0
+	if bytes.HasPrefix(d.buf, []byte("%PDF-")) && len(d.buf) >= 8 {
+		v, _ = strconv.ParseFloat(string(d.buf[5:8]), 64)
+	}
+	if root, err := d.DerefDict(d.trailer.Get("Root")); err == nil {
+		if n, ok := root.Get("Version").(Name); ok {
+			if cv, err := strconv.ParseFloat(string(n), 64); err == nil && cv > v {
+				v = cv
+			}
+		}
+	}
+	return v
+}
+
+// readXref đọc section xref tại offset rồi lần lượt các section cũ hơn (/XRefStm, /Prev).
+// Section mới hơn được đọc trước nên không ghi đè entry đã có.
+func (d *Document) readXref(offset int, visited map[int]bool) error {
+	if offset <= 0 || offset >= d.size || visited[offset] {
+		return nil
+	}
+	visited[offset] = true
+
+	trailer, err := d.readXrefSection(offset)
+	if err != nil {
+		return err
+	}
+	if d.trailer == nil {
+		d.trailer = trailer
+	}
+	for _, next := range olderXrefSections(trailer) {
+		if err := d.readXref(next, visited); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// olderXrefSections trả về vị trí các section cần đọc tiếp theo thứ tự ưu tiên:
+// xref stream bổ sung của file hybrid (/XRefStm) rồi tới section trước (/Prev)
+func olderXrefSections(trailer *Dict) []int {
+	var offsets []int
+	for _, key := range []string{"XRefStm", "Prev"} {
+		if k, ok := trailer.Get(key).(Keyword); ok {
+			if off, err := strconv.Atoi(string(k)); err == nil {
+				offsets = append(offsets, off)
+			}
+		}
+	}
+	return offsets
+}
+
+// readXrefSection đọc một bảng xref (kèm trailer) hoặc một xref stream tại offset, trả về trailer của section
+func (d *Document) readXrefSection(offset int) (*Dict, error) {
+	pos := offset - d.base
+	if pos < 0 || pos >= len(d.buf) {
+		return nil, fmt.Errorf("%w: xref offset %d out of range", ErrMalformedPDF, offset)
+	}
+	p := &parser{buf: d.buf, pos: pos}
+	p.skipWhite()
+
+	if !p.hasPrefix("xref") {
+		_, obj, err := d.parseIndirectAt(offset)
+		if err != nil {
+			return nil, err
+		}
+		s, ok := obj.(*Stream)
+		if !ok || s.Dict.Get("Type") != Name("XRef") {
+			return nil, fmt.Errorf("%w: startxref does not point to xref", ErrMalformedPDF)
+		}
+		if err := d.readXrefStream(s); err != nil {
+			return nil, err
+		}
+		return s.Dict, nil
+	}
+
+	p.pos += len("xref")
+	for {
+		p.skipWhite()
+		if p.hasPrefix("trailer") {
+			p.pos += len("trailer")
+			break
+		}
+		first, err1 := strconv.Atoi(p.regular())
+		p.skipWhite()
+		count, err2 := strconv.Atoi(p.regular())
+		// Mỗi entry dài ít nhất "0 0 n" nên số entry không thể vượt quá phần còn lại của file
+		if err1 != nil || err2 != nil || first < 0 || count < 0 || count > (len(p.buf)-p.pos)/5 {
+			return nil, fmt.Errorf("%w: bad xref subsection", ErrMalformedPDF)
+		}
+		for i := 0; i < count; i++ {
+			p.skipWhite()
+			if p.pos >= len(p.buf) {
+				return nil, fmt.Errorf("%w: truncated xref table", ErrMalformedPDF)
+			}
+			off, _ := strconv.Atoi(p.regular())
+			p.skipWhite()
+			p.regular() // generation
+			p.skipWhite()
+			kind := p.regular()
+			if kind != "n" && kind != "f" {
+				return nil, fmt.Errorf("%w: bad xref entry", ErrMalformedPDF)
+			}
+			entry := xrefEntry{offset: -1}
+			if kind == "n" {
+				entry.offset = off
+			}
+			if err := d.addXrefEntry(first+i, entry); err != nil {
+				return nil, err
+			}
+		}
+	}
+	obj, err := p.parseObject()
+	if err != nil {
+		return nil, err
+	}
+	trailer, ok := obj.(*Dict)
+	if !ok {
+		return nil, fmt.Errorf("%w: bad trailer", ErrMalformedPDF)
+	}
+	return trailer, nil
+}
+
+// addXrefEntry ghi entry nếu section mới hơn chưa khai báo object này
+func (d *Document) addXrefEntry(num int, entry xrefEntry) error {
+	if _, exists := d.xref[num]; exists {
+		return nil
+	}
+	if len(d.xref) >= maxObjects {
+		return fmt.Errorf("%w: more than %d objects", ErrMalformedPDF, maxObjects)
+	}
+	d.xref[num] = entry
+	return nil
+}
+
+func (d *Document) readXrefStream(s *Stream) error {
+	data, err := d.decodeStream(s)
+	if err != nil {
+		return err
+	}
+
+	w, ok := s.Dict.Get("W").(Array)
+	if !ok || len(w) != 3 {
+		return fmt.Errorf("%w: bad /W in xref stream", ErrMalformedPDF)
+	}
+	widths := make([]int, 3)
+	for i := range w {
+		widths[i] = IntValue(w[i])
+		if widths[i] < 0 || widths[i] > maxFieldWidth {
+			return fmt.Errorf("%w: bad /W in xref stream", ErrMalformedPDF)
+		}
+	}
+	rowLen := widths[0] + widths[1] + widths[2]
+	if rowLen == 0 {
+		return fmt.Errorf("%w: bad /W in xref stream", ErrMalformedPDF)
+	}
+
+	index := []int{0, IntValue(s.Dict.Get("Size"))}
+	if arr, ok := s.Dict.Get("Index").(Array); ok {
+		index = index[:0]
+		for _, v := range arr {
+			index = append(index, IntValue(v))
+		}
+	}
+
+	pos := 0
+	for i := 0; i+1 < len(index); i += 2 {
+		for n := 0; n < index[i+1]; n++ {
+			if pos+rowLen > len(data) {
+				return nil
+			}
+			row := data[pos : pos+rowLen]
+			pos += rowLen
+
+			kind := 1 // Mặc định khi W[0] = 0
+			if widths[0] > 0 {
+				kind = readField(row[:widths[0]])
+			}
+			f2 := readField(row[widths[0] : widths[0]+widths[1]])
+			f3 := readField(row[widths[0]+widths[1]:])
+
+			entry := xrefEntry{offset: -1}
+			switch kind {
+			case 1:
+				entry = xrefEntry{offset: f2}
+			case 2:
+				entry = xrefEntry{stream: f2, index: f3, inStm: true}
+			}
+			if err := d.addXrefEntry(index[i]+n, entry); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func readField(b []byte) int {
+	v := 0
+	for _, c := range b {
+		v = v<<8 | int(c)
+	}
+	return v
+}
+
+// parseIndirectAt đọc "n g obj ... endobj" tại vị trí offset
+func (d *Document) parseIndirectAt(offset int) (Ref, Object, error) {
+	pos := offset - d.base
+	if pos < 0 || pos >= len(d.buf) {
+		return Ref{}, nil, fmt.Errorf("%w: object offset %d out of range", ErrMalformedPDF, offset)
+	}
+	p := &parser{buf: d.buf, pos: pos}
+	p.skipWhite()
+	num, err1 := strconv.Atoi(p.regular())
+	p.skipWhite()
+	gen, err2 := strconv.Atoi(p.regular())
+	p.skipWhite()
+	if err1 != nil || err2 != nil || !p.hasPrefix("obj") {
+		return Ref{}, nil, fmt.Errorf("%w: no object at offset %d", ErrMalformedPDF, offset)
+	}
+	p.pos += len("obj")
+
+	obj, err := p.parseObject()
+	if err != nil {
+		return Ref{}, nil, err
+	}
+
+	p.skipWhite()
+	if dd, ok := obj.(*Dict); ok && p.hasPrefix("stream") {
+		p.pos += len("stream")
+		if p.hasPrefix("\r\n") {
+			p.pos += 2
+		} else if p.hasPrefix("\n") || p.hasPrefix("\r") {
+			p.pos++
+		}
+
+		length := -1
+		switch l := dd.Get("Length").(type) {
+		case Keyword:
+			length, _ = strconv.Atoi(string(l))
+		case Ref:
+			if v, err := d.resolve(l); err == nil {
+				if k, ok := v.(Keyword); ok {
+					length, _ = strconv.Atoi(string(k))
+				}
+			}
+		}
+		if length > len(d.buf)-p.pos && length <= d.size-d.base-p.pos {
+			// Stream hợp lệ nhưng vượt quá đoạn file đang nạp
+			return Ref{}, nil, fmt.Errorf("%w: stream of object %d is truncated", ErrMalformedPDF, num)
+		}
+		if length < 0 || length > len(d.buf)-p.pos {
+			end := bytes.Index(d.buf[p.pos:], []byte("endstream"))
+			if end < 0 {
+				return Ref{}, nil, fmt.Errorf("%w: unterminated stream", ErrMalformedPDF)
+			}
+			length = end
+		}
+		return Ref{num, gen}, &Stream{Dict: dd, Data: d.buf[p.pos : p.pos+length]}, nil
+	}
+	return Ref{num, gen}, obj, nil
+}
+
+// resolve trả về giá trị của object gián tiếp
+func (d *Document) resolve(r Ref) (Object, error) {
+	if d.resolving >= maxResolveDepth {
+		return nil, fmt.Errorf("%w: reference chain too deep at object %d", ErrMalformedPDF, r.Num)
+	}
+	d.resolving++
+	defer func() { d.resolving-- }()
+
+	entry, ok := d.xref[r.Num]
+	if !ok || (!entry.inStm && entry.offset < 0) {
+		return Keyword("null"), nil
+	}
+
+	if !entry.inStm {
+		_, obj, err := d.parseIndirectAt(entry.offset)
+		return obj, err
+	}
+
+	data, err := d.objectStream(entry.stream)
+	if err != nil {
+		return nil, err
+	}
+	return parseFromObjectStream(data, r.Num, entry.index)
+}
+
+// Deref trả về giá trị, tự resolve nếu là tham chiếu
+func (d *Document) Deref(obj Object) (Object, error) {
+	if r, ok := obj.(Ref); ok {
+		return d.resolve(r)
+	}
+	return obj, nil
+}
+
+// DerefDict giống Deref nhưng yêu cầu kết quả là dictionary
+func (d *Document) DerefDict(obj Object) (*Dict, error) {
+	v, err := d.Deref(obj)
+	if err != nil {
+		return nil, err
+	}
+	switch t := v.(type) {
+	case *Dict:
+		return t, nil
+	case *Stream:
+		return t.Dict, nil
+	}
+	return nil, fmt.Errorf("%w: expected dictionary", ErrMalformedPDF)
+}
+
+func (d *Document) objectStream(num int) ([]byte, error) {
+	if data, ok := d.objStms[num]; ok {
+		return data, nil
+	}
+	entry, ok := d.xref[num]
+	if !ok || entry.inStm || entry.offset < 0 {
+		return nil, fmt.Errorf("%w: object stream %d not found", ErrMalformedPDF, num)
+	}
+	_, obj, err := d.parseIndirectAt(entry.offset)
+	if err != nil {
+		return nil, err
+	}
+	s, ok := obj.(*Stream)
+	if !ok {
+		return nil, fmt.Errorf("%w: object %d is not a stream", ErrMalformedPDF, num)
+	}
+	data, err := d.decodeStream(s)
+	if err != nil {
+		return nil, err
+	}
+
+	// Lưu kèm /First ở đầu để parseFromObjectStream dùng
+	first := IntValue(s.Dict.Get("First"))
+	n := IntValue(s.Dict.Get("N"))
+	header := []byte(fmt.Sprintf("%d %d\n", first, n))
+	data = append(header, data...)
+	d.objStms[num] = data
+	return data, nil
+}
+
+func parseFromObjectStream(data []byte, num, index int) (Object, error) {
+	p := &parser{buf: data}
+	first, _ := strconv.Atoi(p.regular())
+	p.skipWhite()
+	n, _ := strconv.Atoi(p.regular())
+	p.skipWhite()
+	base := p.pos
+
+	for i := 0; i < n; i++ {
+		p.skipWhite()
+		objNum, err1 := strconv.Atoi(p.regular())
+		p.skipWhite()
+		off, err2 := strconv.Atoi(p.regular())
+		if err1 != nil || err2 != nil {
+			break
+		}
+		if i == index || objNum == num {
+			pos := base + first + off
+			if first < 0 || off < 0 || pos >= len(data) {
+				return nil, fmt.Errorf("%w: bad offset of object %d in object stream", ErrMalformedPDF, num)
+			}
+			op := &parser{buf: data, pos: pos}
+			return op.parseObject()
+		}
+	}
+	return nil, fmt.Errorf("%w: object %d not in object stream", ErrMalformedPDF, num)
+}
+
+// decodeStream giải nén stream; chỉ hỗ trợ FlateDecode (kèm PNG predictor) hoặc không nén
+func (d *Document) decodeStream(s *Stream) ([]byte, error) {
+	filter := s.Dict.Get("Filter")
+	if arr, ok := filter.(Array); ok {
+		if len(arr) > 1 {
+			return nil, fmt.Errorf("%w: multiple filters", ErrMalformedPDF)
+		}
+		if len(arr) == 1 {
+			filter = arr[0]
+		} else {
+			filter = nil
+		}
+	}
+
+	switch filter {
+	case nil:
+		return s.Data, nil
+	case Name("FlateDecode"):
+	default:
+		return nil, fmt.Errorf("%w: unsupported filter %v", ErrMalformedPDF, filter)
+	}
+
+	zr, err := zlib.NewReader(bytes.NewReader(s.Data))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	data, err := io.ReadAll(io.LimitReader(zr, maxDecodedSize+1))
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, err
+	}
+	if len(data) > maxDecodedSize {
+		return nil, fmt.Errorf("%w: stream larger than %d bytes after decompression", ErrMalformedPDF, maxDecodedSize)
+	}
+
+	params, _ := d.DerefDict(s.Dict.Get("DecodeParms"))
+	if arr, ok := s.Dict.Get("DecodeParms").(Array); ok && len(arr) == 1 {
+		params, _ = d.DerefDict(arr[0])
+	}
+	if params == nil || IntValue(params.Get("Predictor")) < 10 {
+		return data, nil
+	}
+
+	columns := IntValue(params.Get("Columns"))
+	if columns <= 0 {
+		columns = 1
+	}
+	return unpredictPNG(data, columns)
+}
+
+// unpredictPNG đảo PNG predictor (mỗi dòng có 1 byte loại filter), giả định 1 byte/pixel
+func unpredictPNG(data []byte, columns int) ([]byte, error) {
+	if columns >= len(data) {
+		return nil, fmt.Errorf("%w: bad PNG predictor columns", ErrMalformedPDF)
+	}
+	rowLen := columns + 1
+	out := make([]byte, 0, len(data)/rowLen*columns)
+	prev := make([]byte, columns)
+	for i := 0; i+rowLen <= len(data); i += rowLen {
+		ft := data[i]
+		row := append([]byte(nil), data[i+1:i+rowLen]...)
+		for j := range row {
+			var left, upLeft byte
+			if j > 0 {
+				left = row[j-1]
+				upLeft = prev[j-1]
+			}
+			up := prev[j]
+			switch ft {
+			case 0:
+			case 1:
+				row[j] += left
+			case 2:
+				row[j] += up
+			case 3:
+				row[j] += byte((int(left) + int(up)) / 2)
+			case 4:
+				row[j] += paeth(left, up, upLeft)
+			default:
+				return nil, fmt.Errorf("%w: bad PNG predictor %d", ErrMalformedPDF, ft)
+			}
+		}
+		out = append(out, row...)
+		prev = row
+	}
+	return out, nil
+}
+
+func paeth(a, b, c byte) byte {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := abs(p-int(a)), abs(p-int(b)), abs(p-int(c))
+	if pa <= pb && pa <= pc {
+		return a
+	}
+	if pb <= pc {
+		return b
+	}
+	return c
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+func IntValue(obj Object) int {
+	if k, ok := obj.(Keyword); ok {
+		if v, err := strconv.Atoi(string(k)); err == nil {
+			return v
+		}
+		if f, err := strconv.ParseFloat(string(k), 64); err == nil {
+			return int(f)
+		}
+	}
+	return 0
+}
+
+func FloatValue(obj Object) (float64, bool) {
+	if k, ok := obj.(Keyword); ok {
+		f, err := strconv.ParseFloat(string(k), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// ---- Serialization ----
+
+func writeObject(w *bytes.Buffer, obj Object) {
+	switch v := obj.(type) {
+	case nil:
+		w.WriteString("null")
+	case Name:
+		w.WriteByte('/')
+		w.WriteString(string(v))
+	case Keyword:
+		w.WriteString(string(v))
+	case RawString:
+		w.Write(v)
+	case Ref:
+		fmt.Fprintf(w, "%d %d R", v.Num, v.Gen)
+	case Array:
+		w.WriteByte('[')
+		for i, item := range v {
+			if i > 0 {
+				w.WriteByte(' ')
+			}
+			writeObject(w, item)
+		}
+		w.WriteByte(']')
+	case *Dict:
+		w.WriteString("<<")
+		for _, k := range v.keys {
+			w.WriteString(" /")
+			w.WriteString(k)
+			w.WriteByte(' ')
+			writeObject(w, v.vals[k])
+		}
+		w.WriteString(" >>")
+	case *Stream:
+		v.Dict.Set("Length", Keyword(strconv.Itoa(len(v.Data))))
+		writeObject(w, v.Dict)
+		w.WriteString("\nstream\n")
+		w.Write(v.Data)
+		w.WriteString("\nendstream")
+	}
+}
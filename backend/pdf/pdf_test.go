@@ -0,0 +1,298 @@
+package pdf
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// buildPDF dựng file PDF với bảng xref trỏ đúng offset của từng object (object i+1 là objects[i]).
+// edit (nếu có) sửa offset trước khi ghi bảng xref để tạo xref hỏng.
+func buildPDF(objects []string, trailer string, edit func(offsets []int)) []byte {
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = b.Len()
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	if edit != nil {
+		edit(offsets)
+	}
+	xref := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d %s >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, trailer, xref)
+	return b.Bytes()
+}
+
+// buildXrefStreamPDF dựng file PDF 1.5 dùng xref stream không nén. objects[i] rỗng nghĩa là object i+1
+// nằm trong object stream theo inStm (số object stream, vị trí trong stream). w khác rỗng thì ghi đè /W.
+func buildXrefStreamPDF(objects []string, inStm map[int][2]int, w string) []byte {
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.5\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		if obj == "" {
+			continue
+		}
+		offsets[i] = b.Len()
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+
+	xrefNum := len(objects) + 1
+	xref := b.Len()
+	var rows bytes.Buffer
+	row := func(kind, f2, f3 int) {
+		rows.Write([]byte{byte(kind), byte(f2 >> 24), byte(f2 >> 16), byte(f2 >> 8), byte(f2), byte(f3 >> 8), byte(f3)})
+	}
+	row(0, 0, 0)
+	for i := range objects {
+		if loc, ok := inStm[i+1]; ok {
+			row(2, loc[0], loc[1])
+		} else {
+			row(1, offsets[i], 0)
+		}
+	}
+	row(1, xref, 0)
+	if w == "" {
+		w = "[1 4 2]"
+	}
+	fmt.Fprintf(&b, "%d 0 obj\n<< /Type /XRef /Size %d /Root 1 0 R /W %s /Length %d >>\nstream\n", xrefNum, xrefNum+1, w, rows.Len())
+	b.Write(rows.Bytes())
+	fmt.Fprintf(&b, "\nendstream\nendobj\nstartxref\n%d\n%%%%EOF\n", xref)
+	return b.Bytes()
+}
+
+// stream dựng stream object không nén
+func stream(dict, data string) string {
+	return fmt.Sprintf("<< /Length %d %s >>\nstream\n%s\nendstream", len(data), dict, data)
+}
+
+// objectStream dựng object stream chứa các object theo thứ tự; offsets khác nil thì ghi đè offset thật
+func objectStream(nums []int, bodies []string, offsets []int) string {
+	var header, body strings.Builder
+	for i, obj := range bodies {
+		off := body.Len()
+		if offsets != nil {
+			off = offsets[i]
+		}
+		fmt.Fprintf(&header, "%d %d ", nums[i], off)
+		body.WriteString(obj)
+		body.WriteString(" ")
+	}
+	return stream(fmt.Sprintf("/Type /ObjStm /N %d /First %d", len(bodies), header.Len()), header.String()+body.String())
+}
+
+const helloContent = "BT /F1 12 Tf 72 712 Td (Hello world) Tj ET"
+
+// minimalObjects là một trang có text và một mục bookmark
+func minimalObjects() []string {
+	return []string{
+		"<< /Type /Catalog /Pages 2 0 R /Outlines 5 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Contents 4 0 R /Resources << >> >>",
+		stream("", helloContent),
+		"<< /Type /Outlines /First 6 0 R /Last 6 0 R >>",
+		"<< /Title (Chapter 1) /Parent 5 0 R >>",
+	}
+}
+
+func minimalPDF() []byte {
+	return buildPDF(minimalObjects(), "/Root 1 0 R", nil)
+}
+
+// objectStreamPDF đặt catalog và cây trang (object 1, 2) trong object stream 7
+func objectStreamPDF(offsets []int) []byte {
+	objects := minimalObjects()
+	objects = append(objects, objectStream([]int{1, 2}, objects[:2], offsets))
+	objects[0], objects[1] = "", ""
+	return buildXrefStreamPDF(objects, map[int][2]int{1: {7, 0}, 2: {7, 1}}, "")
+}
+
+func validate(src []byte) error {
+	return ValidateXref(bytes.NewReader(src), int64(len(src)))
+}
+
+func TestReadMinimalPDF(t *testing.T) {
+	for name, src := range map[string][]byte{
+		"xref table":    minimalPDF(),
+		"object stream": objectStreamPDF(nil),
+	} {
+		t.Run(name, func(t *testing.T) {
+			if err := validate(src); err != nil {
+				t.Fatalf("ValidateXref: %v", err)
+			}
+			texts, err := PageTexts(src, 0)
+			if err != nil {
+				t.Fatalf("PageTexts: %v", err)
+			}
+			if len(texts) != 1 || !strings.Contains(texts[0], "Hello world") {
+				t.Errorf("PageTexts = %q, want one page containing %q", texts, "Hello world")
+			}
+			items, err := Outline(src)
+			if err != nil {
+				t.Fatalf("Outline: %v", err)
+			}
+			if want := []OutlineItem{{Title: "Chapter 1", Level: 0}}; !reflect.DeepEqual(items, want) {
+				t.Errorf("Outline = %+v, want %+v", items, want)
+			}
+			if _, err := FirstPageImage(src); !errors.Is(err, ErrNoPageImage) {
+				t.Errorf("FirstPageImage error = %v, want ErrNoPageImage", err)
+			}
+		})
+	}
+}
+
+func TestAppendUpdate(t *testing.T) {
+	src := minimalPDF()
+	doc, err := Parse(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := doc.DerefDict(doc.Trailer().Get("Root"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pageRef, page, mediaBox, err := doc.FirstPage(root.Get("Pages"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mediaBox != [4]float64{0, 0, 612, 792} {
+		t.Errorf("MediaBox = %v", mediaBox)
+	}
+
+	info := NewDict()
+	info.Set("Title", RawString("(Updated)"))
+	infoRef := Ref{Num: IntValue(doc.Trailer().Get("Size"))}
+	newPage := page.Clone()
+	newPage.Set("Rotate", Keyword("90"))
+	out := doc.AppendUpdate(map[Ref]Object{pageRef: newPage, infoRef: info}, infoRef.Num+1, infoRef)
+
+	if !bytes.HasPrefix(out, src) {
+		t.Fatal("incremental update must keep the original bytes")
+	}
+	if err := validate(out); err != nil {
+		t.Fatalf("ValidateXref after update: %v", err)
+	}
+	updated, err := Parse(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, _ = updated.DerefDict(updated.Trailer().Get("Root"))
+	_, page, _, err = updated.FirstPage(root.Get("Pages"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := page.Get("Rotate"); got != Keyword("90") {
+		t.Errorf("Rotate after update = %v, want 90", got)
+	}
+	if got, err := updated.DerefDict(updated.Trailer().Get("Info")); err != nil || got.Get("Title") == nil {
+		t.Errorf("Info after update = %v, %v", got, err)
+	}
+	if texts, err := PageTexts(out, 0); err != nil || len(texts) != 1 || !strings.Contains(texts[0], "Hello world") {
+		t.Errorf("PageTexts after update = %q, %v", texts, err)
+	}
+}
+
+// File hỏng: mọi hàm đọc phải trả lỗi (ErrMalformedPDF...) thay vì panic hoặc treo
+func TestMalformedPDF(t *testing.T) {
+	withObject := func(num int, obj string) []string {
+		objects := minimalObjects()
+		objects[num-1] = obj
+		return objects
+	}
+	setOffset := func(num, offset int) func([]int) {
+		return func(offsets []int) { offsets[num-1] = offset }
+	}
+	replace := func(src []byte, old, new string) []byte {
+		return bytes.Replace(src, []byte(old), []byte(new), 1)
+	}
+	minimal := minimalPDF()
+	startx := bytes.LastIndex(minimal, []byte("startxref"))
+
+	tests := []struct {
+		name        string
+		src         []byte
+		validateErr bool // ValidateXref phải từ chối file
+		parseErr    bool // Parse phải từ chối file
+	}{
+		{name: "object offset past end of file", src: buildPDF(minimalObjects(), "/Root 1 0 R", setOffset(3, 99999999)), validateErr: true},
+		{name: "startxref past end of file", src: replace(minimal, fmt.Sprintf("startxref\n%d", bytes.Index(minimal, []byte("xref\n0 "))), "startxref\n99999999"), validateErr: true, parseErr: true},
+		{name: "missing startxref", src: append(append([]byte(nil), minimal[:startx]...), "%%EOF\n"...), validateErr: true, parseErr: true},
+		{name: "truncated file", src: minimal[:len(minimal)/2], validateErr: true, parseErr: true},
+		{name: "xref subsection larger than file", src: replace(minimal, "xref\n0 7", "xref\n0 999999999"), validateErr: true, parseErr: true},
+		{name: "bad xref entry", src: replace(minimal, "00000 n", "00000 ]"), validateErr: true, parseErr: true},
+		{name: "prev points to itself", src: replace(minimal, "/Root 1 0 R", fmt.Sprintf("/Root 1 0 R /Prev %d", bytes.Index(minimal, []byte("xref\n0 "))))},
+		{name: "self-referencing length", src: buildPDF(withObject(4, "<< /Length 4 0 R >>\nstream\n"+helloContent+"\nendstream"), "/Root 1 0 R", nil)},
+		{name: "length reference loop", src: buildPDF(append(withObject(4, "<< /Length 7 0 R >>\nstream\n"+helloContent+"\nendstream"), "<< /Length 4 0 R >>\nstream\n0\nendstream"), "/Root 1 0 R", nil)},
+		{name: "length overflow", src: buildPDF(withObject(4, "<< /Length 9223372036854775000 >>\nstream\n"+helloContent+"\nendstream"), "/Root 1 0 R", nil)},
+		{name: "unterminated stream", src: buildPDF(withObject(4, "<< /Length 99999 >>\nstream\n"+helloContent), "/Root 1 0 R", nil)},
+		{name: "deeply nested array", src: buildPDF(withObject(3, "<< /Type /Page /Parent 2 0 R /MediaBox "+strings.Repeat("[", 100000)+" >>"), "/Root 1 0 R", nil)},
+		{name: "page tree cycle", src: buildPDF(withObject(2, "<< /Type /Pages /Kids [2 0 R] /Count 1 >>"), "/Root 1 0 R", nil)},
+		{name: "negative xref stream width", src: buildXrefStreamPDF(minimalObjects(), nil, "[1 -4 2]"), validateErr: true, parseErr: true},
+		{name: "xref stream width too large", src: buildXrefStreamPDF(minimalObjects(), nil, "[1 9 2]"), validateErr: true, parseErr: true},
+		{name: "zero xref stream widths", src: buildXrefStreamPDF(minimalObjects(), nil, "[0 0 0]"), validateErr: true, parseErr: true},
+		{name: "object stream offset past end", src: objectStreamPDF([]int{0, 99999999}), validateErr: true},
+		{name: "negative object stream offset", src: objectStreamPDF([]int{-5, 0}), validateErr: true},
+		{name: "missing object stream", src: buildXrefStreamPDF(withObject(1, ""), map[int][2]int{1: {9, 0}}, ""), validateErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validate(tt.src); (err != nil) != tt.validateErr {
+				t.Errorf("ValidateXref error = %v, want error %v", err, tt.validateErr)
+			} else if err != nil && !errors.Is(err, ErrMalformedPDF) {
+				t.Errorf("ValidateXref error = %v, want ErrMalformedPDF", err)
+			}
+			if _, err := Parse(tt.src); (err != nil) != tt.parseErr {
+				t.Errorf("Parse error = %v, want error %v", err, tt.parseErr)
+			}
+			readAll(t, tt.src)
+		})
+	}
+}
+
+func TestEncryptedPDF(t *testing.T) {
+	src := buildPDF(minimalObjects(), "/Root 1 0 R /Encrypt << /Filter /Standard >>", nil)
+	if _, err := Parse(src); !errors.Is(err, ErrEncryptedPDF) {
+		t.Errorf("Parse error = %v, want ErrEncryptedPDF", err)
+	}
+}
+
+// readAll gọi mọi hàm đọc PDF; lỗi trả về phải là lỗi của package, panic làm test thất bại
+func readAll(t *testing.T, src []byte) {
+	t.Helper()
+	check := func(fn string, err error) {
+		if err != nil && !errors.Is(err, ErrMalformedPDF) && !errors.Is(err, ErrEncryptedPDF) && !errors.Is(err, ErrNoPageImage) {
+			t.Errorf("%s: unexpected error %v", fn, err)
+		}
+	}
+	_, err := PageTexts(src, 0)
+	check("PageTexts", err)
+	_, err = Outline(src)
+	check("Outline", err)
+	_, err = FirstPageImage(src)
+	check("FirstPageImage", err)
+	if doc, err := Parse(src); err == nil {
+		if root, err := doc.DerefDict(doc.Trailer().Get("Root")); err == nil {
+			_, _, _, err = doc.FirstPage(root.Get("Pages"))
+			check("FirstPage", err)
+		}
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	f.Add(minimalPDF())
+	f.Add(objectStreamPDF(nil))
+	f.Fuzz(func(t *testing.T, src []byte) {
+		validate(src)
+		PageTexts(src, 0)
+		Outline(src)
+		FirstPageImage(src)
+	})
+}
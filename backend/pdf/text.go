@@ -0,0 +1,571 @@
+package pdf
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"unicode/utf16"
+)
+
+// Đọc text của PDF (phục vụ trích xuất keyword/mục lục không cần dịch vụ ngoài).
+// Hỗ trợ text trong content stream của trang và Form XObject, font có /ToUnicode hoặc
+// mã hóa 1 byte; không đọc được text trong ảnh scan.
+
+const (
+	maxOutlineItems = 2000
+	maxPageTreeSize = 100000
+)
+
+// PageTexts trả về text của tối đa maxPages trang đầu (maxPages <= 0: mọi trang).
+// Trang lỗi được bỏ qua (trả về chuỗi rỗng) thay vì làm hỏng cả file.
+func PageTexts(src []byte, maxPages int) ([]string, error) {
+	doc, err := Parse(src)
+	if err != nil {
+		return nil, err
+	}
+	root, err := doc.DerefDict(doc.trailer.Get("Root"))
+	if err != nil {
+		return nil, err
+	}
+	pages, err := doc.pages(root.Get("Pages"), maxPages)
+	if err != nil {
+		return nil, err
+	}
+
+	fonts := make(map[Ref]*cmap)
+	texts := make([]string, 0, len(pages))
+	for _, page := range pages {
+		texts = append(texts, doc.pageText(page, fonts))
+	}
+	return texts, nil
+}
+
+// OutlineItem là một mục trong bookmark (outline) của PDF
+type OutlineItem struct {
+	Title string
+	Level int // 0 là mục cấp cao nhất
+}
+
+// Outline trả về bookmark của PDF theo thứ tự đọc (duyệt theo chiều sâu)
+func Outline(src []byte) ([]OutlineItem, error) {
+	doc, err := Parse(src)
+	if err != nil {
+		return nil, err
+	}
+	root, err := doc.DerefDict(doc.trailer.Get("Root"))
+	if err != nil {
+		return nil, err
+	}
+	outlines, err := doc.DerefDict(root.Get("Outlines"))
+	if err != nil {
+		return nil, nil // Không có bookmark
+	}
+
+	var items []OutlineItem
+	visited := make(map[Ref]bool)
+	var walk func(node Object, level int)
+	walk = func(node Object, level int) {
+		for len(items) < maxOutlineItems && level < 16 {
+			r, ok := node.(Ref)
+			if !ok || visited[r] {
+				return
+			}
+			visited[r] = true
+			item, err := doc.DerefDict(r)
+			if err != nil {
+				return
+			}
+			if title, ok := item.Get("Title").(RawString); ok {
+				if t := strings.TrimSpace(textStringValue(title)); t != "" {
+					items = append(items, OutlineItem{Title: t, Level: level})
+				}
+			}
+			walk(item.Get("First"), level+1)
+			node = item.Get("Next")
+		}
+	}
+	walk(outlines.Get("First"), 0)
+	return items, nil
+}
+
+// pages liệt kê các trang theo thứ tự, tối đa limit trang (limit <= 0: không giới hạn)
+func (d *Document) pages(node Object, limit int) ([]*Dict, error) {
+	var out []*Dict
+	visited := make(map[Ref]bool)
+	var walk func(node Object, depth int) error
+	walk = func(node Object, depth int) error {
+		if depth > 64 || len(visited) > maxPageTreeSize || (limit > 0 && len(out) >= limit) {
+			return nil
+		}
+		if r, ok := node.(Ref); ok {
+			if visited[r] {
+				return nil
+			}
+			visited[r] = true
+		}
+		n, err := d.DerefDict(node)
+		if err != nil {
+			return err
+		}
+		if n.Get("Type") == Name("Page") || n.Get("Kids") == nil {
+			out = append(out, n)
+			return nil
+		}
+		kids, err := d.Deref(n.Get("Kids"))
+		if err != nil {
+			return err
+		}
+		arr, _ := kids.(Array)
+		for _, kid := range arr {
+			if err := walk(kid, depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(node, 0); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// pageText trả về text của trang
+func (d *Document) pageText(page *Dict, fonts map[Ref]*cmap) string {
+	content := d.pageContent(page)
+	if len(content) == 0 {
+		return ""
+	}
+	resources, _ := d.pageResources(page)
+	var out strings.Builder
+	d.contentText(content, resources, fonts, 0, &out)
+	return out.String()
+}
+
+// contentText chạy các toán tử text (Tf, Tj, TJ, ', ", Td, T*...) trong content stream;
+// Form XObject (Do) được đọc đệ quy với resources của chính nó
+func (d *Document) contentText(content []byte, resources *Dict, fonts map[Ref]*cmap, depth int, out *strings.Builder) {
+	var fontDict, xobjects *Dict
+	if resources != nil {
+		fontDict, _ = d.DerefDict(resources.Get("Font"))
+		xobjects, _ = d.DerefDict(resources.Get("XObject"))
+	}
+
+	var current *cmap
+	var operands []Object
+	p := &parser{buf: content}
+	for {
+		p.skipWhite()
+		if p.pos >= len(p.buf) {
+			break
+		}
+		obj, err := p.parseObject()
+		if err != nil {
+			p.pos++ // Bỏ qua byte lỗi, đọc tiếp
+			operands = operands[:0]
+			continue
+		}
+		op, ok := obj.(Keyword)
+		if !ok || isNumber(string(op)) || op == "true" || op == "false" || op == "null" {
+			operands = append(operands, obj)
+			continue
+		}
+
+		switch op {
+		case "BI":
+			skipInlineImage(p)
+		case "Do":
+			if len(operands) > 0 && xobjects != nil && depth < 4 {
+				if xname, ok := operands[len(operands)-1].(Name); ok {
+					d.formText(xobjects.Get(string(xname)), resources, fonts, depth, out)
+				}
+			}
+		case "Tf":
+			current = nil
+			if len(operands) >= 2 && fontDict != nil {
+				if fontName, ok := operands[len(operands)-2].(Name); ok {
+					current = d.fontCMap(fontDict.Get(string(fontName)), fonts)
+				}
+			}
+		case "Tj":
+			if len(operands) > 0 {
+				out.WriteString(current.decode(operands[len(operands)-1]))
+			}
+		case "'", "\"":
+			out.WriteByte('\n')
+			if len(operands) > 0 {
+				out.WriteString(current.decode(operands[len(operands)-1]))
+			}
+		case "TJ":
+			if len(operands) > 0 {
+				arr, _ := operands[len(operands)-1].(Array)
+				for _, item := range arr {
+					// Khoảng dịch lớn trong TJ thường là dấu cách giữa hai từ
+					if v, ok := FloatValue(item); ok {
+						if v < -200 {
+							out.WriteByte(' ')
+						}
+						continue
+					}
+					out.WriteString(current.decode(item))
+				}
+			}
+		case "Td", "TD":
+			if len(operands) >= 2 {
+				if ty, _ := FloatValue(operands[len(operands)-1]); ty != 0 {
+					out.WriteByte('\n')
+				} else {
+					out.WriteByte(' ')
+				}
+			}
+		case "T*", "Tm", "ET":
+			out.WriteByte('\n')
+		}
+		operands = operands[:0]
+	}
+}
+
+// formText đọc text trong Form XObject; form không có /Resources dùng resources của cha
+func (d *Document) formText(xobj Object, parent *Dict, fonts map[Ref]*cmap, depth int, out *strings.Builder) {
+	obj, err := d.Deref(xobj)
+	if err != nil {
+		return
+	}
+	s, ok := obj.(*Stream)
+	if !ok || s.Dict.Get("Subtype") != Name("Form") {
+		return
+	}
+	data, err := d.decodeStream(s)
+	if err != nil {
+		return
+	}
+	resources := parent
+	if r, err := d.DerefDict(s.Dict.Get("Resources")); err == nil {
+		resources = r
+	}
+	d.contentText(data, resources, fonts, depth+1, out)
+}
+
+// pageContent nối các content stream của trang (Contents có thể là một stream hoặc mảng)
+func (d *Document) pageContent(page *Dict) []byte {
+	contents, err := d.Deref(page.Get("Contents"))
+	if err != nil {
+		return nil
+	}
+	var streams []Object
+	switch c := contents.(type) {
+	case *Stream:
+		streams = []Object{c}
+	case Array:
+		streams = c
+	}
+
+	var buf bytes.Buffer
+	for _, item := range streams {
+		obj, err := d.Deref(item)
+		if err != nil {
+			continue
+		}
+		s, ok := obj.(*Stream)
+		if !ok {
+			continue
+		}
+		data, err := d.decodeStream(s)
+		if err != nil {
+			continue
+		}
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
+
+// skipInlineImage bỏ qua dữ liệu ảnh nhúng "BI ... ID <dữ liệu> EI"
+func skipInlineImage(p *parser) {
+	idx := bytes.Index(p.buf[p.pos:], []byte("ID"))
+	if idx < 0 {
+		p.pos = len(p.buf)
+		return
+	}
+	p.pos += idx + 2
+	for p.pos < len(p.buf) {
+		idx := bytes.Index(p.buf[p.pos:], []byte("EI"))
+		if idx < 0 {
+			p.pos = len(p.buf)
+			return
+		}
+		p.pos += idx + 2
+		before, after := p.buf[p.pos-3], byte(' ')
+		if p.pos < len(p.buf) {
+			after = p.buf[p.pos]
+		}
+		if isWhite(before) && (isWhite(after) || isDelim(after)) {
+			return
+		}
+	}
+}
+
+func isNumber(s string) bool {
+	_, ok := FloatValue(Keyword(s))
+	return ok
+}
+
+// ---- Bảng mã ToUnicode ----
+
+type cmapRange struct {
+	lo, hi uint32
+	dst    []rune   // Ký tự của lo, các mã sau tăng dần từ ký tự cuối
+	list   []string // Dạng mảng: mỗi mã một chuỗi
+}
+
+type cmap struct {
+	width  int // Số byte mỗi mã ký tự
+	chars  map[uint32]string
+	ranges []cmapRange
+}
+
+// fontCMap đọc /ToUnicode của font; font không có bảng mã trả về nil (đọc như 1 byte/ký tự)
+func (d *Document) fontCMap(fontObj Object, cache map[Ref]*cmap) *cmap {
+	r, isRef := fontObj.(Ref)
+	if isRef {
+		if cm, ok := cache[r]; ok {
+			return cm
+		}
+	}
+	var cm *cmap
+	if font, err := d.DerefDict(fontObj); err == nil {
+		if obj, err := d.Deref(font.Get("ToUnicode")); err == nil {
+			if s, ok := obj.(*Stream); ok {
+				if data, err := d.decodeStream(s); err == nil {
+					cm = parseCMap(data)
+				}
+			}
+		}
+		// Font Type0 (Identity-H...) luôn dùng mã 2 byte
+		if cm == nil && font.Get("Subtype") == Name("Type0") {
+			cm = &cmap{width: 2, chars: map[uint32]string{}}
+		}
+	}
+	if isRef {
+		cache[r] = cm
+	}
+	return cm
+}
+
+func parseCMap(data []byte) *cmap {
+	cm := &cmap{chars: make(map[uint32]string)}
+	p := &parser{buf: data}
+	var operands []Object
+	section := ""
+	for {
+		p.skipWhite()
+		if p.pos >= len(p.buf) {
+			break
+		}
+		obj, err := p.parseObject()
+		if err != nil {
+			p.pos++
+			continue
+		}
+		k, ok := obj.(Keyword)
+		if !ok || isNumber(string(k)) {
+			if section != "" {
+				operands = append(operands, obj)
+			}
+			continue
+		}
+		switch string(k) {
+		case "begincodespacerange", "beginbfchar", "beginbfrange":
+			section = string(k)
+			operands = operands[:0]
+		case "endcodespacerange":
+			if len(operands) > 0 {
+				if lo, ok := operands[0].(RawString); ok && cm.width == 0 {
+					cm.width = len(stringBytes(lo))
+				}
+			}
+			section = ""
+		case "endbfchar":
+			for i := 0; i+1 < len(operands); i += 2 {
+				src, ok1 := operands[i].(RawString)
+				dst, ok2 := operands[i+1].(RawString)
+				if ok1 && ok2 {
+					cm.chars[codeValue(stringBytes(src))] = utf16String(stringBytes(dst))
+				}
+			}
+			section = ""
+		case "endbfrange":
+			for i := 0; i+2 < len(operands); i += 3 {
+				lo, ok1 := operands[i].(RawString)
+				hi, ok2 := operands[i+1].(RawString)
+				if !ok1 || !ok2 {
+					continue
+				}
+				rg := cmapRange{lo: codeValue(stringBytes(lo)), hi: codeValue(stringBytes(hi))}
+				switch dst := operands[i+2].(type) {
+				case RawString:
+					rg.dst = []rune(utf16String(stringBytes(dst)))
+				case Array:
+					for _, item := range dst {
+						if s, ok := item.(RawString); ok {
+							rg.list = append(rg.list, utf16String(stringBytes(s)))
+						}
+					}
+				}
+				if rg.hi >= rg.lo && (len(rg.dst) > 0 || len(rg.list) > 0) {
+					cm.ranges = append(cm.ranges, rg)
+				}
+			}
+			section = ""
+		}
+	}
+	if cm.width == 0 {
+		cm.width = 1
+	}
+	return cm
+}
+
+// decode chuyển chuỗi trong toán tử text thành Unicode
+func (cm *cmap) decode(obj Object) string {
+	s, ok := obj.(RawString)
+	if !ok {
+		return ""
+	}
+	b := stringBytes(s)
+	if cm == nil {
+		return latin1(b)
+	}
+
+	var out strings.Builder
+	for i := 0; i+cm.width <= len(b); i += cm.width {
+		code := codeValue(b[i : i+cm.width])
+		if text, ok := cm.lookup(code); ok {
+			out.WriteString(text)
+		} else if cm.width == 1 {
+			out.WriteByte(b[i])
+		}
+	}
+	return out.String()
+}
+
+func (cm *cmap) lookup(code uint32) (string, bool) {
+	if text, ok := cm.chars[code]; ok {
+		return text, true
+	}
+	for _, rg := range cm.ranges {
+		if code < rg.lo || code > rg.hi {
+			continue
+		}
+		offset := int(code - rg.lo)
+		if rg.list != nil {
+			if offset < len(rg.list) {
+				return rg.list[offset], true
+			}
+			return "", false
+		}
+		runes := append([]rune(nil), rg.dst...)
+		runes[len(runes)-1] += rune(offset)
+		return string(runes), true
+	}
+	return "", false
+}
+
+// ---- Chuỗi PDF ----
+
+// stringBytes giải mã chuỗi PDF dạng (...) hoặc <...> thành byte
+func stringBytes(s RawString) []byte {
+	if len(s) < 2 {
+		return nil
+	}
+	if s[0] == '<' {
+		h := make([]byte, 0, len(s))
+		for _, c := range s[1 : len(s)-1] {
+			if !isWhite(c) {
+				h = append(h, c)
+			}
+		}
+		if len(h)%2 == 1 {
+			h = append(h, '0')
+		}
+		out, _ := hex.DecodeString(string(h))
+		return out
+	}
+
+	in := s[1 : len(s)-1]
+	out := make([]byte, 0, len(in))
+	for i := 0; i < len(in); i++ {
+		c := in[i]
+		if c != '\\' || i+1 >= len(in) {
+			out = append(out, c)
+			continue
+		}
+		i++
+		switch e := in[i]; e {
+		case 'n':
+			out = append(out, '\n')
+		case 'r':
+			out = append(out, '\r')
+		case 't':
+			out = append(out, '\t')
+		case 'b':
+			out = append(out, '\b')
+		case 'f':
+			out = append(out, '\f')
+		case '\r':
+			if i+1 < len(in) && in[i+1] == '\n' {
+				i++
+			}
+		case '\n':
+			// Xuống dòng sau "\" là nối dòng
+		default:
+			if e >= '0' && e <= '7' {
+				v := 0
+				for n := 0; n < 3 && i < len(in) && in[i] >= '0' && in[i] <= '7'; n++ {
+					v = v*8 + int(in[i]-'0')
+					i++
+				}
+				i--
+				out = append(out, byte(v))
+			} else {
+				out = append(out, e)
+			}
+		}
+	}
+	return out
+}
+
+// textStringValue giải mã "text string" (UTF-16BE có BOM, UTF-8 có BOM hoặc PDFDocEncoding)
+func textStringValue(s RawString) string {
+	b := stringBytes(s)
+	switch {
+	case len(b) >= 2 && b[0] == 0xFE && b[1] == 0xFF:
+		return utf16String(b[2:])
+	case len(b) >= 3 && b[0] == 0xEF && b[1] == 0xBB && b[2] == 0xBF:
+		return string(b[3:])
+	}
+	return latin1(b)
+}
+
+func utf16String(b []byte) string {
+	units := make([]uint16, 0, len(b)/2)
+	for i := 0; i+1 < len(b); i += 2 {
+		units = append(units, uint16(b[i])<<8|uint16(b[i+1]))
+	}
+	return string(utf16.Decode(units))
+}
+
+// latin1 đọc mỗi byte là một ký tự (gần đúng với WinAnsi/PDFDocEncoding cho chữ cái)
+func latin1(b []byte) string {
+	runes := make([]rune, len(b))
+	for i, c := range b {
+		runes[i] = rune(c)
+	}
+	return string(runes)
+}
+
+func codeValue(b []byte) uint32 {
+	var v uint32
+	for _, c := range b {
+		v = v<<8 | uint32(c)
+	}
+	return v
+}
@@ -0,0 +1,92 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// FirstPage đi theo /Kids[0] từ gốc cây trang, MediaBox có thể kế thừa từ node cha
+func (d *Document) FirstPage(node Object) (Ref, *Dict, [4]float64, error) {
+	mediaBox := [4]float64{0, 0, 612, 792}
+	for depth := 0; depth < 64; depth++ {
+		r, ok := node.(Ref)
+		if !ok {
+			return Ref{}, nil, mediaBox, fmt.Errorf("%w: page node is not indirect", ErrMalformedPDF)
+		}
+		n, err := d.DerefDict(r)
+		if err != nil {
+			return Ref{}, nil, mediaBox, err
+		}
+
+		if mb, err := d.Deref(n.Get("MediaBox")); err == nil {
+			if arr, ok := mb.(Array); ok && len(arr) == 4 {
+				for i := range arr {
+					if v, ok := FloatValue(arr[i]); ok {
+						mediaBox[i] = v
+					}
+				}
+			}
+		}
+
+		if n.Get("Type") == Name("Page") || n.Get("Kids") == nil {
+			return r, n, mediaBox, nil
+		}
+		kids, err := d.Deref(n.Get("Kids"))
+		if err != nil {
+			return Ref{}, nil, mediaBox, err
+		}
+		arr, ok := kids.(Array)
+		if !ok || len(arr) == 0 {
+			return Ref{}, nil, mediaBox, fmt.Errorf("%w: empty page tree", ErrMalformedPDF)
+		}
+		node = arr[0]
+	}
+	return Ref{}, nil, mediaBox, fmt.Errorf("%w: page tree too deep", ErrMalformedPDF)
+}
+
+// AppendUpdate nối các object mới/sửa, bảng xref và trailer mới (/Size là size, /Info là infoRef)
+// vào cuối file gốc dưới dạng incremental update
+func (doc *Document) AppendUpdate(updates map[Ref]Object, size int, infoRef Ref) []byte {
+	var out bytes.Buffer
+	out.Grow(len(doc.buf) + 4096)
+	out.Write(doc.buf)
+	if len(doc.buf) > 0 && doc.buf[len(doc.buf)-1] != '\n' {
+		out.WriteByte('\n')
+	}
+
+	nums := make([]Ref, 0, len(updates))
+	for r := range updates {
+		nums = append(nums, r)
+	}
+	sort.Slice(nums, func(i, j int) bool { return nums[i].Num < nums[j].Num })
+
+	offsets := make(map[int]int, len(nums))
+	for _, r := range nums {
+		offsets[r.Num] = out.Len()
+		fmt.Fprintf(&out, "%d %d obj\n", r.Num, r.Gen)
+		writeObject(&out, updates[r])
+		out.WriteString("\nendobj\n")
+	}
+
+	xrefOffset := out.Len()
+	out.WriteString("xref\n")
+	for _, r := range nums {
+		fmt.Fprintf(&out, "%d 1\n%010d %05d n \n", r.Num, offsets[r.Num], r.Gen)
+	}
+
+	trailer := NewDict()
+	trailer.Set("Size", Keyword(strconv.Itoa(size)))
+	trailer.Set("Root", doc.trailer.Get("Root"))
+	trailer.Set("Info", infoRef)
+	if id := doc.trailer.Get("ID"); id != nil {
+		trailer.Set("ID", id)
+	}
+	trailer.Set("Prev", Keyword(strconv.Itoa(doc.startx)))
+
+	out.WriteString("trailer\n")
+	writeObject(&out, trailer)
+	fmt.Fprintf(&out, "\nstartxref\n%d\n%%%%EOF\n", xrefOffset)
+	return out.Bytes()
+}
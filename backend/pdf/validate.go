@@ -0,0 +1,159 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+)
+
+// Đoạn file nạp ban đầu cho mỗi section xref/object stream; thiếu thì nạp lớn dần tới maxSectionSize
+const (
+	initialSectionSize = 64 << 10
+	maxSectionSize     = 32 << 20
+)
+
+// ValidateXref đọc mọi section xref (theo /XRefStm, /Prev) và kiểm tra mỗi object được khai báo
+// nằm trong file (kể cả object trong object stream); dùng khi nhận file upload, không đọc nội dung trang.
+// Chỉ các section xref và object stream được nạp vào bộ nhớ, không đọc toàn bộ file.
+func ValidateXref(r io.ReaderAt, size int64) error {
+	if size <= 0 || size > int64(^uint(0)>>1) {
+		return fmt.Errorf("%w: bad file size", ErrMalformedPDF)
+	}
+	fileSize := int(size)
+
+	tailSize := 1024
+	if fileSize < tailSize {
+		tailSize = fileSize
+	}
+	tail, err := readSection(r, fileSize-tailSize, tailSize)
+	if err != nil {
+		return err
+	}
+	idx := bytes.LastIndex(tail, []byte("startxref"))
+	if idx < 0 {
+		return fmt.Errorf("%w: startxref not found", ErrMalformedPDF)
+	}
+	p := &parser{buf: tail, pos: idx + len("startxref")}
+	p.skipWhite()
+	startx, err := strconv.Atoi(p.regular())
+	if err != nil || startx <= 0 || startx >= fileSize {
+		return fmt.Errorf("%w: bad startxref", ErrMalformedPDF)
+	}
+
+	// Đọc các section theo thứ tự như readXref: section mới trước, /XRefStm trước /Prev
+	xref := make(map[int]xrefEntry)
+	visited := make(map[int]bool)
+	pending := []int{startx}
+	for len(pending) > 0 {
+		offset := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if offset <= 0 || offset >= fileSize || visited[offset] {
+			continue
+		}
+		visited[offset] = true
+
+		var trailer *Dict
+		var section map[int]xrefEntry
+		err := withSection(r, fileSize, offset, func(d *Document) error {
+			var err error
+			trailer, err = d.readXrefSection(offset)
+			section = d.xref
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		for num, entry := range section {
+			if _, exists := xref[num]; exists {
+				continue
+			}
+			if len(xref) >= maxObjects {
+				return fmt.Errorf("%w: more than %d objects", ErrMalformedPDF, maxObjects)
+			}
+			xref[num] = entry
+		}
+		older := olderXrefSections(trailer)
+		for i := len(older) - 1; i >= 0; i-- {
+			pending = append(pending, older[i])
+		}
+	}
+
+	// Object nằm trong object stream: gom theo stream để mỗi stream chỉ giải nén một lần
+	inStream := make(map[int][]int)
+	for num, entry := range xref {
+		if entry.inStm {
+			inStream[entry.stream] = append(inStream[entry.stream], num)
+		} else if entry.offset >= fileSize {
+			return fmt.Errorf("%w: object %d offset %d is past end of file", ErrMalformedPDF, num, entry.offset)
+		}
+	}
+	streams := make([]int, 0, len(inStream))
+	for num := range inStream {
+		streams = append(streams, num)
+	}
+	sort.Ints(streams)
+
+	for _, stmNum := range streams {
+		nums := inStream[stmNum]
+		stm, ok := xref[stmNum]
+		if !ok || stm.inStm || stm.offset < 0 || stm.offset >= fileSize {
+			return fmt.Errorf("%w: object %d is in missing object stream %d", ErrMalformedPDF, nums[0], stmNum)
+		}
+		// Vị trí của object trong object stream chỉ biết được sau khi giải nén
+		err := withSection(r, fileSize, stm.offset, func(d *Document) error {
+			d.xref[stmNum] = stm
+			data, err := d.objectStream(stmNum)
+			if err != nil {
+				return err
+			}
+			for _, num := range nums {
+				if _, err := parseFromObjectStream(data, num, xref[num].index); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// withSection nạp đoạn file bắt đầu tại offset rồi gọi fn với Document chỉ chứa đoạn đó.
+// fn lỗi khi đoạn chưa đủ dài thì nạp lại đoạn lớn gấp bốn, tới cuối file hoặc maxSectionSize.
+func withSection(r io.ReaderAt, size, offset int, fn func(d *Document) error) error {
+	n := initialSectionSize
+	for {
+		if n > size-offset {
+			n = size - offset
+		}
+		buf, err := readSection(r, offset, n)
+		if err != nil {
+			return err
+		}
+		d := &Document{buf: buf, base: offset, size: size, xref: make(map[int]xrefEntry), objStms: make(map[int][]byte)}
+		err = fn(d)
+		if err == nil || offset+n >= size {
+			return err
+		}
+		if n >= maxSectionSize {
+			return fmt.Errorf("%w: xref section at %d larger than %d bytes: %v", ErrMalformedPDF, offset, maxSectionSize, err)
+		}
+		n *= 4
+	}
+}
+
+func readSection(r io.ReaderAt, offset, n int) ([]byte, error) {
+	buf := make([]byte, n)
+	read, err := r.ReadAt(buf, int64(offset))
+	if read < n {
+		if err == nil || err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return buf, nil
+}
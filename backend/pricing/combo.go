@@ -0,0 +1,41 @@
+package pricing
+
+import "github.com/Poloni84Learning/ebook-store/models"
+
+// BundleLines tách một combo thành các dòng theo từng sách. Phần giảm giá trọn gói
+// được phân bổ theo tỷ lệ giá lẻ; dòng cuối nhận phần dư do làm tròn.
+// books phải chứa giá hiện tại của mọi sách trong combo.
+func BundleLines(combo *models.BookCombo, books map[uint]models.Book, quantity int) []Line {
+	comboID := combo.ID
+
+	var listPrice float64
+	for _, item := range combo.ComboItems {
+		listPrice += books[item.BookID].Price
+	}
+	listTotal := Round(listPrice * float64(quantity))
+	discount := Round(listTotal - Round(combo.BundleTotal(listPrice)*float64(quantity)))
+
+	lines := make([]Line, 0, len(combo.ComboItems))
+	remaining := discount
+	for i, item := range combo.ComboItems {
+		book := books[item.BookID]
+		share := remaining
+		if i < len(combo.ComboItems)-1 {
+			share = 0
+			if listTotal > 0 {
+				share = Round(discount * book.Price * float64(quantity) / listTotal)
+			}
+			remaining = Round(remaining - share)
+		}
+
+		lines = append(lines, Line{
+			BookID:         item.BookID,
+			Quantity:       quantity,
+			UnitPrice:      book.Price,
+			Category:       book.Category,
+			ComboID:        &comboID,
+			BundleDiscount: share,
+		})
+	}
+	return lines
+}
@@ -0,0 +1,109 @@
+package pricing
+
+import (
+	"math"
+
+	"github.com/Poloni84Learning/ebook-store/models"
+	"gorm.io/gorm"
+)
+
+// CouponRule là coupon đã nạp sẵn phạm vi áp dụng để xét từng dòng
+type CouponRule struct {
+	Coupon     *models.Coupon
+	categories map[models.BookCategory]bool
+	books      map[uint]bool
+	comboBooks map[uint]bool
+	combos     map[uint]bool
+}
+
+// NewCouponRule nạp danh sách sách thuộc các combo được áp dụng
+func NewCouponRule(db *gorm.DB, coupon *models.Coupon) (*CouponRule, error) {
+	rule := &CouponRule{
+		Coupon:     coupon,
+		categories: make(map[models.BookCategory]bool),
+		books:      make(map[uint]bool),
+		comboBooks: make(map[uint]bool),
+		combos:     make(map[uint]bool),
+	}
+
+	for _, category := range coupon.Categories {
+		rule.categories[models.BookCategory(category)] = true
+	}
+	for _, bookID := range coupon.BookIDs {
+		rule.books[uint(bookID)] = true
+	}
+
+	for _, comboID := range coupon.ComboIDs {
+		rule.combos[uint(comboID)] = true
+	}
+
+	if len(coupon.ComboIDs) > 0 {
+		var bookIDs []uint
+		if err := db.Model(&models.ComboItem{}).
+			Where("combo_id IN ? AND is_hidden = ?", []int64(coupon.ComboIDs), false).
+			Pluck("book_id", &bookIDs).Error; err != nil {
+			return nil, err
+		}
+		for _, bookID := range bookIDs {
+			rule.comboBooks[bookID] = true
+		}
+	}
+
+	return rule, nil
+}
+
+// Applies kiểm tra coupon có áp dụng cho dòng này không
+func (r *CouponRule) Applies(line Line) bool {
+	if !r.Coupon.HasRestrictions() {
+		return true
+	}
+	if line.ComboID != nil && r.combos[*line.ComboID] {
+		return true
+	}
+	return r.categories[line.Category] || r.books[line.BookID] || r.comboBooks[line.BookID]
+}
+
+// apply tính giảm giá coupon trên phần còn lại sau khuyến mãi và phân bổ vào các dòng
+func (r *CouponRule) apply(quote *Quote, lines []Line) error {
+	var base float64
+	var eligible []int
+	for i, line := range lines {
+		if r.Applies(line) {
+			eligible = append(eligible, i)
+			base += quote.Lines[i].Total
+		}
+	}
+
+	if len(eligible) == 0 || base <= 0 {
+		return models.ErrCouponNotApplicable
+	}
+	if quote.Subtotal-quote.Discount < r.Coupon.MinOrderAmount {
+		return models.ErrCouponMinOrder
+	}
+
+	var discount float64
+	switch r.Coupon.Type {
+	case models.CouponTypePercent:
+		discount = base * math.Min(r.Coupon.Value, 100) / 100
+	default:
+		discount = math.Min(r.Coupon.Value, base)
+	}
+	discount = Round(discount)
+
+	// Phân bổ theo tỷ lệ giá trị dòng, dòng cuối nhận phần dư do làm tròn
+	remaining := discount
+	for n, i := range eligible {
+		share := remaining
+		if n < len(eligible)-1 {
+			share = Round(discount * quote.Lines[i].Total / base)
+			remaining = Round(remaining - share)
+		}
+		quote.Lines[i].Discount = Round(quote.Lines[i].Discount + share)
+		quote.Lines[i].Total = Round(quote.Lines[i].Total - share)
+	}
+
+	quote.CouponCode = r.Coupon.Code
+	quote.CouponDiscount = discount
+	quote.Discount = Round(quote.Discount + discount)
+	return nil
+}
@@ -0,0 +1,160 @@
+package pricing
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Poloni84Learning/ebook-store/models"
+	"github.com/lib/pq"
+)
+
+func TestCouponQuote(t *testing.T) {
+	threeBooks := []Line{
+		{BookID: 1, Quantity: 1, UnitPrice: 10, Category: models.CategoryScience},
+		{BookID: 2, Quantity: 1, UnitPrice: 20, Category: models.CategoryFiction},
+		{BookID: 3, Quantity: 1, UnitPrice: 30, Category: models.CategoryFiction},
+	}
+	sameBooks := []Line{
+		{BookID: 1, Quantity: 1, UnitPrice: 10},
+		{BookID: 2, Quantity: 1, UnitPrice: 10},
+		{BookID: 3, Quantity: 1, UnitPrice: 10},
+	}
+	withCombo := []Line{
+		{BookID: 1, Quantity: 1, UnitPrice: 10, ComboID: comboID(7), BundleDiscount: 1},
+		{BookID: 2, Quantity: 1, UnitPrice: 20, ComboID: comboID(7), BundleDiscount: 2},
+		{BookID: 3, Quantity: 1, UnitPrice: 10},
+	}
+
+	tests := []struct {
+		name      string
+		promotion float64
+		rule      *CouponRule
+		lines     []Line
+		shares    []float64 // Phần coupon phân bổ vào từng dòng
+		err       error
+	}{
+		{
+			name:   "phần trăm phân bổ theo giá trị dòng",
+			rule:   rule(&models.Coupon{Type: models.CouponTypePercent, Value: 10}),
+			lines:  threeBooks,
+			shares: []float64{1, 2, 3},
+		},
+		{
+			name:   "số tiền cố định, dòng cuối nhận phần dư",
+			rule:   rule(&models.Coupon{Type: models.CouponTypeFixed, Value: 10}),
+			lines:  sameBooks,
+			shares: []float64{3.33, 3.33, 3.34},
+		},
+		{
+			name:   "số tiền cố định không vượt giá trị đơn",
+			rule:   rule(&models.Coupon{Type: models.CouponTypeFixed, Value: 100}),
+			lines:  sameBooks,
+			shares: []float64{10, 10, 10},
+		},
+		{
+			name:   "phần trăm trên 100 bị chặn",
+			rule:   rule(&models.Coupon{Type: models.CouponTypePercent, Value: 150}),
+			lines:  sameBooks,
+			shares: []float64{10, 10, 10},
+		},
+		{
+			name:      "tính trên giá đã trừ khuyến mãi",
+			promotion: 10,
+			rule:      rule(&models.Coupon{Type: models.CouponTypePercent, Value: 10}),
+			lines:     threeBooks[:2],
+			shares:    []float64{0.9, 1.8},
+		},
+		{
+			name:   "chỉ áp dụng cho thể loại được chọn",
+			rule:   rule(&models.Coupon{Type: models.CouponTypeFixed, Value: 25, Categories: pq.StringArray{string(models.CategoryFiction)}}),
+			lines:  threeBooks,
+			shares: []float64{0, 10, 15},
+		},
+		{
+			name:   "chỉ áp dụng cho sách được chọn",
+			rule:   rule(&models.Coupon{Type: models.CouponTypePercent, Value: 50, BookIDs: pq.Int64Array{3}}),
+			lines:  threeBooks,
+			shares: []float64{0, 0, 15},
+		},
+		{
+			name:   "áp dụng cho dòng thuộc combo được chọn",
+			rule:   &CouponRule{Coupon: &models.Coupon{Type: models.CouponTypeFixed, Value: 9, ComboIDs: pq.Int64Array{7}}, combos: map[uint]bool{7: true}},
+			lines:  withCombo,
+			shares: []float64{3, 6, 0},
+		},
+		{
+			name:   "áp dụng cho sách thuộc combo khi mua lẻ",
+			rule:   &CouponRule{Coupon: &models.Coupon{Type: models.CouponTypeFixed, Value: 5, ComboIDs: pq.Int64Array{9}}, comboBooks: map[uint]bool{3: true}},
+			lines:  withCombo,
+			shares: []float64{0, 0, 5},
+		},
+		{
+			name:  "không có dòng phù hợp",
+			rule:  rule(&models.Coupon{Type: models.CouponTypePercent, Value: 10, Categories: pq.StringArray{string(models.CategoryMath)}}),
+			lines: threeBooks,
+			err:   models.ErrCouponNotApplicable,
+		},
+		{
+			name:  "dòng phù hợp đã giảm hết",
+			rule:  rule(&models.Coupon{Type: models.CouponTypePercent, Value: 10}),
+			lines: []Line{{BookID: 1, Quantity: 1, UnitPrice: 10, ComboID: comboID(7), BundleDiscount: 10}},
+			err:   models.ErrCouponNotApplicable,
+		},
+		{
+			name:      "giá trị tối thiểu xét sau khuyến mãi",
+			promotion: 10,
+			rule:      rule(&models.Coupon{Type: models.CouponTypePercent, Value: 10, MinOrderAmount: 30}),
+			lines:     threeBooks[:2],
+			err:       models.ErrCouponMinOrder,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.rule.Coupon.Code = "SALE"
+			plain, err := (&Engine{Promotion: tt.promotion, ShippingFee: 2}).Quote(tt.lines)
+			if err != nil {
+				t.Fatal(err)
+			}
+			quote, err := (&Engine{Promotion: tt.promotion, ShippingFee: 2}).WithCoupon(tt.rule).Quote(tt.lines)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("error = %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var couponDiscount float64
+			for i, want := range tt.shares {
+				if got := Round(quote.Lines[i].Discount - plain.Lines[i].Discount); got != want {
+					t.Errorf("line %d coupon share = %v, want %v", i, got, want)
+				}
+				couponDiscount += want
+			}
+			if quote.CouponDiscount != Round(couponDiscount) || quote.CouponCode != "SALE" {
+				t.Errorf("coupon = %q %v, want SALE %v", quote.CouponCode, quote.CouponDiscount, Round(couponDiscount))
+			}
+			if want := Round(plain.Total - couponDiscount); quote.Total != want {
+				t.Errorf("total = %v, want %v", quote.Total, want)
+			}
+			checkTotals(t, quote)
+
+			var order models.Order
+			quote.ApplyTo(&order)
+			if order.CouponCode != "SALE" || order.TotalAmount != quote.Total || order.DiscountAmount != quote.Discount {
+				t.Errorf("order = %+v", order)
+			}
+		})
+	}
+}
+
+// rule dựng CouponRule cho coupon không giới hạn theo combo, không cần truy vấn DB
+func rule(coupon *models.Coupon) *CouponRule {
+	r, err := NewCouponRule(nil, coupon)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
@@ -0,0 +1,162 @@
+package pricing
+
+import (
+	"errors"
+	"math"
+
+	"github.com/Poloni84Learning/ebook-store/models"
+	"gorm.io/gorm"
+)
+
+// Line là một dòng cần tính giá
+type Line struct {
+	BookID    uint                `json:"book_id"`
+	Quantity  int                 `json:"quantity"`
+	UnitPrice float64             `json:"unit_price"`
+	Category  models.BookCategory `json:"category,omitempty"`
+
+	// Dòng thuộc combo: giảm giá trọn gói thay cho khuyến mãi chung
+	ComboID        *uint   `json:"combo_id,omitempty"`
+	BundleDiscount float64 `json:"bundle_discount,omitempty"`
+}
+
+// LineQuote là kết quả tính giá cho một dòng. Discount là tổng giảm giá của cả dòng.
+type LineQuote struct {
+	BookID    uint    `json:"book_id"`
+	Quantity  int     `json:"quantity"`
+	UnitPrice float64 `json:"unit_price"`
+	ComboID   *uint   `json:"combo_id,omitempty"`
+	Subtotal  float64 `json:"subtotal"`
+	Discount  float64 `json:"discount"`
+	Total     float64 `json:"total"`
+}
+
+// Quote là bảng giá chi tiết của cả đơn hàng
+type Quote struct {
+	Lines         []LineQuote `json:"lines"`
+	Subtotal      float64     `json:"subtotal"`
+	Discount      float64     `json:"discount"`
+	ShippingFee   float64     `json:"shipping_fee"`
+	Total         float64     `json:"total"`
+	Promotion     float64     `json:"promotion_percent"`
+	PromotionInfo string      `json:"promotion_info,omitempty"`
+
+	CouponCode     string  `json:"coupon_code,omitempty"`
+	CouponDiscount float64 `json:"coupon_discount"`
+	coupon         *models.Coupon
+}
+
+// Engine áp dụng khuyến mãi và phí ship từ SystemConfig
+type Engine struct {
+	ShippingFee   float64
+	Promotion     float64 // Phần trăm giảm giá áp dụng cho từng dòng
+	PromotionInfo string
+
+	coupon *CouponRule
+}
+
+// LoadEngine đọc cấu hình hiện hành; chưa có SystemConfig thì không giảm giá, không phí ship
+func LoadEngine(db *gorm.DB) (*Engine, error) {
+	var cfg models.SystemConfig
+	if err := db.Order("id DESC").First(&cfg).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &Engine{}, nil
+		}
+		return nil, err
+	}
+
+	return &Engine{
+		ShippingFee:   cfg.ShippingFee,
+		Promotion:     clampPercent(cfg.Promotion),
+		PromotionInfo: cfg.PromotionInfo,
+	}, nil
+}
+
+// WithCoupon áp dụng thêm coupon cho các lần tính giá sau
+func (e *Engine) WithCoupon(rule *CouponRule) *Engine {
+	e.coupon = rule
+	return e
+}
+
+// Quote tính giá cho danh sách dòng: khuyến mãi (hoặc giá combo) từng dòng, coupon, rồi phí ship
+func (e *Engine) Quote(lines []Line) (*Quote, error) {
+	quote := &Quote{
+		Promotion:     e.Promotion,
+		PromotionInfo: e.PromotionInfo,
+	}
+
+	for _, line := range lines {
+		subtotal := Round(line.UnitPrice * float64(line.Quantity))
+		discount := Round(subtotal * e.Promotion / 100)
+		if line.ComboID != nil {
+			discount = Round(math.Min(line.BundleDiscount, subtotal))
+		}
+
+		quote.Lines = append(quote.Lines, LineQuote{
+			BookID:    line.BookID,
+			Quantity:  line.Quantity,
+			UnitPrice: line.UnitPrice,
+			ComboID:   line.ComboID,
+			Subtotal:  subtotal,
+			Discount:  discount,
+			Total:     Round(subtotal - discount),
+		})
+		quote.Subtotal += subtotal
+		quote.Discount += discount
+	}
+
+	quote.Subtotal = Round(quote.Subtotal)
+	quote.Discount = Round(quote.Discount)
+
+	if e.coupon != nil {
+		if err := e.coupon.apply(quote, lines); err != nil {
+			return nil, err
+		}
+		quote.coupon = e.coupon.Coupon
+	}
+
+	if len(lines) > 0 {
+		quote.ShippingFee = Round(e.ShippingFee)
+	}
+	quote.Total = Round(math.Max(0, quote.Subtotal-quote.Discount) + quote.ShippingFee)
+	return quote, nil
+}
+
+// OrderItems chuyển các dòng đã tính giá thành OrderItem
+func (q *Quote) OrderItems() []models.OrderItem {
+	items := make([]models.OrderItem, 0, len(q.Lines))
+	for _, line := range q.Lines {
+		items = append(items, models.OrderItem{
+			BookID:   line.BookID,
+			Quantity: line.Quantity,
+			Price:    line.UnitPrice,
+			Discount: line.Discount,
+			ComboID:  line.ComboID,
+		})
+	}
+	return items
+}
+
+// ApplyTo ghi bảng giá vào đơn hàng
+func (q *Quote) ApplyTo(order *models.Order) {
+	order.Subtotal = q.Subtotal
+	order.DiscountAmount = q.Discount
+	order.ShippingFee = q.ShippingFee
+	order.TotalAmount = q.Total
+	if q.coupon != nil {
+		order.CouponID = &q.coupon.ID
+		order.CouponCode = q.coupon.Code
+	} else {
+		order.CouponID = nil
+		order.CouponCode = ""
+	}
+}
+
+// Round làm tròn tiền về 2 chữ số thập phân
+func Round(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+func clampPercent(p float64) float64 {
+	return math.Max(0, math.Min(100, p))
+}
@@ -0,0 +1,194 @@
+package pricing
+
+import (
+	"testing"
+
+	"github.com/Poloni84Learning/ebook-store/models"
+)
+
+func comboID(id uint) *uint {
+	return &id
+}
+
+// checkTotals kiểm tra các dòng cộng lại đúng bằng tổng đơn; webhook so số tiền với Total
+func checkTotals(t *testing.T, quote *Quote) {
+	t.Helper()
+	var subtotal, discount, total float64
+	for _, line := range quote.Lines {
+		subtotal += line.Subtotal
+		discount += line.Discount
+		total += line.Total
+		if got := Round(line.Subtotal - line.Discount); got != line.Total {
+			t.Errorf("line %d: subtotal %v - discount %v = %v, total %v", line.BookID, line.Subtotal, line.Discount, got, line.Total)
+		}
+	}
+	if Round(subtotal) != quote.Subtotal || Round(discount) != quote.Discount {
+		t.Errorf("lines sum to subtotal %v discount %v, quote has %v %v", Round(subtotal), Round(discount), quote.Subtotal, quote.Discount)
+	}
+	if got := Round(total + quote.ShippingFee); got != quote.Total {
+		t.Errorf("line totals + shipping = %v, quote total %v", got, quote.Total)
+	}
+}
+
+func TestQuote(t *testing.T) {
+	tests := []struct {
+		name      string
+		engine    Engine
+		lines     []Line
+		discounts []float64 // Giảm giá từng dòng
+		shipping  float64
+		total     float64
+	}{
+		{
+			name:      "khuyến mãi làm tròn từng dòng",
+			engine:    Engine{Promotion: 10, ShippingFee: 2.5},
+			lines:     []Line{{BookID: 1, Quantity: 3, UnitPrice: 9.99}, {BookID: 2, Quantity: 1, UnitPrice: 5}},
+			discounts: []float64{3, 0.5},
+			shipping:  2.5,
+			total:     33.97,
+		},
+		{
+			name:   "giảm giá combo thay cho khuyến mãi",
+			engine: Engine{Promotion: 10},
+			lines: []Line{
+				{BookID: 1, Quantity: 1, UnitPrice: 10, ComboID: comboID(7), BundleDiscount: 2},
+				{BookID: 2, Quantity: 1, UnitPrice: 10},
+			},
+			discounts: []float64{2, 1},
+			total:     17,
+		},
+		{
+			name:      "combo không giảm thì không nhận khuyến mãi chung",
+			engine:    Engine{Promotion: 50},
+			lines:     []Line{{BookID: 1, Quantity: 2, UnitPrice: 10, ComboID: comboID(7)}},
+			discounts: []float64{0},
+			total:     20,
+		},
+		{
+			name:      "giảm giá combo không vượt giá dòng",
+			engine:    Engine{ShippingFee: 3},
+			lines:     []Line{{BookID: 1, Quantity: 1, UnitPrice: 10, ComboID: comboID(7), BundleDiscount: 15}},
+			discounts: []float64{10},
+			shipping:  3,
+			total:     3,
+		},
+		{
+			name:      "khuyến mãi 100% chỉ còn phí ship",
+			engine:    Engine{Promotion: 100, ShippingFee: 4.99},
+			lines:     []Line{{BookID: 1, Quantity: 1, UnitPrice: 12.5}},
+			discounts: []float64{12.5},
+			shipping:  4.99,
+			total:     4.99,
+		},
+		{
+			name:   "giỏ rỗng không tính phí ship",
+			engine: Engine{Promotion: 10, ShippingFee: 4.99},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			quote, err := tt.engine.Quote(tt.lines)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(quote.Lines) != len(tt.discounts) {
+				t.Fatalf("got %d lines, want %d", len(quote.Lines), len(tt.discounts))
+			}
+			for i, want := range tt.discounts {
+				if got := quote.Lines[i].Discount; got != want {
+					t.Errorf("line %d discount = %v, want %v", i, got, want)
+				}
+			}
+			if quote.ShippingFee != tt.shipping {
+				t.Errorf("shipping = %v, want %v", quote.ShippingFee, tt.shipping)
+			}
+			if quote.Total != tt.total {
+				t.Errorf("total = %v, want %v", quote.Total, tt.total)
+			}
+			checkTotals(t, quote)
+		})
+	}
+}
+
+func TestBundleLines(t *testing.T) {
+	bundlePrice := func(p float64) *float64 { return &p }
+	books := map[uint]models.Book{
+		1: {Price: 10, Category: models.CategoryFiction},
+		2: {Price: 20, Category: models.CategoryScience},
+		3: {Price: 30, Category: models.CategoryMath},
+		4: {Price: 10},
+		5: {Price: 10},
+		6: {Price: 10},
+	}
+	items := func(ids ...uint) []models.ComboItem {
+		var items []models.ComboItem
+		for _, id := range ids {
+			items = append(items, models.ComboItem{BookID: id})
+		}
+		return items
+	}
+
+	tests := []struct {
+		name      string
+		combo     models.BookCombo
+		quantity  int
+		discounts []float64 // Phần giảm giá trọn gói từng dòng
+	}{
+		{
+			name:      "phân bổ theo tỷ lệ giá lẻ",
+			combo:     models.BookCombo{DiscountPercent: 10, ComboItems: items(1, 2, 3)},
+			quantity:  1,
+			discounts: []float64{1, 2, 3},
+		},
+		{
+			name:      "dòng cuối nhận phần dư làm tròn",
+			combo:     models.BookCombo{BundlePrice: bundlePrice(20), ComboItems: items(4, 5, 6)},
+			quantity:  1,
+			discounts: []float64{3.33, 3.33, 3.34},
+		},
+		{
+			name:      "phần dư âm khi mua nhiều combo",
+			combo:     models.BookCombo{BundlePrice: bundlePrice(20), ComboItems: items(4, 5, 6)},
+			quantity:  2,
+			discounts: []float64{6.67, 6.67, 6.66},
+		},
+		{
+			name:      "giá trọn gói cao hơn giá lẻ thì không giảm",
+			combo:     models.BookCombo{BundlePrice: bundlePrice(100), ComboItems: items(1, 2)},
+			quantity:  1,
+			discounts: []float64{0, 0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.combo.ID = 7
+			lines := BundleLines(&tt.combo, books, tt.quantity)
+			if len(lines) != len(tt.discounts) {
+				t.Fatalf("got %d lines, want %d", len(lines), len(tt.discounts))
+			}
+			var listPrice float64
+			for i, line := range lines {
+				book := books[tt.combo.ComboItems[i].BookID]
+				listPrice += book.Price
+				if line.BundleDiscount != tt.discounts[i] {
+					t.Errorf("line %d bundle discount = %v, want %v", i, line.BundleDiscount, tt.discounts[i])
+				}
+				if line.ComboID == nil || *line.ComboID != 7 || line.Quantity != tt.quantity ||
+					line.UnitPrice != book.Price || line.Category != book.Category {
+					t.Errorf("line %d = %+v", i, line)
+				}
+			}
+
+			// Khuyến mãi chung không áp dụng cho combo: tổng đơn đúng bằng giá trọn gói
+			quote, err := (&Engine{Promotion: 20}).Quote(lines)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := Round(Round(tt.combo.BundleTotal(listPrice)) * float64(tt.quantity))
+			if quote.Total != want {
+				t.Errorf("quote total = %v, want bundle total %v", quote.Total, want)
+			}
+			checkTotals(t, quote)
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package recommendation
+
+import (
+	"log"
+	"time"
+
+	"github.com/Poloni84Learning/ebook-store/config"
+	"github.com/Poloni84Learning/ebook-store/models"
+	"gorm.io/gorm"
+)
+
+// Chu kỳ ngắn nhất giữa hai lượt tính; cấu hình 0 hoặc âm (hay quá nhỏ) được nâng lên mức này
+// để worker không chạy liên tục một câu truy vấn nặng
+const minInterval = time.Minute
+
+// Worker tính lại bảng book_similarities định kỳ cho API gợi ý sách
+type Worker struct {
+	DB       *gorm.DB
+	Interval time.Duration
+}
+
+// StartWorker khởi động worker chạy ngầm; lượt tính đầu tiên chạy ngay khi khởi động
+func StartWorker(db *gorm.DB, cfg *config.Config) {
+	w := &Worker{DB: db, Interval: cfg.RecommendationRefreshInterval}
+	go w.run()
+}
+
+func (w *Worker) run() {
+	interval := w.Interval
+	if interval < minInterval {
+		log.Printf("[Recommendation] Chu kỳ %v quá ngắn, dùng %v", interval, minInterval)
+		interval = minInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		w.RunOnce()
+		<-ticker.C
+	}
+}
+
+// RunOnce tính lại độ tương đồng giữa các sách; bỏ qua nếu instance khác đang tính.
+// Panic được ghi log thay vì làm dừng worker.
+func (w *Worker) RunOnce() {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("[Recommendation] Panic khi tính độ tương đồng sách: %v", r)
+		}
+	}()
+
+	started := time.Now()
+	ran, err := models.RecomputeBookSimilarities(w.DB)
+	switch {
+	case err != nil:
+		log.Printf("[Recommendation] Lỗi tính độ tương đồng sách: %v", err)
+	case ran:
+		log.Printf("[Recommendation] Đã tính lại độ tương đồng sách trong %v", time.Since(started).Round(time.Millisecond))
+	}
+}
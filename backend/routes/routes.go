@@ -1,6 +1,8 @@
 package routes
 
 import (
+	"path/filepath"
+
 	"github.com/Poloni84Learning/ebook-store/config"
 	"github.com/Poloni84Learning/ebook-store/controllers"
 	"github.com/Poloni84Learning/ebook-store/middlewares"
@@ -16,7 +18,8 @@ func SetupRouter(db *gorm.DB, cfg *config.Config) *gin.Engine {
 	router.Use(middlewares.LoggerMiddleware())
 	router.Static("/uploads/covers", "/app/public/uploads/covers")
 
-	router.Static("/storage", "/app/storage")
+	// Chỉ ảnh bìa (images/) được phục vụ công khai; file sách chỉ tải qua DownloadFile hoặc signed URL /api/files
+	router.Static("/storage/images", filepath.Join(cfg.UploadRoot, "images"))
 
 	// Khởi tạo controllers
 	authController := controllers.NewAuthController(db, cfg)
@@ -24,8 +27,17 @@ func SetupRouter(db *gorm.DB, cfg *config.Config) *gin.Engine {
 	orderController := controllers.NewOrderController(db, cfg)
 	comboController := controllers.NewComboController(db, cfg)
 	reviewController := controllers.NewReviewController(db, cfg)
+	cartController := controllers.NewCartController(db, cfg)
+	paymentController := controllers.NewPaymentController(db, cfg)
+	couponController := controllers.NewCouponController(db, cfg)
+	libraryController := controllers.NewLibraryController(db, cfg)
+	searchController := controllers.NewSearchController(db, cfg)
+	recommendationController := controllers.NewRecommendationController(db, cfg)
 	systemConfigController := controllers.SystemConfigController{DB: db}
 
+	// Route public có ghi log tìm kiếm: đọc token nếu có để log theo người dùng đã đăng nhập
+	optionalAuth := middlewares.OptionalJWTAuthMiddleware(cfg)
+
 	// Public routes (không yêu cầu auth)
 	public := router.Group("/api")
 	{
@@ -35,20 +47,28 @@ func SetupRouter(db *gorm.DB, cfg *config.Config) *gin.Engine {
 		public.POST("/auth/register", authController.Register)
 		public.POST("/auth/login", authController.Login)
 		public.POST("/auth/staff-login", authController.StaffLogin) // New endpoint for staff/admin login
-		public.GET("/books", bookController.GetBooks)
+		public.GET("/books", optionalAuth, bookController.GetBooks)
 		public.GET("/books/:id", bookController.GetBook)
-		public.GET("/books/by-title", bookController.GetBooksByTitle)
-		public.GET("/books/by-author", bookController.GetBooksByAuthor)
-		public.GET("/books/by-category", bookController.GetBooksByCategory)
-		public.GET("/books/search", bookController.SearchBooks)
+		public.GET("/books/by-title", optionalAuth, bookController.GetBooksByTitle)
+		public.GET("/books/by-author", optionalAuth, bookController.GetBooksByAuthor)
+		public.GET("/books/by-category", optionalAuth, bookController.GetBooksByCategory)
+		public.GET("/books/search", optionalAuth, bookController.SearchBooks)
+		public.GET("/search", optionalAuth, searchController.Search) // Tìm kiếm toàn văn, xếp hạng theo độ liên quan
+		public.GET("/search/suggest", searchController.Suggest)      // Gợi ý khi đang gõ
 		public.GET("/combos", comboController.GetCombos)
 		public.GET("/combos/:id", comboController.GetComboDetails)
 		public.GET("/books/:id/combos", bookController.GetBookCombos)
+		public.GET("/books/:id/files", bookController.GetBookFiles)       // Các định dạng có sẵn
+		public.GET("/books/download/:token", bookController.DownloadFile) // Xác thực bằng token ký HMAC
+		public.HEAD("/books/download/:token", bookController.DownloadFile)
+		public.GET("/files/*key", bookController.ServeSignedFile) // Signed URL của storage local
+		public.HEAD("/files/*key", bookController.ServeSignedFile)
 		public.GET("/books/:id/reviews", reviewController.GetBookReviews) // Xem review sách
 		public.GET("/books/top-selling", bookController.GetTopBooksByCompletedOrders)
 		public.GET("/books/most-reviewed", reviewController.GetMostReviewedBooks)
 		public.GET("/books/top-rated", reviewController.GetTopRatedBooks)
 		public.GET("/categories", bookController.GetAllCategories)
+		public.POST("/payments/webhook/:provider", paymentController.Webhook) // Xác thực bằng chữ ký webhook
 	}
 
 	// Protected routes (yêu cầu JWT auth)
@@ -62,6 +82,8 @@ func SetupRouter(db *gorm.DB, cfg *config.Config) *gin.Engine {
 		{
 			user.GET("/profile", authController.GetProfile)
 			user.PUT("/profile", authController.UpdateProfile)
+			user.GET("/library", libraryController.GetLibrary)                        // Sách đã sở hữu
+			user.GET("/recommendations", recommendationController.GetRecommendations) // Gợi ý theo lịch sử mua/đánh giá
 		}
 
 		// Book routes
@@ -73,13 +95,14 @@ func SetupRouter(db *gorm.DB, cfg *config.Config) *gin.Engine {
 				adminBook.POST("", bookController.CreateBook)
 				adminBook.PUT("/:id", bookController.UpdateBook)
 				adminBook.DELETE("/:id", bookController.DeleteBook)
+				adminBook.POST("/:id/files", bookController.UploadBookFile)
+				adminBook.DELETE("/:id/files/:format", bookController.DeleteBookFile)
 
 			}
 
 			// Review routes
 			book.POST("/:id/reviews", reviewController.CreateReview) // User đánh giá sách
 			book.GET("/:id/download-link", bookController.GenerateDownloadLink)
-			book.GET("/download/:token", bookController.DownloadFile)
 			book.GET("/search-helper", bookController.SearchByKeywords)
 			// // Review management
 			review := protected.Group("/reviews")
@@ -90,12 +113,27 @@ func SetupRouter(db *gorm.DB, cfg *config.Config) *gin.Engine {
 
 		}
 
+		// Cart routes
+		cart := protected.Group("/cart")
+		{
+			cart.GET("", cartController.GetCart)
+			cart.DELETE("", cartController.ClearCart)
+			cart.POST("/items", cartController.AddItem)
+			cart.PUT("/items/:id", cartController.UpdateItem)
+			cart.DELETE("/items/:id", cartController.RemoveItem)
+			cart.POST("/checkout", cartController.Checkout)
+		}
+
 		// Order routes
 		order := protected.Group("/orders")
 		{
 			order.POST("", orderController.CreateOrder)
+			order.POST("/quote", orderController.QuoteOrder) // Xem trước tổng tiền
 			order.GET("", orderController.GetUserOrders)
 			order.GET("/:id", orderController.GetOrderDetails)
+			order.GET("/:id/history", orderController.GetOrderHistory)
+			order.POST("/:id/payments", paymentController.CreatePayment)
+			order.GET("/:id/payments", paymentController.GetOrderPayments)
 			order.PUT("/:id", orderController.UserUpdateOrder) // User cập nhật đơn hàng
 
 			// Admin/Staff only
@@ -105,6 +143,18 @@ func SetupRouter(db *gorm.DB, cfg *config.Config) *gin.Engine {
 				adminOrder.PUT("/:id/status", orderController.StaffUpdateOrder) // Cập nhật trạng thái
 			}
 		}
+
+		// Payment routes
+		payment := protected.Group("/payments")
+		{
+			payment.POST("/:id/confirm", paymentController.ConfirmPayment)
+
+			adminPayment := payment.Group("").Use(middlewares.RoleMiddleware([]string{"admin", "staff"}))
+			{
+				adminPayment.POST("/:id/refund", paymentController.RefundPayment)
+			}
+		}
+
 		combo := protected.Group("/combos")
 		{
 			adminCombo := combo.Group("").Use(middlewares.RoleMiddleware([]string{"admin", "staff"}))
@@ -124,6 +174,9 @@ func SetupRouter(db *gorm.DB, cfg *config.Config) *gin.Engine {
 			admin.PUT("/users/:id/role", authController.ChangeUserRole)
 			admin.GET("/books/:id/keywords", bookController.GetKeywordsAndTOC)
 			admin.PUT("/books/:id/keywords", bookController.UpdateKeywordsAndTOC)
+			admin.POST("/books/:id/extract", bookController.TriggerExtraction)
+			admin.GET("/books/:id/extract", bookController.GetExtractionJobs)
+			admin.GET("/extraction/health", bookController.GetExtractionHealth)
 			adminDashboard := admin.Group("/dashboard")
 			{
 				adminDashboard.GET("/top-books", bookController.GetTopBooks)
@@ -134,6 +187,16 @@ func SetupRouter(db *gorm.DB, cfg *config.Config) *gin.Engine {
 				adminDashboard.GET("/total-stats", orderController.GetOrderStats)
 				adminDashboard.GET("/top-trending", reviewController.GetBookCountAboveRating)
 				adminDashboard.GET("/order-trend", orderController.GetOrderTrends)
+				adminDashboard.GET("/search-insights", searchController.GetSearchInsights)
+			}
+			adminCoupon := admin.Group("/coupons")
+			{
+				adminCoupon.GET("", couponController.GetCoupons)
+				adminCoupon.POST("", couponController.CreateCoupon)
+				adminCoupon.GET("/:id", couponController.GetCoupon)
+				adminCoupon.PUT("/:id", couponController.UpdateCoupon)
+				adminCoupon.DELETE("/:id", couponController.DeleteCoupon)
+				adminCoupon.GET("/:id/redemptions", couponController.GetCouponRedemptions)
 			}
 			adminSystemConfig := admin.Group("/system-config")
 			{
@@ -298,6 +298,40 @@ func seedBooks(db *gorm.DB) {
 			Language:    "English",
 			Category:    "Fantasy",
 		},
+		// Sách tiếng Việt, dùng để kiểm tra tìm kiếm không dấu ("de men" khớp "Dế Mèn")
+		{
+			Title:       "Dế Mèn Phiêu Lưu Ký",
+			Author:      "Tô Hoài",
+			Description: "Cuộc phiêu lưu của chú Dế Mèn qua thế giới loài vật",
+			Price:       4.99,
+			Stock:       80,
+			ISBN:        "9786042000011",
+			Pages:       144,
+			Language:    "Vietnamese",
+			Category:    "Children",
+		},
+		{
+			Title:       "Truyện Kiều",
+			Author:      "Nguyễn Du",
+			Description: "Đoạn trường tân thanh, truyện thơ lục bát về cuộc đời Thúy Kiều",
+			Price:       6.99,
+			Stock:       60,
+			ISBN:        "9786042000028",
+			Pages:       256,
+			Language:    "Vietnamese",
+			Category:    "Fiction",
+		},
+		{
+			Title:       "Số Đỏ",
+			Author:      "Vũ Trọng Phụng",
+			Description: "Tiểu thuyết trào phúng về xã hội thành thị Hà Nội thập niên 1930",
+			Price:       5.49,
+			Stock:       45,
+			ISBN:        "9786042000035",
+			Pages:       232,
+			Language:    "Vietnamese",
+			Category:    "Fiction",
+		},
 	}
 
 	for _, book := range books {
@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/Poloni84Learning/ebook-store/config"
+)
+
+var ErrNotFound = errors.New("object not found")
+
+// ObjectInfo là metadata của một object đã lưu
+type ObjectInfo struct {
+	Key         string
+	Size        int64
+	ContentType string
+	ModTime     time.Time
+	ETag        string
+}
+
+// SignedURLOptions ghi đè header trả về khi client tải qua signed URL
+type SignedURLOptions struct {
+	ContentType        string
+	ContentDisposition string
+}
+
+// Blob là giao diện chung cho nơi lưu file (ổ đĩa local, S3/MinIO...).
+// Key dùng dấu "/" làm phân cách, ví dụ "pdf/1700000000_book.pdf".
+type Blob interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, *ObjectInfo, error)
+	Stat(ctx context.Context, key string) (*ObjectInfo, error)
+	Delete(ctx context.Context, key string) error
+	// SignedURL tạo link tải có hạn dùng, không cần xác thực thêm
+	SignedURL(ctx context.Context, key string, ttl time.Duration, opts SignedURLOptions) (string, error)
+	// PublicURL trả về URL công khai (dùng cho ảnh bìa)
+	PublicURL(key string) string
+}
+
+// NewBlob khởi tạo storage theo cấu hình
+func NewBlob(cfg *config.Config) (Blob, error) {
+	switch cfg.StorageBackend {
+	case "", "local":
+		return NewLocal(cfg.UploadRoot, cfg.PublicBaseURL, cfg.DownloadTokenSecret), nil
+	case "s3":
+		return NewS3(S3Options{
+			Endpoint:      cfg.S3Endpoint,
+			Region:        cfg.S3Region,
+			Bucket:        cfg.S3Bucket,
+			AccessKey:     cfg.S3AccessKey,
+			SecretKey:     cfg.S3SecretKey,
+			PathStyle:     cfg.S3ForcePathStyle,
+			PublicBaseURL: cfg.S3PublicURL,
+		})
+	default:
+		return nil, fmt.Errorf("unsupported storage backend: %s", cfg.StorageBackend)
+	}
+}
@@ -0,0 +1,185 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SignedPathPrefix là route phục vụ signed URL của Local
+const SignedPathPrefix = "/api/files/"
+
+// Local lưu file trên ổ đĩa. Khi chạy nhiều replica, Root phải là volume dùng chung.
+type Local struct {
+	Root    string
+	BaseURL string // URL công khai của API
+	Secret  string // Khóa ký signed URL
+}
+
+func NewLocal(root, baseURL, secret string) *Local {
+	return &Local{Root: root, BaseURL: strings.TrimRight(baseURL, "/"), Secret: secret}
+}
+
+// path đổi key thành đường dẫn trong Root, không cho thoát ra ngoài bằng ".."
+func (l *Local) path(key string) (string, error) {
+	clean := path.Clean("/" + key)
+	if clean == "/" {
+		return "", fmt.Errorf("invalid key: %q", key)
+	}
+	return filepath.Join(l.Root, filepath.FromSlash(clean)), nil
+}
+
+func (l *Local) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	dst, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	// Ghi file tạm rồi rename để không ai đọc được file ghi dở
+	tmp, err := os.CreateTemp(filepath.Dir(dst), ".upload-*")
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), dst); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+// Get trả về *os.File nên caller có thể Seek (phục vụ Range)
+func (l *Local) Get(ctx context.Context, key string) (io.ReadCloser, *ObjectInfo, error) {
+	p, err := l.path(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	file, err := os.Open(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	fi, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	return file, l.info(key, fi), nil
+}
+
+func (l *Local) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	p, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := os.Stat(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return l.info(key, fi), nil
+}
+
+func (l *Local) Delete(ctx context.Context, key string) error {
+	p, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (l *Local) SignedURL(ctx context.Context, key string, ttl time.Duration, opts SignedURLOptions) (string, error) {
+	q := url.Values{}
+	q.Set("expires", strconv.FormatInt(time.Now().Add(ttl).Unix(), 10))
+	if opts.ContentType != "" {
+		q.Set("response-content-type", opts.ContentType)
+	}
+	if opts.ContentDisposition != "" {
+		q.Set("response-content-disposition", opts.ContentDisposition)
+	}
+	q.Set("signature", l.sign(key, q))
+	return l.BaseURL + SignedPathPrefix + (&url.URL{Path: key}).EscapedPath() + "?" + q.Encode(), nil
+}
+
+func (l *Local) PublicURL(key string) string {
+	return "/storage/" + key
+}
+
+// ServeSigned phục vụ file qua signed URL do SignedURL tạo (hỗ trợ Range)
+func (l *Local) ServeSigned(w http.ResponseWriter, r *http.Request, key string) {
+	q := r.URL.Query()
+	expires, err := strconv.ParseInt(q.Get("expires"), 10, 64)
+	if err != nil || time.Now().Unix() > expires ||
+		!hmac.Equal([]byte(q.Get("signature")), []byte(l.sign(key, q))) {
+		http.Error(w, "invalid or expired link", http.StatusForbidden)
+		return
+	}
+
+	rc, info, err := l.Get(r.Context(), key)
+	if errors.Is(err, ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, "cannot open file", http.StatusInternalServerError)
+		return
+	}
+	defer rc.Close()
+
+	if ct := q.Get("response-content-type"); ct != "" {
+		w.Header().Set("Content-Type", ct)
+	}
+	if cd := q.Get("response-content-disposition"); cd != "" {
+		w.Header().Set("Content-Disposition", cd)
+	}
+	w.Header().Set("ETag", info.ETag)
+	http.ServeContent(w, r, path.Base(key), info.ModTime, rc.(io.ReadSeeker))
+}
+
+// sign ký key cùng các tham số (trừ chính signature)
+func (l *Local) sign(key string, q url.Values) string {
+	mac := hmac.New(sha256.New, []byte(l.Secret))
+	fmt.Fprintf(mac, "%s\n%s\n%s\n%s", key, q.Get("expires"), q.Get("response-content-type"), q.Get("response-content-disposition"))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (l *Local) info(key string, fi os.FileInfo) *ObjectInfo {
+	return &ObjectInfo{
+		Key:         key,
+		Size:        fi.Size(),
+		ContentType: mime.TypeByExtension(path.Ext(key)),
+		ModTime:     fi.ModTime(),
+		ETag:        fmt.Sprintf(`"%x-%x"`, fi.Size(), fi.ModTime().UnixNano()),
+	}
+}
@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	ErrDownloadTokenInvalid = errors.New("invalid download token")
+	ErrDownloadTokenExpired = errors.New("download token expired")
+)
+
+// DownloadClaims là thông tin được ký trong link tải sách
+type DownloadClaims struct {
+	BookID    uint
+	UserID    uint
+	ExpiresAt time.Time
+}
+
+// GenerateDownloadToken tạo token dạng <payload>.<chữ ký> (base64url), payload = "bookID.userID.expiry".
+// Token tự chứa thông tin nên không cần lưu trữ và dùng được trên nhiều instance.
+func GenerateDownloadToken(secret string, bookID, userID uint, ttl time.Duration) (string, time.Time) {
+	expiresAt := time.Now().Add(ttl).Truncate(time.Second)
+	payload := fmt.Sprintf("%d.%d.%d", bookID, userID, expiresAt.Unix())
+
+	encoded := base64.RawURLEncoding.EncodeToString([]byte(payload))
+	return encoded + "." + signDownloadPayload(secret, encoded), expiresAt
+}
+
+// ParseDownloadToken kiểm tra chữ ký, hạn dùng và trả về thông tin trong token
+func ParseDownloadToken(secret, token string) (*DownloadClaims, error) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, ErrDownloadTokenInvalid
+	}
+	if !hmac.Equal([]byte(signature), []byte(signDownloadPayload(secret, encoded))) {
+		return nil, ErrDownloadTokenInvalid
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrDownloadTokenInvalid
+	}
+
+	var bookID, userID uint
+	var expiry int64
+	if _, err := fmt.Sscanf(string(payload), "%d.%d.%d", &bookID, &userID, &expiry); err != nil {
+		return nil, ErrDownloadTokenInvalid
+	}
+
+	claims := &DownloadClaims{BookID: bookID, UserID: userID, ExpiresAt: time.Unix(expiry, 0)}
+	if time.Now().After(claims.ExpiresAt) {
+		return nil, ErrDownloadTokenExpired
+	}
+	return claims, nil
+}
+
+func signDownloadPayload(secret, encoded string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(encoded))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,37 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Chuẩn hóa chuỗi để tìm kiếm không phân biệt dấu và hoa thường: "Sách Đẹp" -> "sach dep".
+// Phải cho cùng kết quả với hàm fold_text() trong database (lower(unaccent(...))), vì
+// cột được chuẩn hóa bằng fold_text còn từ khóa tìm kiếm được chuẩn hóa bằng FoldText.
+
+var foldReplacer = strings.NewReplacer("đ", "d", "Đ", "D")
+
+// FoldText bỏ dấu (kể cả đ/Đ) và chuyển về chữ thường
+func FoldText(s string) string {
+	// NFD tách dấu thành ký tự kết hợp riêng để xóa, áp dụng cho cả chuỗi dựng sẵn lẫn tổ hợp
+	t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	folded, _, err := transform.String(t, s)
+	if err != nil {
+		folded = s
+	}
+	return strings.ToLower(foldReplacer.Replace(folded))
+}
+
+// EscapeLike escape ký tự đại diện của LIKE để chuỗi được so khớp nguyên văn
+func EscapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// FoldedContains trả về mẫu LIKE "chứa s" đã chuẩn hóa, dùng với fold_text(cột) LIKE ?
+func FoldedContains(s string) string {
+	return "%" + EscapeLike(FoldText(s)) + "%"
+}
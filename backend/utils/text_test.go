@@ -0,0 +1,54 @@
+package utils
+
+import "testing"
+
+func TestFoldText(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"tên sách", "Dế Mèn Phiêu Lưu Ký", "de men phieu luu ky"},
+		{"đ hoa đầu câu", "Đắc Nhân Tâm", "dac nhan tam"},
+		{"nhiều dấu", "Tuổi Trẻ Đáng Giá Bao Nhiêu", "tuoi tre dang gia bao nhieu"},
+		{"ư ơ", "Nhà Giả Kim - Những Người Khốn Khổ", "nha gia kim - nhung nguoi khon kho"},
+		{"đ thường và hoa", "đĐ đường ĐƯỜNG", "dd duong duong"},
+		// Chuỗi dạng tổ hợp (NFD): chữ gốc + dấu kết hợp riêng, thường gặp khi copy từ macOS
+		{"dấu kết hợp", "Tie\u0302\u0301ng Vie\u0323\u0302t", "tieng viet"},
+		{"dấu kết hợp với đ", "\u0110a\u0300 La\u0323t", "da lat"},
+		{"không dấu", "Clean Code", "clean code"},
+		{"chuỗi rỗng", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FoldText(tt.in); got != tt.want {
+				t.Errorf("FoldText(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEscapeLike(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"dac nhan tam", "dac nhan tam"},
+		{"100%", `100\%`},
+		{"snake_case", `snake\_case`},
+		{`C:\sach`, `C:\\sach`},
+		// Dấu "\" có sẵn phải được escape trước, không escape chồng lên "\%" vừa tạo
+		{`50\%_`, `50\\\%\_`},
+	}
+	for _, tt := range tests {
+		if got := EscapeLike(tt.in); got != tt.want {
+			t.Errorf("EscapeLike(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestFoldedContains(t *testing.T) {
+	if got, want := FoldedContains("Đắc_Nhân 100%"), `%dac\_nhan 100\%%`; got != want {
+		t.Errorf("FoldedContains = %q, want %q", got, want)
+	}
+}
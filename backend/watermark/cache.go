@@ -0,0 +1,85 @@
+package watermark
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Cache lưu file đã đóng dấu theo từng user và sách để các lần tải (và request Range)
+// sau dùng lại đúng một file, không phải xử lý lại. Cache nằm trên ổ đĩa của từng
+// instance; instance chưa có sẽ tự tạo lại từ file gốc.
+type Cache struct {
+	Dir string
+}
+
+// Source là file PDF gốc; Size và ModTime dùng để nhận biết file gốc đã thay đổi
+type Source struct {
+	Size    int64
+	ModTime time.Time
+	Open    func() (io.ReadCloser, error)
+}
+
+// Get trả về đường dẫn file đã đóng dấu, tạo mới nếu chưa có hoặc file gốc đã thay đổi.
+// Nếu lỗi, caller nên phục vụ file gốc.
+func (c *Cache) Get(src Source, bookID, userID uint, mark Mark) (string, error) {
+	// Khóa cache đổi khi file gốc, người mua hoặc đơn hàng thay đổi
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%d|%d|%s|%d", src.Size, src.ModTime.UnixNano(), mark.Licensee, mark.OrderID)))
+	dir := filepath.Join(c.Dir, fmt.Sprint(bookID))
+	prefix := fmt.Sprintf("%d-", userID)
+	path := filepath.Join(dir, prefix+hex.EncodeToString(sum[:8])+".pdf")
+
+	if _, err := os.Stat(path); err == nil {
+		return path, nil
+	}
+
+	rc, err := src.Open()
+	if err != nil {
+		return "", err
+	}
+	original, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil {
+		return "", err
+	}
+	stamped, err := Stamp(original, mark)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	tmp, err := os.CreateTemp(dir, prefix+"*.tmp")
+	if err != nil {
+		return "", err
+	}
+	if _, err := tmp.Write(stamped); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+
+	// Xóa các bản cũ của cùng user/sách
+	if entries, err := os.ReadDir(dir); err == nil {
+		for _, e := range entries {
+			if strings.HasPrefix(e.Name(), prefix) && strings.HasSuffix(e.Name(), ".pdf") && filepath.Join(dir, e.Name()) != path {
+				os.Remove(filepath.Join(dir, e.Name()))
+			}
+		}
+	}
+	return path, nil
+}
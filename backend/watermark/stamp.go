@@ -0,0 +1,196 @@
+package watermark
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"github.com/Poloni84Learning/ebook-store/pdf"
+)
+
+// Mark là thông tin người mua được đóng dấu vào file
+type Mark struct {
+	Licensee string // Email hoặc username người mua
+	OrderID  uint   // 0 nếu không có đơn hàng (staff/admin)
+	IssuedAt time.Time
+}
+
+// Text là dòng chữ hiển thị ở chân trang đầu tiên
+func (m Mark) Text() string {
+	order := "staff copy"
+	if m.OrderID != 0 {
+		order = fmt.Sprintf("order #%d", m.OrderID)
+	}
+	return fmt.Sprintf("Licensed to %s - %s - %s", m.Licensee, order, m.IssuedAt.UTC().Format("2006-01-02 15:04 UTC"))
+}
+
+const (
+	fontSize   = 7.0
+	footerPadX = 18.0
+	footerPadY = 10.0
+)
+
+// Stamp thêm dấu chân trang (annotation /Watermark, in được) vào trang đầu và ghi
+// thông tin người mua vào Info dictionary. File gốc được giữ nguyên; phần thay đổi
+// được nối vào cuối dưới dạng incremental update nên không cần viết lại toàn bộ PDF.
+// File không đọc được trả về pdf.ErrMalformedPDF để caller phục vụ file gốc.
+func Stamp(src []byte, mark Mark) (stamped []byte, err error) {
+	// Bộ đọc đã kiểm tra dữ liệu đầu vào; recover chỉ là lớp bảo vệ cuối để một file lỗi không làm sập server
+	defer func() {
+		if r := recover(); r != nil {
+			stamped, err = nil, fmt.Errorf("%w: %v", pdf.ErrMalformedPDF, r)
+		}
+	}()
+
+	doc, err := pdf.Parse(src)
+	if err != nil {
+		return nil, err
+	}
+
+	root, err := doc.DerefDict(doc.Trailer().Get("Root"))
+	if err != nil {
+		return nil, err
+	}
+	pageRef, page, mediaBox, err := doc.FirstPage(root.Get("Pages"))
+	if err != nil {
+		return nil, err
+	}
+
+	size := pdf.IntValue(doc.Trailer().Get("Size"))
+	if size <= 0 {
+		return nil, fmt.Errorf("%w: trailer has no /Size", pdf.ErrMalformedPDF)
+	}
+	next := size
+	alloc := func() pdf.Ref {
+		r := pdf.Ref{Num: next}
+		next++
+		return r
+	}
+	fontRef, formRef, annotRef, infoRef := alloc(), alloc(), alloc(), alloc()
+
+	updates := make(map[pdf.Ref]pdf.Object)
+
+	// Font chuẩn Helvetica, không cần nhúng
+	font := pdf.NewDict()
+	font.Set("Type", pdf.Name("Font"))
+	font.Set("Subtype", pdf.Name("Type1"))
+	font.Set("BaseFont", pdf.Name("Helvetica"))
+	font.Set("Encoding", pdf.Name("WinAnsiEncoding"))
+	updates[fontRef] = font
+
+	// Appearance stream của annotation, có Resources riêng nên không phải sửa Resources của trang
+	text := asciiText(mark.Text())
+	width := float64(len(text))*fontSize*0.55 + 4
+	height := fontSize + 4
+	content := fmt.Sprintf("q 0.45 g BT /WmF %s Tf 2 3 Td %s Tj ET Q", fmtNum(fontSize), literal(text))
+
+	fonts := pdf.NewDict()
+	fonts.Set("WmF", fontRef)
+	resources := pdf.NewDict()
+	resources.Set("Font", fonts)
+	formDict := pdf.NewDict()
+	formDict.Set("Type", pdf.Name("XObject"))
+	formDict.Set("Subtype", pdf.Name("Form"))
+	formDict.Set("BBox", pdf.Array{pdf.Keyword("0"), pdf.Keyword("0"), pdf.Keyword(fmtNum(width)), pdf.Keyword(fmtNum(height))})
+	formDict.Set("Resources", resources)
+	updates[formRef] = &pdf.Stream{Dict: formDict, Data: []byte(content)}
+
+	llx, lly := mediaBox[0], mediaBox[1]
+	ap := pdf.NewDict()
+	ap.Set("N", formRef)
+	annot := pdf.NewDict()
+	annot.Set("Type", pdf.Name("Annot"))
+	annot.Set("Subtype", pdf.Name("Watermark"))
+	annot.Set("Rect", pdf.Array{
+		pdf.Keyword(fmtNum(llx + footerPadX)), pdf.Keyword(fmtNum(lly + footerPadY)),
+		pdf.Keyword(fmtNum(llx + footerPadX + width)), pdf.Keyword(fmtNum(lly + footerPadY + height)),
+	})
+	annot.Set("F", pdf.Keyword("132")) // Print + Locked
+	annot.Set("P", pageRef)
+	annot.Set("AP", ap)
+	updates[annotRef] = annot
+
+	// Thêm annotation vào /Annots của trang
+	newPage := page.Clone()
+	switch annots := page.Get("Annots").(type) {
+	case pdf.Array:
+		newPage.Set("Annots", append(append(pdf.Array{}, annots...), annotRef))
+	case pdf.Ref:
+		existing, err := doc.Deref(annots)
+		if err != nil {
+			return nil, err
+		}
+		arr, _ := existing.(pdf.Array)
+		updates[annots] = append(append(pdf.Array{}, arr...), annotRef)
+	default:
+		newPage.Set("Annots", pdf.Array{annotRef})
+	}
+	updates[pageRef] = newPage
+
+	// Annotation /Watermark có từ PDF 1.6: nâng /Version trong catalog nếu file cũ hơn
+	if doc.Version() < 1.6 {
+		rootRef, ok := doc.Trailer().Get("Root").(pdf.Ref)
+		if !ok {
+			return nil, fmt.Errorf("%w: /Root is not indirect", pdf.ErrMalformedPDF)
+		}
+		newRoot := root.Clone()
+		newRoot.Set("Version", pdf.Name("1.6"))
+		updates[rootRef] = newRoot
+	}
+
+	// Info dictionary: giữ thông tin cũ, thêm thông tin người mua (không hiển thị)
+	info := pdf.NewDict()
+	if old, err := doc.DerefDict(doc.Trailer().Get("Info")); err == nil {
+		info = old.Clone()
+	}
+	info.Set("EbookStoreLicensee", textString(mark.Licensee))
+	if mark.OrderID != 0 {
+		info.Set("EbookStoreOrder", pdf.RawString(literal(strconv.FormatUint(uint64(mark.OrderID), 10))))
+	}
+	info.Set("EbookStoreIssued", pdfDate(mark.IssuedAt))
+	info.Set("ModDate", pdfDate(mark.IssuedAt))
+	updates[infoRef] = info
+
+	return doc.AppendUpdate(updates, next, infoRef), nil
+}
+
+// asciiText thay ký tự ngoài ASCII để hiển thị được bằng font chuẩn
+func asciiText(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r >= 0x20 && r < 0x7f {
+			b.WriteRune(r)
+		} else {
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
+
+// literal tạo chuỗi PDF dạng (...) đã escape
+func literal(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `(`, `\(`, `)`, `\)`)
+	return "(" + r.Replace(s) + ")"
+}
+
+// textString mã hóa chuỗi Unicode theo UTF-16BE (có BOM) dạng hex
+func textString(s string) pdf.RawString {
+	var b strings.Builder
+	b.WriteString("<FEFF")
+	for _, u := range utf16.Encode([]rune(s)) {
+		fmt.Fprintf(&b, "%04X", u)
+	}
+	b.WriteString(">")
+	return pdf.RawString(b.String())
+}
+
+func pdfDate(t time.Time) pdf.RawString {
+	return pdf.RawString("(D:" + t.UTC().Format("20060102150405") + "+00'00')")
+}
+
+func fmtNum(f float64) string {
+	return strconv.FormatFloat(math.Round(f*100)/100, 'f', -1, 64)
+}